@@ -0,0 +1,307 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/tomdoesdev/brace/internal/compiler"
+	braceerrors "github.com/tomdoesdev/brace/internal/errors"
+	"github.com/tomdoesdev/brace/internal/transform"
+)
+
+// buildJob is a single file compiled by `brace build`
+type buildJob struct {
+	input  string
+	output string
+	format transform.OutputFormat
+}
+
+// buildResult is the outcome of a build job
+type buildResult struct {
+	job         buildJob
+	err         error
+	report      string                   // text report of a compilation error
+	diagnostics []braceerrors.Diagnostic // diagnostics of the compilation
+}
+
+// formatRule maps files matching a glob to an output format
+type formatRule struct {
+	pattern string
+	format  transform.OutputFormat
+}
+
+// runBuild implements `brace build`: compile many files into a mirrored output tree
+func runBuild(args []string) int {
+	fs := flag.NewFlagSet("build", flag.ExitOnError)
+	outDir := fs.String("out", "", "Output directory (required)")
+	formatName := fs.String("format", "json", "Default output format: json or yaml")
+	jobs := fs.Int("j", runtime.NumCPU(), "Number of files to compile concurrently")
+	var rules stringList
+	fs.Var(&rules, "rule", "Output format for files matching a glob, as GLOB=FORMAT (repeatable, first match wins)")
+	flags := addCompileFlags(fs)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s build -out DIR [options] <dir|file|glob>...\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "Compile every .brace file found into DIR, mirroring the input tree.\n")
+		fmt.Fprintf(os.Stderr, "The output format of a file is chosen by the first matching -rule, then by an\n")
+		fmt.Fprintf(os.Stderr, "inner extension (app.yaml.brace -> app.yaml), then by -format.\n\nOptions:\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if *outDir == "" || fs.NArg() == 0 {
+		fs.Usage()
+		return 1
+	}
+	if *jobs < 1 {
+		*jobs = 1
+	}
+
+	color, err := reportFlags(flags)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	defaultFormat, err := parseOutputFormat(*formatName)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	formatRules, err := parseFormatRules(rules)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	buildJobs, err := collectBuildJobs(fs.Args(), *outDir, defaultFormat, formatRules)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error %v\n", err)
+		return 1
+	}
+	if len(buildJobs) == 0 {
+		fmt.Fprintf(os.Stderr, "Error: no .brace files found\n")
+		return 1
+	}
+
+	results := runBuildJobs(buildJobs, *jobs, compilerOptions(flags), color)
+
+	failed := 0
+	var diagnostics []braceerrors.Diagnostic
+	for _, result := range results {
+		diagnostics = append(diagnostics, result.diagnostics...)
+		if result.err == nil {
+			continue
+		}
+		failed++
+		switch {
+		case result.report != "" && *flags.diagnostics == "text":
+			fmt.Fprintf(os.Stderr, "FAIL %s\n%s\n\n", result.job.input, result.report)
+		case result.report == "":
+			fmt.Fprintf(os.Stderr, "FAIL %s\n%v\n\n", result.job.input, result.err)
+		}
+	}
+	if *flags.diagnostics != "text" {
+		writeDiagnostics(os.Stderr, diagnostics, *flags.diagnostics)
+	}
+	fmt.Fprintf(os.Stderr, "Built %d of %d files", len(results)-failed, len(results))
+	if failed > 0 {
+		fmt.Fprintf(os.Stderr, ", %d failed\n", failed)
+		return 1
+	}
+	fmt.Fprintf(os.Stderr, " into %s\n", *outDir)
+	return 0
+}
+
+// parseFormatRules parses GLOB=FORMAT rules
+func parseFormatRules(rules []string) ([]formatRule, error) {
+	var parsed []formatRule
+	for _, rule := range rules {
+		pattern, name, ok := strings.Cut(rule, "=")
+		if !ok || pattern == "" {
+			return nil, fmt.Errorf("invalid -rule %q (expected GLOB=FORMAT)", rule)
+		}
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid -rule pattern %q", pattern)
+		}
+		format, err := parseOutputFormat(name)
+		if err != nil {
+			return nil, err
+		}
+		parsed = append(parsed, formatRule{pattern: pattern, format: format})
+	}
+	return parsed, nil
+}
+
+// collectBuildJobs expands directories and globs into build jobs
+// Each input's path relative to its root (the directory argument, or the static
+// prefix of a glob) is mirrored under outDir; inputs that map to the same
+// output file are an error
+func collectBuildJobs(inputs []string, outDir string, defaultFormat transform.OutputFormat, rules []formatRule) ([]buildJob, error) {
+	seen := make(map[string]bool)
+	var jobs []buildJob
+
+	add := func(root, file string) error {
+		rel, err := filepath.Rel(root, file)
+		if err != nil {
+			return err
+		}
+		if seen[file] {
+			return nil
+		}
+		seen[file] = true
+
+		output, format := buildOutputPath(filepath.ToSlash(rel), defaultFormat, rules)
+		jobs = append(jobs, buildJob{
+			input:  file,
+			output: filepath.Join(outDir, filepath.FromSlash(output)),
+			format: format,
+		})
+		return nil
+	}
+
+	for _, input := range inputs {
+		matches := []string{input}
+		root := filepath.Dir(input)
+		if strings.ContainsAny(input, "*?[") {
+			var err error
+			matches, err = filepath.Glob(input)
+			if err != nil {
+				return nil, fmt.Errorf("invalid pattern %q: %v", input, err)
+			}
+			root = globRoot(input)
+		}
+
+		for _, match := range matches {
+			info, err := os.Stat(match)
+			if err != nil {
+				return nil, err
+			}
+			if !info.IsDir() {
+				if err := add(root, match); err != nil {
+					return nil, err
+				}
+				continue
+			}
+
+			err = filepath.WalkDir(match, func(file string, entry fs.DirEntry, err error) error {
+				if err != nil {
+					return err
+				}
+				if entry.IsDir() || filepath.Ext(file) != ".brace" {
+					return nil
+				}
+				return add(match, file)
+			})
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].input < jobs[j].input })
+
+	// Jobs writing the same file would race and silently drop one result
+	sources := make(map[string]string, len(jobs))
+	for _, job := range jobs {
+		if other, ok := sources[job.output]; ok {
+			return nil, fmt.Errorf("%s and %s both build to %s", other, job.input, job.output)
+		}
+		sources[job.output] = job.input
+	}
+	return jobs, nil
+}
+
+// globRoot returns the directory part of a glob before its first wildcard
+func globRoot(pattern string) string {
+	idx := strings.IndexAny(pattern, "*?[")
+	return filepath.Dir(pattern[:idx] + "x")
+}
+
+// buildOutputPath maps a relative input path to its output path and format
+func buildOutputPath(rel string, defaultFormat transform.OutputFormat, rules []formatRule) (string, transform.OutputFormat) {
+	base := strings.TrimSuffix(rel, ".brace")
+
+	// Inner extension: app.yaml.brace -> app.yaml
+	format, hasInner := defaultFormat, false
+	switch strings.ToLower(path.Ext(base)) {
+	case ".json":
+		format, hasInner = transform.FormatJSON, true
+	case ".yaml", ".yml":
+		format, hasInner = transform.FormatYAML, true
+	}
+
+	for _, rule := range rules {
+		matchedPath, _ := path.Match(rule.pattern, rel)
+		matchedName, _ := path.Match(rule.pattern, path.Base(rel))
+		if matchedPath || matchedName {
+			format = rule.format
+			if hasInner {
+				base = strings.TrimSuffix(base, path.Ext(base))
+				hasInner = false
+			}
+			break
+		}
+	}
+
+	if hasInner {
+		return base, format
+	}
+	return base + "." + string(format), format
+}
+
+// runBuildJobs compiles jobs on a pool of workers, each with its own compiler
+func runBuildJobs(jobs []buildJob, workers int, opts []compiler.Option, color bool) []buildResult {
+	queue := make(chan int)
+	results := make([]buildResult, len(jobs))
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers && w < len(jobs); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c := compiler.New(opts...)
+			for i := range queue {
+				results[i] = buildFile(c, jobs[i], color)
+			}
+		}()
+	}
+
+	for i := range jobs {
+		queue <- i
+	}
+	close(queue)
+	wg.Wait()
+
+	return results
+}
+
+// buildFile compiles one file and writes its output
+func buildFile(c *compiler.Compiler, job buildJob, color bool) buildResult {
+	result := buildResult{job: job}
+	source, err := readSourceFile(job.input)
+	if err != nil {
+		result.err = err
+		return result
+	}
+
+	output, err := c.CompileFileToFormat(source, job.input, job.format)
+	result.diagnostics = c.Diagnostics()
+	if err != nil {
+		result.err, result.report = err, errorReport(c, err, source, job.input, color)
+		return result
+	}
+
+	if err := os.MkdirAll(filepath.Dir(job.output), 0755); err != nil {
+		result.err = fmt.Errorf("creating output directory: %v", err)
+	} else if err := writeFileAtomic(job.output, []byte(output)); err != nil {
+		result.err = fmt.Errorf("writing %s: %v", job.output, err)
+	}
+	return result
+}
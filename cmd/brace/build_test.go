@@ -0,0 +1,77 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/tomdoesdev/brace/internal/transform"
+)
+
+func TestGlobRoot(t *testing.T) {
+	tests := []struct {
+		pattern, root string
+	}{
+		{"configs/*.brace", "configs"},
+		{"configs/prod/**/app.brace", filepath.Join("configs", "prod")},
+		{"*.brace", "."},
+		{"a/b[0-9]/c.brace", "a"},
+		{"a/b?.brace", "a"},
+	}
+	for _, tt := range tests {
+		if root := globRoot(tt.pattern); root != tt.root {
+			t.Errorf("globRoot(%q) = %q, expected %q", tt.pattern, root, tt.root)
+		}
+	}
+}
+
+func TestBuildOutputPath(t *testing.T) {
+	rules := []formatRule{{pattern: "k8s/*", format: transform.FormatYAML}, {pattern: "*.legacy.brace", format: transform.FormatJSON}}
+	tests := []struct {
+		rel, output string
+		format      transform.OutputFormat
+	}{
+		{"app.brace", "app.json", transform.FormatJSON},
+		{"sub/app.yaml.brace", "sub/app.yaml", transform.FormatYAML},
+		{"sub/app.yml.brace", "sub/app.yml", transform.FormatYAML},
+		{"k8s/deploy.brace", "k8s/deploy.yaml", transform.FormatYAML},
+		{"k8s/deploy.json.brace", "k8s/deploy.yaml", transform.FormatYAML},
+		{"old/app.legacy.brace", "old/app.legacy.json", transform.FormatJSON},
+	}
+	for _, tt := range tests {
+		output, format := buildOutputPath(tt.rel, transform.FormatJSON, rules)
+		if output != tt.output || format != tt.format {
+			t.Errorf("buildOutputPath(%q) = %q, %s; expected %q, %s", tt.rel, output, format, tt.output, tt.format)
+		}
+	}
+}
+
+func TestCollectBuildJobsCollision(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"a/x.brace", "b/x.brace", "b/y.yaml.brace"} {
+		file := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(file, []byte("@brace \"1.0.0\"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	out := filepath.Join(dir, "out")
+
+	jobs, err := collectBuildJobs([]string{filepath.Join(dir, "b")}, out, transform.FormatJSON, nil)
+	if err != nil || len(jobs) != 2 || jobs[1].output != filepath.Join(out, "y.yaml") {
+		t.Errorf("expected two jobs for b, got %+v, %v", jobs, err)
+	}
+
+	_, err = collectBuildJobs([]string{filepath.Join(dir, "a"), filepath.Join(dir, "b")}, out, transform.FormatJSON, nil)
+	if err == nil {
+		t.Fatal("expected a collision between a/x.brace and b/x.brace")
+	}
+	for _, want := range []string{filepath.Join("a", "x.brace"), filepath.Join("b", "x.brace"), filepath.Join(out, "x.json")} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("expected the error to name %s, got %v", want, err)
+		}
+	}
+}
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/tomdoesdev/brace/internal/compiler"
+	"github.com/tomdoesdev/brace/internal/diff"
+	"github.com/tomdoesdev/brace/internal/secret"
+	"github.com/tomdoesdev/brace/internal/transform"
+	"github.com/tomdoesdev/brace/internal/value"
+)
+
+// diffSide is one compiled input of `brace diff`
+type diffSide struct {
+	filename  string
+	value     interface{}
+	locations map[string]transform.Location // nil for JSON and YAML inputs
+}
+
+// diffLocation is a source location in JSON diff output
+type diffLocation struct {
+	File   string `json:"file"`
+	Line   int    `json:"line,omitempty"`
+	Column int    `json:"column,omitempty"`
+}
+
+// diffEntry is a change in JSON diff output
+// Old and New are always present, as either may be null; kind tells whether
+// a null side is a null value or was added or removed
+type diffEntry struct {
+	Kind        diff.Kind     `json:"kind"`
+	Path        string        `json:"path"`
+	Pointer     string        `json:"pointer"`
+	Old         interface{}   `json:"old"`
+	New         interface{}   `json:"new"`
+	OldLocation *diffLocation `json:"old_location,omitempty"`
+	NewLocation *diffLocation `json:"new_location,omitempty"`
+}
+
+// runDiff implements `brace diff`: compare the compiled values of two files
+func runDiff(args []string) int {
+	fs := flag.NewFlagSet("diff", flag.ExitOnError)
+	jsonOutput := fs.Bool("json", false, "Print the changes as JSON")
+	flags := addCompileFlags(fs)
+	// Diff output is meant for review, so secrets are redacted unless -redact=false
+	redact := fs.Lookup("redact")
+	redact.DefValue = "true"
+	redact.Value.Set("true")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s diff [options] <old> <new>\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "Compile both files with the same environment and print the values that were\n")
+		fmt.Fprintf(os.Stderr, "added, removed or changed, with their source locations. Either side may also be\n")
+		fmt.Fprintf(os.Stderr, "a compiled .json or .yaml file. Secrets are compared by value but printed as\n")
+		fmt.Fprintf(os.Stderr, "%s unless -redact=false.\n\n", secret.Redacted)
+		fmt.Fprintf(os.Stderr, "Exit status: 0 no differences, 1 differences, 2 error\n\nOptions:\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() != 2 {
+		fs.Usage()
+		return exitError
+	}
+
+	color, err := reportFlags(flags)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitError
+	}
+
+	c := compiler.New(append(compilerOptions(flags), compiler.WithSecretValues())...)
+	var sides [2]diffSide
+	for i, filename := range fs.Args() {
+		side, ok := loadDiffSide(c, filename, flags, color)
+		if !ok {
+			return exitError
+		}
+		sides[i] = side
+	}
+
+	changes := diff.Compare(sides[0].value, sides[1].value)
+	if *jsonOutput {
+		if err := printDiffJSON(changes, sides, *flags.redact); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return exitError
+		}
+	} else {
+		printDiff(changes, sides, *flags.redact)
+	}
+
+	if len(changes) > 0 {
+		return 1
+	}
+	return 0
+}
+
+// loadDiffSide compiles a BRACE file, or reads an already compiled JSON or
+// YAML file, reporting any error
+func loadDiffSide(c *compiler.Compiler, filename string, flags *compileFlags, color bool) (diffSide, bool) {
+	source, err := readSourceFile(filename)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error %v\n", err)
+		return diffSide{}, false
+	}
+
+	var v interface{}
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".json":
+		v, err = value.FromJSON([]byte(source))
+	case ".yaml", ".yml":
+		v, err = value.FromYAML([]byte(source))
+	default:
+		v, err = c.CompileValue(source, filename)
+		reportCompile(c, err, source, filename, flags, color)
+		return diffSide{filename: filename, value: v, locations: c.Locations()}, err == nil
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", filename, err)
+		return diffSide{}, false
+	}
+	return diffSide{filename: filename, value: v}, true
+}
+
+// locate returns the source location of pointer in side
+func (side diffSide) locate(pointer string) *diffLocation {
+	if side.locations == nil {
+		return &diffLocation{File: side.filename}
+	}
+	location := transform.Locate(side.locations, pointer)
+	return &diffLocation{File: side.filename, Line: location.Line, Column: location.Column}
+}
+
+func (l *diffLocation) String() string {
+	if l.Line == 0 {
+		return l.File
+	}
+	return fmt.Sprintf("%s:%d:%d", l.File, l.Line, l.Column)
+}
+
+// printDiff prints changes as text, one block per change
+func printDiff(changes []diff.Change, sides [2]diffSide, redact bool) {
+	counts := make(map[diff.Kind]int)
+	for _, change := range changes {
+		counts[change.Kind]++
+		pointer := change.Path.Pointer()
+		path := displayPath(change)
+
+		switch change.Kind {
+		case diff.Added:
+			fmt.Printf("+ %s  (%s)\n", path, sides[1].locate(pointer))
+			fmt.Printf("    + %s\n", showValue(change.New, redact))
+		case diff.Removed:
+			fmt.Printf("- %s  (%s)\n", path, sides[0].locate(pointer))
+			fmt.Printf("    - %s\n", showValue(change.Old, redact))
+		case diff.Changed:
+			old, new := showValue(change.Old, redact), showValue(change.New, redact)
+			if old == new {
+				// Only redacted secrets differ
+				new += " (changed)"
+			}
+			fmt.Printf("~ %s  (%s -> %s)\n", path, sides[0].locate(pointer), sides[1].locate(pointer))
+			fmt.Printf("    - %s\n", old)
+			fmt.Printf("    + %s\n", new)
+		}
+	}
+
+	if len(changes) == 0 {
+		fmt.Println("No differences")
+		return
+	}
+	fmt.Printf("\n%d added, %d removed, %d changed\n", counts[diff.Added], counts[diff.Removed], counts[diff.Changed])
+}
+
+// printDiffJSON prints changes as a JSON array
+func printDiffJSON(changes []diff.Change, sides [2]diffSide, redact bool) error {
+	entries := make([]diffEntry, 0, len(changes))
+	for _, change := range changes {
+		pointer := change.Path.Pointer()
+		entry := diffEntry{
+			Kind:    change.Kind,
+			Path:    displayPath(change),
+			Pointer: pointer,
+			Old:     value.RevealSecrets(change.Old, redact),
+			New:     value.RevealSecrets(change.New, redact),
+		}
+		if change.Kind != diff.Added {
+			entry.OldLocation = sides[0].locate(pointer)
+		}
+		if change.Kind != diff.Removed {
+			entry.NewLocation = sides[1].locate(pointer)
+		}
+		entries = append(entries, entry)
+	}
+
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(data))
+	return nil
+}
+
+// displayPath returns the query path of a change, "." for the root
+func displayPath(change diff.Change) string {
+	if len(change.Path) == 0 {
+		return "."
+	}
+	return change.Path.String()
+}
+
+// showValue renders a changed value on one line, revealing or redacting its secrets
+func showValue(v interface{}, redact bool) string {
+	return compactJSON(value.RevealSecrets(v, redact))
+}
+
+// compactJSON renders a value on one line
+func compactJSON(v interface{}) string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprintf("%v", v)
+	}
+	return string(data)
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/tomdoesdev/brace/internal/diff"
+)
+
+func TestDiffEntryKeepsNull(t *testing.T) {
+	data, err := json.Marshal(diffEntry{Kind: diff.Changed, Path: "x", Pointer: "/x", Old: int64(1), New: nil})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `"old":1,"new":null`) {
+		t.Errorf("expected a change to null to keep \"new\", got %s", data)
+	}
+}
+
+func TestDiffRedactsChangedSecrets(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"old.brace":   "@brace \"1.0.0\"\npassword = @secret(\"db/password\")\n",
+		"new.brace":   "@brace \"1.0.0\"\npassword = @secret(\"db/new\")\n",
+		"db/password": "hunter2",
+		"db/new":      "swordfish",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, args := range [][]string{nil, {"-json"}} {
+		args = append(args, "-no-env", "-secrets-dir", dir, filepath.Join(dir, "old.brace"), filepath.Join(dir, "new.brace"))
+		var status int
+		output := captureStdout(t, func() { status = runDiff(args) })
+		if status != 1 {
+			t.Errorf("%v: expected exit status 1 for a changed secret, got %d:\n%s", args, status, output)
+		}
+		if strings.Contains(output, "hunter2") || strings.Contains(output, "swordfish") {
+			t.Errorf("%v: expected secrets to be redacted, got:\n%s", args, output)
+		}
+	}
+}
+
+// captureStdout returns what f prints to standard output
+func captureStdout(t *testing.T, f func()) string {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+
+	done := make(chan []byte)
+	go func() {
+		data, _ := io.ReadAll(r)
+		done <- data
+	}()
+	f()
+	w.Close()
+	return string(<-done)
+}
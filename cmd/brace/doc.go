@@ -0,0 +1,86 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/tomdoesdev/brace/internal/compiler"
+	"github.com/tomdoesdev/brace/internal/doc"
+)
+
+// runDoc implements `brace doc`: generate a reference page from a file's doc comments
+func runDoc(args []string) int {
+	fs := flag.NewFlagSet("doc", flag.ExitOnError)
+	formatName := fs.String("format", "markdown", "Output format: markdown, html or json")
+	outputFile := fs.String("o", "", "Write the page to this file instead of stdout")
+	flags := addCompileFlags(fs)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s doc [options] <file.brace>\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "Print a reference page listing every key, table and constant with its type,\n")
+		fmt.Fprintf(os.Stderr, "resolved value, @env default and description. Descriptions are the comments\n")
+		fmt.Fprintf(os.Stderr, "directly above a definition, or else a comment after it on the same line.\n")
+		fmt.Fprintf(os.Stderr, "Secrets are always redacted.\n\nOptions:\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return exitError
+	}
+	color, err := reportFlags(flags)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitError
+	}
+	filename := fs.Arg(0)
+
+	switch *formatName {
+	case "markdown", "md", "html", "json":
+	default:
+		fmt.Fprintf(os.Stderr, "Error: unsupported doc format %q (use markdown, html or json)\n", *formatName)
+		return exitError
+	}
+
+	source, err := readSourceFile(filename)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error %v\n", err)
+		return exitError
+	}
+
+	// Reference pages are meant to be shared, so secrets are always redacted
+	c := compiler.New(append(compilerOptions(flags), compiler.WithRedaction())...)
+	output, err := c.CompileValue(source, filename)
+	reportCompile(c, err, source, filename, flags, color)
+	if err != nil {
+		return exitError
+	}
+	ref := doc.Build(filename, c.Program(), c.SyntaxTree(), output, c.Analyzer().Constant)
+
+	var page string
+	switch *formatName {
+	case "html":
+		page = ref.HTML()
+	case "json":
+		data, err := json.MarshalIndent(ref, "", "  ")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return exitError
+		}
+		page = string(data) + "\n"
+	default:
+		page = ref.Markdown()
+	}
+
+	if *outputFile == "" {
+		fmt.Print(page)
+		return 0
+	}
+	if err := writeFileAtomic(*outputFile, []byte(page)); err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing %s: %v\n", *outputFile, err)
+		return exitError
+	}
+	return 0
+}
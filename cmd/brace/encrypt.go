@@ -0,0 +1,346 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/tomdoesdev/brace/internal/encrypt"
+	"github.com/tomdoesdev/brace/internal/lexer"
+	"github.com/tomdoesdev/brace/internal/token"
+)
+
+// runKeygen implements `brace keygen`: create a symmetric key or X25519 key pair
+func runKeygen(args []string) int {
+	fs := flag.NewFlagSet("keygen", flag.ExitOnError)
+	keyType := fs.String("type", "x25519", "Key type: x25519 or symmetric")
+	output := fs.String("o", "", "Key file to append to, or - for stdout (default: $BRACE_KEY_FILE or the user key file)")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s keygen [options]\n\nGenerate a key for @encrypted values.\n\nOptions:\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	var identity encrypt.Identity
+	var err error
+	switch *keyType {
+	case "x25519":
+		identity, err = encrypt.GenerateX25519Identity()
+	case "symmetric":
+		identity, err = encrypt.GenerateSymmetricKey()
+	default:
+		fmt.Fprintf(os.Stderr, "Error: Unsupported key type '%s'. Supported types: x25519, symmetric\n", *keyType)
+		return 1
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error generating key: %v\n", err)
+		return 1
+	}
+
+	var entry strings.Builder
+	entry.WriteString(fmt.Sprintf("# created: %s\n", time.Now().UTC().Format(time.RFC3339)))
+	if *keyType == "x25519" {
+		entry.WriteString(fmt.Sprintf("# recipient: %s\n", identity.Recipient()))
+	}
+	entry.WriteString(identity.String() + "\n")
+
+	path := *output
+	if path == "" {
+		path, err = encrypt.DefaultKeyFile()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error locating key file: %v\n", err)
+			return 1
+		}
+	}
+
+	if path == "-" {
+		fmt.Print(entry.String())
+		return 0
+	}
+
+	if err := appendKeyFile(path, entry.String()); err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing key file: %v\n", err)
+		return 1
+	}
+	fmt.Fprintf(os.Stderr, "Key written to %s\n", path)
+	if *keyType == "x25519" {
+		fmt.Println(identity.Recipient())
+	}
+	return 0
+}
+
+// appendKeyFile appends a key entry to a key file readable only by the user
+func appendKeyFile(path, entry string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	_, err = file.WriteString(entry)
+	return err
+}
+
+// runEncrypt implements `brace encrypt`: print an @encrypted literal for a value
+func runEncrypt(args []string) int {
+	fs := flag.NewFlagSet("encrypt", flag.ExitOnError)
+	var recipients, keyFiles stringList
+	fs.Var(&recipients, "r", "Encrypt to this X25519 recipient (repeatable)")
+	fs.Var(&keyFiles, "key", "Encrypt to every key in this key file (repeatable)")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s encrypt [options] [value]\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "Print an @encrypted(\"...\") literal for value (read from stdin if omitted).\n")
+		fmt.Fprintf(os.Stderr, "Without -r or -key, the value is encrypted to the default key file.\n\nOptions:\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	targets, err := loadRecipients(recipients, keyFiles)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error %v\n", err)
+		return 1
+	}
+
+	var plaintext string
+	if fs.NArg() > 0 {
+		plaintext = strings.Join(fs.Args(), " ")
+	} else {
+		data, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading stdin: %v\n", err)
+			return 1
+		}
+		plaintext = strings.TrimSuffix(string(data), "\n")
+	}
+
+	ciphertext, err := encrypt.Encrypt(plaintext, targets)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error encrypting value: %v\n", err)
+		return 1
+	}
+	fmt.Printf("@encrypted(\"%s\")\n", ciphertext)
+	return 0
+}
+
+// runDecrypt implements `brace decrypt`: print a file with @encrypted values replaced by plaintext
+func runDecrypt(args []string) int {
+	fs := flag.NewFlagSet("decrypt", flag.ExitOnError)
+	var identityFiles stringList
+	fs.Var(&identityFiles, "identity", "Key file to decrypt with (repeatable, default: the default key file)")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s decrypt [options] <file.brace>\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "Print file.brace with every @encrypted(\"...\") replaced by its plaintext string.\n\nOptions:\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return 1
+	}
+
+	identities, err := loadIdentities(identityFiles, true)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error %v\n", err)
+		return 1
+	}
+
+	filename := fs.Arg(0)
+	source, err := readSourceFile(filename)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error %v\n", err)
+		return 1
+	}
+
+	output, err := rewriteEncrypted(source, func(ciphertext string) (string, error) {
+		plaintext, err := encrypt.Decrypt(ciphertext, identities)
+		if err != nil {
+			return "", err
+		}
+		return quoteBraceString(plaintext)
+	}, true)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error %s: %v\n", filename, err)
+		return 1
+	}
+
+	fmt.Print(output)
+	return 0
+}
+
+// runRekey implements `brace rekey`: re-encrypt every @encrypted value in files to new recipients
+func runRekey(args []string) int {
+	fs := flag.NewFlagSet("rekey", flag.ExitOnError)
+	var identityFiles, recipients, keyFiles stringList
+	fs.Var(&identityFiles, "identity", "Key file to decrypt with (repeatable, default: the default key file)")
+	fs.Var(&recipients, "r", "New X25519 recipient (repeatable)")
+	fs.Var(&keyFiles, "key", "Encrypt to every key in this key file (repeatable)")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s rekey [options] <file.brace>...\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "Re-encrypt every @encrypted value in place for a new set of recipients.\n\nOptions:\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() == 0 || len(recipients)+len(keyFiles) == 0 {
+		fs.Usage()
+		return 1
+	}
+
+	identities, err := loadIdentities(identityFiles, true)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error %v\n", err)
+		return 1
+	}
+	targets, err := loadRecipients(recipients, keyFiles)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error %v\n", err)
+		return 1
+	}
+
+	status := 0
+	for _, filename := range fs.Args() {
+		source, err := readSourceFile(filename)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error %v\n", err)
+			status = 1
+			continue
+		}
+
+		output, err := rewriteEncrypted(source, func(ciphertext string) (string, error) {
+			rotated, err := encrypt.Rekey(ciphertext, identities, targets)
+			if err != nil {
+				return "", err
+			}
+			return "\"" + rotated + "\"", nil
+		}, false)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error %s: %v\n", filename, err)
+			status = 1
+			continue
+		}
+
+		if err := writeFileAtomic(filename, []byte(output)); err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing %s: %v\n", filename, err)
+			status = 1
+			continue
+		}
+		fmt.Fprintf(os.Stderr, "Rekeyed %s\n", filename)
+	}
+	return status
+}
+
+// loadRecipients parses -r recipients and the keys in -key files
+// With neither, the default key file is used
+func loadRecipients(recipients, keyFiles []string) ([]encrypt.Recipient, error) {
+	var targets []encrypt.Recipient
+	for _, r := range recipients {
+		recipient, err := encrypt.ParseRecipient(r)
+		if err != nil {
+			return nil, fmt.Errorf("recipient %q: %v", r, err)
+		}
+		targets = append(targets, recipient)
+	}
+
+	identities, err := loadIdentities(keyFiles, len(recipients) == 0)
+	if err != nil {
+		return nil, err
+	}
+	for _, identity := range identities {
+		targets = append(targets, identity.Recipient())
+	}
+
+	if len(targets) == 0 {
+		return nil, fmt.Errorf("no recipients: pass -r or -key, or create a key with brace keygen")
+	}
+	return targets, nil
+}
+
+// loadIdentities reads key files, falling back to the default key file when
+// none are given and useDefault is set
+func loadIdentities(keyFiles []string, useDefault bool) ([]encrypt.Identity, error) {
+	if len(keyFiles) == 0 && useDefault {
+		path, err := encrypt.DefaultKeyFile()
+		if err != nil {
+			return nil, err
+		}
+		if _, err := os.Stat(path); err != nil {
+			return nil, nil
+		}
+		keyFiles = []string{path}
+	}
+
+	var identities []encrypt.Identity
+	for _, path := range keyFiles {
+		loaded, err := encrypt.LoadIdentities(path)
+		if err != nil {
+			return nil, err
+		}
+		identities = append(identities, loaded...)
+	}
+	return identities, nil
+}
+
+// rewriteEncrypted finds every @encrypted("...") in source and replaces either the
+// whole directive (wholeDirective) or just its string argument with replace's result
+func rewriteEncrypted(source string, replace func(ciphertext string) (string, error), wholeDirective bool) (string, error) {
+	l := lexer.New(source)
+	var tokens []token.Token
+	for {
+		tok := l.NextToken()
+		if tok.Type == token.EOF {
+			break
+		}
+		if tok.Type != token.COMMENT {
+			tokens = append(tokens, tok)
+		}
+	}
+
+	var out strings.Builder
+	last := 0
+	for i := 0; i+4 < len(tokens); i++ {
+		at, name, lparen, str, rparen := tokens[i], tokens[i+1], tokens[i+2], tokens[i+3], tokens[i+4]
+		if at.Type != token.AT || name.Type != token.IDENT || name.Literal != "encrypted" ||
+			lparen.Type != token.LPAREN || str.Type != token.STRING || rparen.Type != token.RPAREN {
+			continue
+		}
+
+		replacement, err := replace(str.Literal)
+		if err != nil {
+			return "", fmt.Errorf("%d:%d: %v", at.Line, at.Column, err)
+		}
+
+		start, end := str.Position, str.Position+str.Length
+		if wholeDirective {
+			start, end = at.Position, rparen.Position+1
+		}
+		out.WriteString(source[last:start])
+		out.WriteString(replacement)
+		last = end
+		i += 4
+	}
+	out.WriteString(source[last:])
+
+	return out.String(), nil
+}
+
+// quoteBraceString renders s as a BRACE string literal
+// BRACE strings have no escape sequences, so the quoting style is chosen to fit the content
+func quoteBraceString(s string) (string, error) {
+	switch {
+	case !strings.ContainsAny(s, "\"\n"):
+		return "\"" + s + "\"", nil
+	case !strings.ContainsAny(s, "'\n"):
+		return "'" + s + "'", nil
+	case !strings.Contains(s, "\"\"\"") && !strings.HasSuffix(s, "\""):
+		return "\"\"\"" + s + "\"\"\"", nil
+	default:
+		return "", fmt.Errorf("value cannot be represented as a BRACE string literal")
+	}
+}
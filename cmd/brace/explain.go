@@ -0,0 +1,110 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/tomdoesdev/brace/internal/analyzer"
+	"github.com/tomdoesdev/brace/internal/compiler"
+	"github.com/tomdoesdev/brace/internal/query"
+)
+
+// runExplain implements `brace explain`: show where the value at a path came from
+func runExplain(args []string) int {
+	fs := flag.NewFlagSet("explain", flag.ExitOnError)
+	jsonOutput := fs.Bool("json", false, "Print the provenance as JSON")
+	flags := addCompileFlags(fs)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s explain [options] <file.brace> <path>\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "Print the value at path and the chain it came from: keys, references,\n")
+		fmt.Fprintf(os.Stderr, "constants, @env lookups (set or unset) and defaults, with source locations.\n\n")
+		fmt.Fprintf(os.Stderr, "Exit status: 0 found, 1 path not found, 2 compile or usage error\n\nOptions:\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() != 2 {
+		fs.Usage()
+		return exitError
+	}
+	color, err := reportFlags(flags)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitError
+	}
+	filename := fs.Arg(0)
+
+	path, err := query.Parse(fs.Arg(1))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitError
+	}
+
+	source, err := readSourceFile(filename)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error %v\n", err)
+		return exitError
+	}
+
+	c := compiler.New(compilerOptions(flags)...)
+	output, err := c.CompileValue(source, filename)
+	reportCompile(c, err, source, filename, flags, color)
+	if err != nil {
+		return exitError
+	}
+
+	values, err := query.Eval(output, path)
+	if errors.Is(err, query.ErrNotFound) {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitNotFound
+	}
+	var origin *analyzer.Origin
+	if err == nil {
+		origin, err = c.Analyzer().Explain(path)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitError
+	}
+	value := values[0]
+
+	if *jsonOutput {
+		data, err := json.MarshalIndent(map[string]interface{}{
+			"path":   path.String(),
+			"value":  value,
+			"file":   filename,
+			"origin": origin,
+		}, "", "  ")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return exitError
+		}
+		fmt.Println(string(data))
+		return 0
+	}
+
+	fmt.Printf("%s = %s\n", path, compactJSON(value))
+	printOrigin(origin, filename, "  ", "")
+	return 0
+}
+
+// printOrigin prints a provenance chain, one step per line, indenting branches
+func printOrigin(origin *analyzer.Origin, filename, indent, arrow string) {
+	line := indent + arrow + string(origin.Kind) + " " + origin.Description
+	if origin.Line > 0 {
+		line += fmt.Sprintf(" at %s:%d:%d", filename, origin.Line, origin.Column)
+	}
+	fmt.Println(line)
+
+	if len(origin.From) == 1 {
+		printOrigin(origin.From[0], filename, indent, "-> ")
+		return
+	}
+	for _, from := range origin.From {
+		printOrigin(from, filename, indent+strings.Repeat(" ", len(arrow))+"  ", "-> ")
+	}
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	braceerrors "github.com/tomdoesdev/brace/internal/errors"
+)
+
+// runExplainError implements `brace explain-error`: print the long-form
+// explanation of a diagnostic code, or list every code
+func runExplainError(args []string) int {
+	fs := flag.NewFlagSet("explain-error", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s explain-error [code]\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "Print the explanation of a diagnostic code such as E0101, with examples.\n")
+		fmt.Fprintf(os.Stderr, "Without a code, list every code.\n\n")
+		fmt.Fprintf(os.Stderr, "Exit status: 0 found, 1 unknown code, 2 usage error\n")
+	}
+	fs.Parse(args)
+
+	switch fs.NArg() {
+	case 0:
+		for _, code := range braceerrors.Codes() {
+			title, _ := braceerrors.Title(code)
+			fmt.Printf("%s  %s\n", code, title)
+		}
+		return 0
+	case 1:
+	default:
+		fs.Usage()
+		return exitError
+	}
+
+	code := strings.ToUpper(fs.Arg(0))
+	explanation, ok := braceerrors.Explain(code)
+	if !ok {
+		fmt.Fprintf(os.Stderr, "Error: unknown error code %s (run `%s explain-error` to list codes)\n", code, os.Args[0])
+		return exitNotFound
+	}
+	title, _ := braceerrors.Title(code)
+	fmt.Printf("%s: %s\n\n%s", code, title, explanation)
+	return 0
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"go/token"
+	"os"
+
+	"github.com/tomdoesdev/brace/internal/compiler"
+	"github.com/tomdoesdev/brace/internal/doc"
+	"github.com/tomdoesdev/brace/internal/gen"
+)
+
+// runGen implements `brace gen`: generate Go or TypeScript types for a file
+func runGen(args []string) int {
+	fs := flag.NewFlagSet("gen", flag.ExitOnError)
+	lang := fs.String("lang", "go", "Language to generate: go or ts")
+	pkg := fs.String("package", "config", "Package name of generated Go source")
+	typeName := fs.String("type", "Config", "Name of the root type")
+	constants := fs.Bool("consts", false, "Also declare @const values as constants")
+	outputFile := fs.String("o", "", "Write the source to this file instead of stdout")
+	flags := addCompileFlags(fs)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s gen [options] <file.brace>\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "Print Go structs or TypeScript interfaces for the compiled output of a file.\n")
+		fmt.Fprintf(os.Stderr, "Types are inferred from the values: tables and objects become named types,\n")
+		fmt.Fprintf(os.Stderr, "arrays take the type of their elements and doc comments become type docs.\n")
+		fmt.Fprintf(os.Stderr, "Go fields have json tags naming their keys, so the JSON output\n")
+		fmt.Fprintf(os.Stderr, "decodes into the root type with encoding/json.\n\nOptions:\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return exitError
+	}
+	color, err := reportFlags(flags)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitError
+	}
+	filename := fs.Arg(0)
+
+	if !token.IsIdentifier(*pkg) {
+		fmt.Fprintf(os.Stderr, "Error: -package %q is not a Go identifier\n", *pkg)
+		return exitError
+	}
+	if !token.IsIdentifier(*typeName) {
+		fmt.Fprintf(os.Stderr, "Error: -type %q is not an identifier\n", *typeName)
+		return exitError
+	}
+	opts := gen.Options{Lang: *lang, Package: *pkg, TypeName: *typeName, Constants: *constants}
+
+	source, err := readSourceFile(filename)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error %v\n", err)
+		return exitError
+	}
+
+	// Constants holding secrets are left out, and no secret reaches the source
+	c := compiler.New(append(compilerOptions(flags), compiler.WithRedaction())...)
+	output, err := c.CompileValue(source, filename)
+	reportCompile(c, err, source, filename, flags, color)
+	if err != nil {
+		return exitError
+	}
+	ref := doc.Build(filename, c.Program(), c.SyntaxTree(), output, c.Analyzer().Constant)
+	code, err := gen.Generate(gen.Infer(output, c.Locations(), ref, *typeName), opts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitError
+	}
+
+	if *outputFile == "" {
+		fmt.Print(code)
+		return 0
+	}
+	if err := writeFileAtomic(*outputFile, []byte(code)); err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing %s: %v\n", *outputFile, err)
+		return exitError
+	}
+	return 0
+}
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestGenRejectsInvalidNames(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "app.brace")
+	if err := os.WriteFile(filename, []byte("@brace \"1.0.0\"\nx = 1\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for _, args := range [][]string{
+		{"-package", "my-config"},
+		{"-package", ""},
+		{"-type", ""},
+		{"-type", "App Config"},
+	} {
+		if code := runGen(append(args, filename)); code != exitError {
+			t.Errorf("gen %v: expected exit status %d, got %d", args, exitError, code)
+		}
+	}
+	if code := runGen([]string{"-lang", "rust", filename}); code != exitError {
+		t.Errorf("expected an unsupported language to fail, got exit status %d", code)
+	}
+}
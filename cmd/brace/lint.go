@@ -0,0 +1,125 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/tomdoesdev/brace/internal/compiler"
+	braceerrors "github.com/tomdoesdev/brace/internal/errors"
+	"github.com/tomdoesdev/brace/internal/lint"
+)
+
+// runLint implements `brace lint`: report warnings and style problems in files
+func runLint(args []string) int {
+	fs := flag.NewFlagSet("lint", flag.ExitOnError)
+	configPath := fs.String("config", "", "Lint config file (default: nearest "+lint.ConfigFile+" above each file)")
+	diagnosticsFormat := fs.String("diagnostics", "text", "Report format: text, json or sarif")
+	listRules := fs.Bool("rules", false, "List the lint rules and their default severities")
+	colorMode := fs.String("color", "auto", "Color text reports: auto, always or never")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s lint [options] <file.brace>...\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "Check files for likely mistakes and style problems without compiling them.\n")
+		fmt.Fprintf(os.Stderr, "Severities are set per rule in %s as `rule = error|warning|note|off`.\n", lint.ConfigFile)
+		fmt.Fprintf(os.Stderr, "A `// brace:ignore rule` comment suppresses a rule on its line, or on the\n")
+		fmt.Fprintf(os.Stderr, "next line when it stands alone; `// brace:library` marks a library file.\n\n")
+		fmt.Fprintf(os.Stderr, "Exit status: 0 no errors, 1 errors found, 2 usage or config error\n\nOptions:\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if *listRules {
+		for _, rule := range lint.Rules() {
+			fmt.Printf("%-20s %-8s %s\n", rule.Name, rule.Severity, rule.Description)
+		}
+		return 0
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return exitError
+	}
+	switch *diagnosticsFormat {
+	case "text", "json", "sarif":
+	default:
+		fmt.Fprintf(os.Stderr, "Error: unsupported diagnostics format %q (supported: text, json, sarif)\n", *diagnosticsFormat)
+		return exitError
+	}
+
+	color, err := colorEnabled(*colorMode, os.Stdout)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitError
+	}
+
+	var explicit *lint.Config
+	if *configPath != "" {
+		config, err := lint.LoadConfig(*configPath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return exitError
+		}
+		explicit = &config
+	}
+
+	c := compiler.New()
+	var all []braceerrors.Diagnostic
+	errorCount, warningCount := 0, 0
+	for _, filename := range fs.Args() {
+		config := lint.Config{}
+		if explicit != nil {
+			config = *explicit
+		} else if path, ok := lint.FindConfig(filepath.Dir(filename)); ok {
+			loaded, err := lint.LoadConfig(path)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				return exitError
+			}
+			config = loaded
+		}
+
+		source, err := readSourceFile(filename)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error %v\n", err)
+			return exitError
+		}
+
+		// Files that do not parse report their parse errors instead of findings
+		program, err := c.Parse(source, filename)
+		diagnostics := c.Diagnostics()
+		if err == nil {
+			diagnostics = lint.Run(filename, source, program, config)
+		}
+		for _, d := range diagnostics {
+			switch d.Severity {
+			case braceerrors.SeverityError:
+				errorCount++
+			case braceerrors.SeverityWarning:
+				warningCount++
+			}
+		}
+
+		if *diagnosticsFormat == "text" {
+			reporter := braceerrors.NewErrorReporter(source, filename)
+			reporter.SetColor(color)
+			for _, d := range diagnostics {
+				fmt.Print(reporter.ReportDiagnostic(d))
+				fmt.Println()
+			}
+		}
+		all = append(all, diagnostics...)
+	}
+
+	if *diagnosticsFormat == "text" {
+		if len(all) > 0 {
+			fmt.Printf("%d errors, %d warnings\n", errorCount, warningCount)
+		}
+	} else {
+		writeDiagnostics(os.Stdout, all, *diagnosticsFormat)
+	}
+
+	if errorCount > 0 {
+		return 1
+	}
+	return 0
+}
@@ -1,26 +1,146 @@
 package main
 
 import (
+	"encoding/json"
 	"flag"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
+	"text/tabwriter"
+	"time"
 
 	"github.com/tomdoesdev/brace/internal/compiler"
+	"github.com/tomdoesdev/brace/internal/encrypt"
+	"github.com/tomdoesdev/brace/internal/env"
+	braceerrors "github.com/tomdoesdev/brace/internal/errors"
+	"github.com/tomdoesdev/brace/internal/secret"
 	"github.com/tomdoesdev/brace/internal/transform"
 )
 
-func setupFlags() (*string, *string, *bool, *bool) {
-	outputFormat := flag.String("format", "json", "Output format: json or yaml")
-	outputFile := flag.String("output", "", "Output file (default: stdout)")
-	showHelp := flag.Bool("help", false, "Show help")
-	showVersion := flag.Bool("version", false, "Show version")
+// version is the compiler version reported by -version and in SARIF logs
+const version = "1.0.0"
+
+// command is a brace subcommand; it returns the process exit status
+type command struct {
+	run     func(args []string) int
+	summary string
+}
+
+// commands are the subcommands of the brace CLI; without one, brace compiles a file
+var commands = map[string]command{
+	"build":         {runBuild, "Compile directories of .brace files into an output tree"},
+	"diff":          {runDiff, "Compare the compiled values of two files"},
+	"doc":           {runDoc, "Generate a Markdown or HTML reference from doc comments"},
+	"gen":           {runGen, "Generate Go or TypeScript types for a file"},
+	"explain":       {runExplain, "Show where the value at a path came from"},
+	"explain-error": {runExplainError, "Explain a diagnostic code such as E0101"},
+	"lint":          {runLint, "Check files for likely mistakes and style problems"},
+	"migrate":       {runMigrate, "Upgrade files to the newest BRACE version"},
+	"get":           {runGet, "Print the value at a path in a compiled file"},
+	"keygen":        {runKeygen, "Generate a key for @encrypted values"},
+	"encrypt":       {runEncrypt, "Print an @encrypted literal for a value"},
+	"decrypt":       {runDecrypt, "Print a file with @encrypted values decrypted"},
+	"query":         {runGet, "Alias for get"},
+	"rekey":         {runRekey, "Re-encrypt @encrypted values for new recipients"},
+}
+
+// stringList is a flag.Value collecting every occurrence of a repeatable flag
+type stringList []string
+
+func (s *stringList) String() string { return strings.Join(*s, ",") }
+
+func (s *stringList) Set(value string) error {
+	*s = append(*s, value)
+	return nil
+}
+
+// compileFlags holds the flags that configure the compiler itself
+// They are shared by every command that compiles files
+type compileFlags struct {
+	fileRoot   *string
+	envFiles   stringList
+	envVars    stringList
+	noEnv      *bool
+	envAllow   stringList
+	envDeny    stringList
+	secretsDir *string
+	secretsEnv *bool
+	redact     *bool
+	identities stringList
+	allowEnc   *bool
+
+	diagnostics *string // error report format: text, json or sarif
+	color       *string // color text error reports: auto, always or never
+}
+
+// addCompileFlags registers the compiler flags on fs
+func addCompileFlags(fs *flag.FlagSet) *compileFlags {
+	flags := &compileFlags{
+		fileRoot:   fs.String("file-root", "", "Directory @file paths must stay inside (default: the input file's directory)"),
+		noEnv:      fs.Bool("no-env", false, "Do not read the process environment (hermetic compile)"),
+		secretsDir: fs.String("secrets-dir", "", "Resolve @secret(\"a/b\") from the file a/b in this directory"),
+		secretsEnv: fs.Bool("secrets-env", false, "Resolve @secret(\"a/b\") from the "+secret.DefaultEnvPrefix+"A_B environment variable"),
+		redact:     fs.Bool("redact", false, "Replace secret values with "+secret.Redacted+" in the output"),
+		allowEnc:   fs.Bool("allow-encrypted", false, "Emit "+encrypt.Placeholder+" for @encrypted values that cannot be decrypted"),
+
+		diagnostics: fs.String("diagnostics", "text", "Error output format: text, json or sarif (json and sarif are always written to stderr, even on success)"),
+		color:       fs.String("color", "auto", "Color text error reports: auto, always or never (auto colors a terminal unless NO_COLOR is set)"),
+	}
+	fs.Var(&flags.identities, "identity", "Key file for @encrypted values (repeatable, default: $BRACE_KEY_FILE or the user key file)")
+	fs.Var(&flags.envFiles, "env-file", "Read environment variables from a .env file (repeatable, later files win)")
+	fs.Var(&flags.envVars, "env", "Set an environment variable as KEY=VALUE (repeatable, overrides -env-file)")
+	fs.Var(&flags.envAllow, "env-allow", "Only allow @env to read variables matching this glob pattern (repeatable)")
+	fs.Var(&flags.envDeny, "env-deny", "Deny @env access to variables matching this glob pattern (repeatable)")
+	return flags
+}
+
+// options holds the parsed command line flags
+type options struct {
+	*compileFlags
+	outputFormat *string
+	outputFile   *string
+	showHelp     *bool
+	showVersion  *bool
+	auditEnv     *bool
+	watch        *bool
+	watchEvery   *time.Duration
+	stdinName    *string
+	baseDir      *string
+	sourceMap    *string
+}
+
+func setupFlags() *options {
+	opts := &options{
+		compileFlags: addCompileFlags(flag.CommandLine),
+		outputFormat: flag.String("format", "json", "Output format: json or yaml"),
+		outputFile:   flag.String("output", "", "Output file (default: stdout)"),
+		showHelp:     flag.Bool("help", false, "Show help"),
+		showVersion:  flag.Bool("version", false, "Show version"),
+		auditEnv:     flag.Bool("audit-env", false, "List the environment variables the file reads instead of compiling it"),
+		watch:        flag.Bool("watch", false, "Recompile whenever the input file or a file it embeds changes"),
+		watchEvery:   flag.Duration("watch-interval", 500*time.Millisecond, "How often -watch checks files for changes"),
+		stdinName:    flag.String("stdin-filename", "", "Name used for stdin input in error messages (default: <stdin>)"),
+		sourceMap:    flag.String("sourcemap", "", "Write a source map from JSON pointers in the output to source positions to this file"),
+		baseDir:      flag.String("base-dir", "", "Directory relative @file paths resolve against (default: the input file's directory, or the working directory for stdin)"),
+	}
 
 	flag.Usage = func() {
-		fmt.Fprintf(os.Stderr, "Usage: %s [options] <file.brace>\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "Usage: %s [options] <file.brace | ->\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "       %s <command> [options] [args]\n\n", os.Args[0])
 		fmt.Fprintf(os.Stderr, "Compile BRACE configuration files to JSON or YAML.\n\n")
+		fmt.Fprintf(os.Stderr, "Commands:\n")
+		names := make([]string, 0, len(commands))
+		for name := range commands {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			fmt.Fprintf(os.Stderr, "  %-14s %s\n", name, commands[name].summary)
+		}
+		fmt.Fprintf(os.Stderr, "\n")
 		fmt.Fprintf(os.Stderr, "Options:\n")
 		flag.PrintDefaults()
 		fmt.Fprintf(os.Stderr, "\nExamples:\n")
@@ -28,9 +148,18 @@ func setupFlags() (*string, *string, *bool, *bool) {
 		fmt.Fprintf(os.Stderr, "  %s -format=yaml config.brace       # Output YAML to stdout\n", os.Args[0])
 		fmt.Fprintf(os.Stderr, "  %s -output=config.json config.brace # Output JSON to file\n", os.Args[0])
 		fmt.Fprintf(os.Stderr, "  %s -format=yaml -output=config.yaml config.brace # Output YAML to file\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s -no-env -env-file=prod.env -env PORT=80 config.brace # Hermetic compile\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  gen | %s -stdin-filename=gen.brace - > out.json # Compile from stdin\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s -output=config.json -sourcemap=config.map.json config.brace # Map output paths to source\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s -watch -output=config.json config.brace # Recompile on change\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s -audit-env -env-deny='*_SECRET' config.brace # List environment variables read\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s get config.brace database.port  # Print a single value\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s diff old.brace new.brace       # Show changed values\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s explain config.brace database.host # Show where a value came from\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s build -out=dist -rule='k8s/*=yaml' configs # Compile a directory tree\n", os.Args[0])
 	}
 
-	return outputFormat, outputFile, showHelp, showVersion
+	return opts
 }
 
 func handleFlags(showHelp, showVersion *bool) string {
@@ -42,11 +171,14 @@ func handleFlags(showHelp, showVersion *bool) string {
 	}
 
 	if *showVersion {
-		fmt.Println("brace compiler version 1.0.0")
+		fmt.Printf("brace compiler version %s\n", version)
 		os.Exit(0)
 	}
 
 	if len(flag.Args()) < 1 {
+		if stdinIsPiped() {
+			return "-"
+		}
 		fmt.Fprintf(os.Stderr, "Error: No input file specified\n\n")
 		flag.Usage()
 		os.Exit(1)
@@ -55,15 +187,28 @@ func handleFlags(showHelp, showVersion *bool) string {
 	return flag.Args()[0]
 }
 
-func determineOutputFormat(outputFormat, outputFile *string) transform.OutputFormat {
-	var format transform.OutputFormat
-	switch strings.ToLower(*outputFormat) {
+// stdinIsPiped reports whether stdin is a pipe or file rather than a terminal
+func stdinIsPiped() bool {
+	info, err := os.Stdin.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice == 0
+}
+
+// parseOutputFormat converts a format name to an output format
+func parseOutputFormat(name string) (transform.OutputFormat, error) {
+	switch strings.ToLower(name) {
 	case "json":
-		format = transform.FormatJSON
+		return transform.FormatJSON, nil
 	case "yaml", "yml":
-		format = transform.FormatYAML
+		return transform.FormatYAML, nil
 	default:
-		fmt.Fprintf(os.Stderr, "Error: Unsupported output format '%s'. Supported formats: json, yaml\n", *outputFormat)
+		return "", fmt.Errorf("unsupported output format '%s'. Supported formats: json, yaml", name)
+	}
+}
+
+func determineOutputFormat(outputFormat, outputFile *string) transform.OutputFormat {
+	format, err := parseOutputFormat(*outputFormat)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		os.Exit(1)
 	}
 
@@ -93,6 +238,42 @@ func readSourceFile(filename string) (string, error) {
 	return string(source), nil
 }
 
+func readStdin() (string, error) {
+	source, err := io.ReadAll(os.Stdin)
+	if err != nil {
+		return "", fmt.Errorf("reading stdin: %v", err)
+	}
+	return string(source), nil
+}
+
+// writeFileAtomic replaces path with data via a temporary file and rename
+func writeFileAtomic(path string, data []byte) error {
+	info, err := os.Stat(path)
+	mode := os.FileMode(0644)
+	if err == nil {
+		mode = info.Mode().Perm()
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(mode); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
 func writeOutput(output, outputFile string) {
 	if outputFile != "" {
 		err := os.WriteFile(outputFile, []byte(output), 0644)
@@ -106,23 +287,289 @@ func writeOutput(output, outputFile string) {
 	}
 }
 
+// envProvider builds the @env provider from -env, -env-file and -no-env
+// Precedence: -env flags, then -env-file files (last file first), then the process environment
+func envProvider(opts *compileFlags) (env.Provider, error) {
+	var layers env.Layered
+
+	if len(opts.envVars) > 0 {
+		vars := env.Map{}
+		for _, assignment := range opts.envVars {
+			key, value, ok := strings.Cut(assignment, "=")
+			if !ok || key == "" {
+				return nil, fmt.Errorf("invalid -env value %q (expected KEY=VALUE)", assignment)
+			}
+			vars[key] = value
+		}
+		layers = append(layers, vars)
+	}
+
+	for i := len(opts.envFiles) - 1; i >= 0; i-- {
+		vars, err := env.LoadFile(opts.envFiles[i])
+		if err != nil {
+			return nil, err
+		}
+		layers = append(layers, vars)
+	}
+
+	if !*opts.noEnv {
+		layers = append(layers, env.OS{})
+	}
+
+	return layers, nil
+}
+
+// compilerOptions converts command line flags into compiler options
+func compilerOptions(opts *compileFlags) []compiler.Option {
+	var compilerOpts []compiler.Option
+	if *opts.fileRoot != "" {
+		compilerOpts = append(compilerOpts, compiler.WithFileRoot(*opts.fileRoot))
+	}
+
+	provider, err := envProvider(opts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error %v\n", err)
+		os.Exit(1)
+	}
+	compilerOpts = append(compilerOpts, compiler.WithEnvProvider(provider))
+
+	var secrets secret.Chain
+	if *opts.secretsDir != "" {
+		secrets = append(secrets, secret.Dir(*opts.secretsDir))
+	}
+	if *opts.secretsEnv {
+		secrets = append(secrets, secret.Env{Provider: provider, Prefix: secret.DefaultEnvPrefix})
+	}
+	if len(secrets) > 0 {
+		compilerOpts = append(compilerOpts, compiler.WithSecretProvider(secrets))
+	}
+	if *opts.redact {
+		compilerOpts = append(compilerOpts, compiler.WithRedaction())
+	}
+
+	identityFiles := opts.identities
+	compilerOpts = append(compilerOpts, compiler.WithIdentityLoader(func() ([]encrypt.Identity, error) {
+		return loadIdentities(identityFiles, true)
+	}))
+	if *opts.allowEnc {
+		compilerOpts = append(compilerOpts, compiler.WithAllowEncrypted())
+	}
+
+	if len(opts.envAllow) > 0 || len(opts.envDeny) > 0 {
+		compilerOpts = append(compilerOpts, compiler.WithEnvPolicy(env.Policy{
+			Allow: opts.envAllow,
+			Deny:  opts.envDeny,
+		}))
+	}
+
+	return compilerOpts
+}
+
+// auditEnv prints every environment variable the file reads and exits
+// It exits with status 1 if the file cannot be parsed or any access violates the policy
+func auditEnv(c *compiler.Compiler, source, filename string) {
+	accesses, err := c.AuditEnv(source, filename)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Audit error:\n%s\n", err)
+		os.Exit(1)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "VARIABLE\tLOCATION\tLOOKUP\tSTATUS")
+	violations := 0
+	for _, access := range accesses {
+		lookup := "@env"
+		if access.Type != "" {
+			lookup += "." + access.Type
+		}
+		if access.HasDefault {
+			lookup += " (default)"
+		}
+		status := "allowed"
+		if access.Violation != "" {
+			status = access.Violation
+			violations++
+		}
+		fmt.Fprintf(w, "%s\t%s:%d:%d\t%s\t%s\n", access.Name, filename, access.Line, access.Column, lookup, status)
+	}
+	w.Flush()
+
+	if violations > 0 {
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
+
 func main() {
-	outputFormat, outputFile, showHelp, showVersion := setupFlags()
-	filename := handleFlags(showHelp, showVersion)
-	format := determineOutputFormat(outputFormat, outputFile)
+	if len(os.Args) > 1 {
+		if cmd, ok := commands[os.Args[1]]; ok {
+			os.Exit(cmd.run(os.Args[2:]))
+		}
+	}
+
+	opts := setupFlags()
+	filename := handleFlags(opts.showHelp, opts.showVersion)
+	color, err := reportFlags(opts.compileFlags)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	format := determineOutputFormat(opts.outputFormat, opts.outputFile)
+
+	compileOpts := compilerOptions(opts.compileFlags)
+	if *opts.baseDir != "" {
+		compileOpts = append(compileOpts, compiler.WithBaseDir(*opts.baseDir))
+	}
+
+	if *opts.sourceMap != "" {
+		compileOpts = append(compileOpts, compiler.WithSourceMap())
+	}
 
-	source, err := readSourceFile(filename)
+	var source string
+	if filename == "-" {
+		if *opts.watch {
+			fmt.Fprintf(os.Stderr, "Error: -watch cannot be used with stdin input\n")
+			os.Exit(1)
+		}
+		source, err = readStdin()
+		filename = "<stdin>"
+		if *opts.stdinName != "" {
+			filename = *opts.stdinName
+		}
+		if *opts.baseDir == "" {
+			// Resolve @file against the working directory rather than the
+			// directory of a -stdin-filename that may not exist
+			compileOpts = append(compileOpts, compiler.WithBaseDir("."))
+		}
+	} else {
+		if *opts.watch {
+			if *opts.auditEnv {
+				fmt.Fprintf(os.Stderr, "Error: -watch cannot be used with -audit-env\n")
+				os.Exit(1)
+			}
+			c := compiler.NewWithFormat(format, compileOpts...)
+			watchFiles(c, filename, watchConfig{
+				outputFile:  *opts.outputFile,
+				sourceMap:   *opts.sourceMap,
+				diagnostics: *opts.diagnostics,
+				interval:    *opts.watchEvery,
+				color:       color,
+			})
+		}
+		source, err = readSourceFile(filename)
+	}
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error %v\n", err)
 		os.Exit(1)
 	}
 
-	c := compiler.NewWithFormat(format)
+	c := compiler.NewWithFormat(format, compileOpts...)
+	if *opts.auditEnv {
+		auditEnv(c, source, filename)
+	}
 	output, err := c.CompileFile(source, filename)
+	reportCompile(c, err, source, filename, opts.compileFlags, color)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Compilation error:\n%s\n", err)
 		os.Exit(1)
 	}
 
-	writeOutput(output, *outputFile)
+	writeOutput(output, *opts.outputFile)
+	if *opts.sourceMap != "" {
+		if err := writeSourceMap(c.SourceMap(), *opts.sourceMap); err != nil {
+			fmt.Fprintf(os.Stderr, "Error %v\n", err)
+			os.Exit(1)
+		}
+	}
+}
+
+// reportFlags validates -diagnostics and -color, and reports whether text
+// error reports on stderr should be colored
+func reportFlags(opts *compileFlags) (bool, error) {
+	switch *opts.diagnostics {
+	case "text", "json", "sarif":
+	default:
+		return false, fmt.Errorf("unsupported diagnostics format '%s'. Supported formats: text, json, sarif", *opts.diagnostics)
+	}
+	return colorEnabled(*opts.color, os.Stderr)
+}
+
+// reportCompile writes the diagnostics of a compilation to stderr in the
+// -diagnostics format; for text, only a failed compilation is reported
+func reportCompile(c *compiler.Compiler, err error, source, filename string, opts *compileFlags, color bool) {
+	if *opts.diagnostics != "text" {
+		writeDiagnostics(os.Stderr, c.Diagnostics(), *opts.diagnostics)
+		return
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Compilation error:\n%s\n", errorReport(c, err, source, filename, color))
+	}
+}
+
+// errorReport formats a compilation error as Rust-style reports of its
+// diagnostics, with their help and notes, falling back to the error text
+// for errors without a position
+func errorReport(c *compiler.Compiler, err error, source, filename string, color bool) string {
+	diagnostics := c.Diagnostics()
+	if len(diagnostics) == 0 {
+		return err.Error()
+	}
+	for _, d := range diagnostics {
+		if d.Range.IsZero() {
+			return err.Error()
+		}
+	}
+	reporter := braceerrors.NewErrorReporter(source, filename)
+	reporter.SetColor(color)
+	return reporter.ReportDiagnostics(diagnostics)
+}
+
+// colorEnabled reports whether text reports written to f should be colored
+// for a -color mode; auto colors terminals unless NO_COLOR is set or TERM is dumb
+func colorEnabled(mode string, f *os.File) (bool, error) {
+	switch mode {
+	case "always":
+		return true, nil
+	case "never":
+		return false, nil
+	case "auto":
+		if os.Getenv("NO_COLOR") != "" || os.Getenv("TERM") == "dumb" {
+			return false, nil
+		}
+		info, err := f.Stat()
+		return err == nil && info.Mode()&os.ModeCharDevice != 0, nil
+	default:
+		return false, fmt.Errorf("unsupported color mode %q (supported: auto, always, never)", mode)
+	}
+}
+
+// writeDiagnostics writes diagnostics to w as JSON or SARIF
+func writeDiagnostics(w io.Writer, diagnostics []braceerrors.Diagnostic, format string) {
+	if diagnostics == nil {
+		diagnostics = []braceerrors.Diagnostic{}
+	}
+
+	var data []byte
+	var err error
+	if format == "sarif" {
+		data, err = braceerrors.SARIF(diagnostics, version)
+	} else {
+		data, err = json.MarshalIndent(diagnostics, "", "  ")
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error encoding diagnostics: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Fprintln(w, string(data))
+}
+
+// writeSourceMap writes a source map as JSON
+func writeSourceMap(sm *compiler.SourceMap, path string) error {
+	data, err := json.MarshalIndent(sm, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encoding source map: %w", err)
+	}
+	if err := writeFileAtomic(path, append(data, '\n')); err != nil {
+		return fmt.Errorf("writing source map: %w", err)
+	}
+	return nil
 }
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/tomdoesdev/brace/internal/migrate"
+	langversion "github.com/tomdoesdev/brace/internal/version"
+)
+
+func runMigrate(args []string) int {
+	fs := flag.NewFlagSet("migrate", flag.ExitOnError)
+	write := fs.Bool("w", false, "Write the migrated source back to each file instead of printing it")
+	check := fs.Bool("check", false, "List files that need migrating without changing them (exit 1 if any)")
+	features := fs.Bool("features", false, "Print the language features of each version and exit")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s migrate [options] <file.brace>...\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "Upgrade files to BRACE %s, rewriting code whose meaning changed.\n\nOptions:\n", langversion.Latest())
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if *features {
+		printFeatures()
+		return 0
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return exitError
+	}
+
+	status := 0
+	for _, filename := range fs.Args() {
+		source, err := readSourceFile(filename)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error %v\n", err)
+			status = exitError
+			continue
+		}
+
+		result, err := migrate.Migrate(source)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error %s: %v\n", filename, err)
+			status = exitError
+			continue
+		}
+
+		if *check {
+			if result.Changed() {
+				fmt.Printf("%s: BRACE %s can be migrated to %s\n", filename, result.From, result.To)
+				if status == 0 {
+					status = 1
+				}
+			}
+			continue
+		}
+
+		for _, change := range result.Changes {
+			fmt.Fprintf(os.Stderr, "%s:%d:%d: %s\n", filename, change.Line, change.Column, change.Message)
+		}
+		for _, warning := range result.Warnings {
+			fmt.Fprintf(os.Stderr, "%s:%d:%d: warning: %s\n", filename, warning.Line, warning.Column, warning.Message)
+		}
+
+		if !*write {
+			fmt.Print(result.Source)
+			continue
+		}
+		if !result.Changed() {
+			continue
+		}
+		if err := writeFileAtomic(filename, []byte(result.Source)); err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing %s: %v\n", filename, err)
+			status = exitError
+			continue
+		}
+		fmt.Fprintf(os.Stderr, "Migrated %s from BRACE %s to %s\n", filename, result.From, result.To)
+	}
+	return status
+}
+
+// printFeatures prints the version-dependent language features
+func printFeatures() {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "FEATURE\tSINCE\tUNTIL\tDESCRIPTION")
+	for _, f := range langversion.Features() {
+		until := "-"
+		if !f.Until.IsZero() {
+			until = f.Until.String()
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", f.Name, f.Since, until, f.Description)
+	}
+	w.Flush()
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/tomdoesdev/brace/internal/compiler"
+	"github.com/tomdoesdev/brace/internal/query"
+	"github.com/tomdoesdev/brace/internal/transform"
+)
+
+// Exit codes for `brace get`, following grep: 1 means no match, 2 means an error
+const (
+	exitNotFound = 1
+	exitError    = 2
+)
+
+// runGet implements `brace get` (alias `brace query`): print the value at a path
+func runGet(args []string) int {
+	fs := flag.NewFlagSet("get", flag.ExitOnError)
+	formatName := fs.String("format", "json", "Format for objects and arrays: json or yaml")
+	stdinName := fs.String("stdin-filename", "", "Name used for stdin input in error messages (default: <stdin>)")
+	flags := addCompileFlags(fs)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s get [options] <file.brace | -> <path>\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "Print the value at path in the compiled file. Strings, numbers and booleans\n")
+		fmt.Fprintf(os.Stderr, "are printed raw; objects and arrays in -format. A path with wildcards prints\n")
+		fmt.Fprintf(os.Stderr, "one result per line.\n\n")
+		fmt.Fprintf(os.Stderr, "Paths: database.port, servers[0].host, servers[-1], servers[*].host,\n")
+		fmt.Fprintf(os.Stderr, "       features.*, labels.\"app.kubernetes.io/name\", . (whole document)\n\n")
+		fmt.Fprintf(os.Stderr, "Exit status: 0 found, 1 path not found, 2 compile or usage error\n\nOptions:\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() != 2 {
+		fs.Usage()
+		return exitError
+	}
+	filename, pathExpr := fs.Arg(0), fs.Arg(1)
+
+	color, err := reportFlags(flags)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitError
+	}
+	format, err := parseOutputFormat(*formatName)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitError
+	}
+	path, err := query.Parse(pathExpr)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitError
+	}
+
+	opts := compilerOptions(flags)
+	var source string
+	if filename == "-" {
+		source, err = readStdin()
+		filename = "<stdin>"
+		if *stdinName != "" {
+			filename = *stdinName
+		}
+		opts = append(opts, compiler.WithBaseDir("."))
+	} else {
+		source, err = readSourceFile(filename)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error %v\n", err)
+		return exitError
+	}
+
+	c := compiler.New(opts...)
+	root, err := c.CompileValue(source, filename)
+	reportCompile(c, err, source, filename, flags, color)
+	if err != nil {
+		return exitError
+	}
+
+	results, err := query.Eval(root, path)
+	if errors.Is(err, query.ErrNotFound) {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitNotFound
+	}
+
+	t := transform.NewWithFormat(format)
+	for _, result := range results {
+		text, err := formatQueryResult(t, result)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return exitError
+		}
+		fmt.Println(strings.TrimSuffix(text, "\n"))
+	}
+	return 0
+}
+
+// formatQueryResult prints scalars raw so they can be used directly in shell scripts
+func formatQueryResult(t *transform.Transform, value interface{}) (string, error) {
+	switch v := value.(type) {
+	case nil:
+		return "null", nil
+	case string:
+		return v, nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case int64:
+		return strconv.FormatInt(v, 10), nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	default:
+		return t.Encode(v)
+	}
+}
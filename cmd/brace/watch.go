@@ -0,0 +1,134 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/tomdoesdev/brace/internal/compiler"
+)
+
+// fileState is the part of a file's metadata used to detect changes
+type fileState struct {
+	exists  bool
+	size    int64
+	modTime time.Time
+}
+
+// watchConfig holds the flags that shape each watch-mode compilation
+type watchConfig struct {
+	outputFile  string
+	sourceMap   string // write the source map here after each successful compilation
+	diagnostics string // text, json or sarif
+	interval    time.Duration
+	color       bool
+}
+
+// watchFiles polls the input file and every file it embeds, recompiling whenever one
+// changes; the output is only rewritten when the compiled result differs
+// Compilation errors are reported and watching continues; watch never returns
+func watchFiles(c *compiler.Compiler, filename string, config watchConfig) {
+	var lastOutput string
+	var hasOutput bool
+
+	// If the output file already exists, only rewrite it when the content changes
+	if config.outputFile != "" {
+		if existing, err := os.ReadFile(config.outputFile); err == nil {
+			lastOutput, hasOutput = string(existing), true
+		}
+	}
+
+	watched := []string{filename}
+	var states map[string]fileState
+
+	for {
+		current := statFiles(watched)
+		if states == nil || !sameStates(states, current) {
+			watched = compileForWatch(c, filename, config, &lastOutput, &hasOutput)
+			states = statFiles(watched)
+		}
+		time.Sleep(config.interval)
+	}
+}
+
+// compileForWatch runs a single watch-mode compilation and returns the files to watch next
+func compileForWatch(c *compiler.Compiler, filename string, config watchConfig, lastOutput *string, hasOutput *bool) []string {
+	timestamp := time.Now().Format("15:04:05")
+	watched := []string{filename}
+
+	source, err := readSourceFile(filename)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "[%s] Error %v\n", timestamp, err)
+		return watched
+	}
+
+	output, err := c.CompileFile(source, filename)
+	watched = append(watched, c.Dependencies()...)
+	if config.diagnostics != "text" {
+		writeDiagnostics(os.Stderr, c.Diagnostics(), config.diagnostics)
+	}
+	if err != nil {
+		if config.diagnostics == "text" {
+			fmt.Fprintf(os.Stderr, "[%s] Compilation error:\n%s\n", timestamp, errorReport(c, err, source, filename, config.color))
+		}
+		return watched
+	}
+
+	// The source map follows every compilation, as locations can move without changing the output
+	if config.sourceMap != "" {
+		if err := writeSourceMap(c.SourceMap(), config.sourceMap); err != nil {
+			fmt.Fprintf(os.Stderr, "[%s] Error %v\n", timestamp, err)
+		}
+	}
+
+	if *hasOutput && output == *lastOutput {
+		fmt.Fprintf(os.Stderr, "[%s] Compiled %s (output unchanged)\n", timestamp, filename)
+		return watched
+	}
+
+	if config.outputFile == "" {
+		writeOutput(output, "")
+	} else if err := writeFileAtomic(config.outputFile, []byte(output)); err != nil {
+		fmt.Fprintf(os.Stderr, "[%s] Error writing output file: %v\n", timestamp, err)
+		return watched
+	}
+
+	*lastOutput, *hasOutput = output, true
+	if config.outputFile != "" {
+		fmt.Fprintf(os.Stderr, "[%s] Compiled %s -> %s\n", timestamp, filename, config.outputFile)
+	}
+	return watched
+}
+
+// statFiles records the current state of each file
+func statFiles(paths []string) map[string]fileState {
+	states := make(map[string]fileState, len(paths))
+	for _, path := range paths {
+		abs, err := filepath.Abs(path)
+		if err != nil {
+			abs = path
+		}
+		info, err := os.Stat(abs)
+		if err != nil {
+			states[abs] = fileState{}
+			continue
+		}
+		states[abs] = fileState{exists: true, size: info.Size(), modTime: info.ModTime()}
+	}
+	return states
+}
+
+// sameStates reports whether two snapshots describe the same files in the same state
+func sameStates(a, b map[string]fileState) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for path, state := range a {
+		other, ok := b[path]
+		if !ok || other.exists != state.exists || other.size != state.size || !other.modTime.Equal(state.modTime) {
+			return false
+		}
+	}
+	return true
+}
@@ -2,61 +2,119 @@ package analyzer
 
 import (
 	"fmt"
-	"os"
-	"sort"
 	"strconv"
+	"strings"
 
 	"github.com/tomdoesdev/brace/internal/ast"
+	"github.com/tomdoesdev/brace/internal/directive"
+	"github.com/tomdoesdev/brace/internal/env"
+	"github.com/tomdoesdev/brace/internal/errors"
+	"github.com/tomdoesdev/brace/internal/token"
+	"github.com/tomdoesdev/brace/internal/value"
+	"github.com/tomdoesdev/brace/internal/version"
 )
 
-// Supported BRACE versions
-var supportedVersions = map[string]bool{
-	"0.0.1": true,
-	"1.0.0": true,
-	// Add new versions here as they're released
-}
-
 // Analyzer performs semantic analysis on the AST
 // This includes processing directives and resolving constant references
 type Analyzer struct {
-	constants map[string]map[string]interface{} // namespace -> name -> value
-	errors    []string
+	constants  map[string]map[string]interface{} // namespace -> name -> value
+	errors     []string
+	directives *directive.Registry // custom directive handlers
+	filename   string              // file being analyzed, passed to directive handlers
+	env        env.Provider        // source of @env values
+	version    version.Version     // version declared with @brace
+	versionTok token.Token         // the @brace version string
+	inferEnv   bool                // legacy type inference for untyped @env values
+	policy     env.Policy          // environment access policy set by the embedding application
+	filePolicy env.Policy          // environment access policy declared with @env_policy
+
+	diagnostics []errors.Diagnostic    // a.errors with positions
+	namespaces  map[string]token.Token // where each constant namespace was first declared, for suggestions
+	tables      map[string]token.Token // where each table path was first declared, for suggestions
+
+	constantDefinitions map[string]map[string]constantDefinition // namespace -> name -> definition, for Explain
+	envLookups          map[*ast.EnvDirective]envLookup          // outcome of each @env lookup, for Explain
+	directiveOutputs    map[string]*ast.DirectiveStatement       // keys custom directive statements wrote last, for Explain
+
+	outputs    *outputNode                     // keys of the output, for resolving :$.path references
+	outputRefs map[*ast.Reference]outputResult // resolved :$.path references
+	evaluating []outputFrame                   // keys being evaluated, innermost last
 }
 
-// New creates a new analyzer instance
+// New creates a new analyzer instance reading @env values from the process environment
 func New() *Analyzer {
 	return &Analyzer{
-		constants: make(map[string]map[string]interface{}),
-		errors:    []string{},
+		constants:           make(map[string]map[string]interface{}),
+		errors:              []string{},
+		env:                 env.OS{},
+		namespaces:          make(map[string]token.Token),
+		tables:              make(map[string]token.Token),
+		constantDefinitions: make(map[string]map[string]constantDefinition),
+		envLookups:          make(map[*ast.EnvDirective]envLookup),
+		directiveOutputs:    make(map[string]*ast.DirectiveStatement),
+		outputRefs:          make(map[*ast.Reference]outputResult),
 	}
 }
 
+// SetEnvProvider sets where @env directives read variables from
+func (a *Analyzer) SetEnvProvider(provider env.Provider) {
+	a.env = provider
+}
+
+// SetEnvPolicy restricts which environment variables @env may read
+// Policies declared in the file with @env_policy can only restrict access further
+func (a *Analyzer) SetEnvPolicy(policy env.Policy) {
+	a.policy = policy
+}
+
+// SetDirectives sets the registry used to execute custom directives
+func (a *Analyzer) SetDirectives(directives *directive.Registry) {
+	a.directives = directives
+}
+
+// SetFilename sets the name of the file being analyzed
+func (a *Analyzer) SetFilename(filename string) {
+	a.filename = filename
+}
+
 // Analyze processes the AST and resolves all directives and references
 func (a *Analyzer) Analyze(program *ast.Program) error {
 	// Validate that we have statements
 	if len(program.Statements) == 0 {
-		return fmt.Errorf("empty BRACE program")
+		return a.fatal(errors.Errorf(errors.CodeMissingBrace, "empty BRACE program"))
 	}
 
 	// First statement must be @brace directive (parser should have enforced this)
 	firstStmt := program.Statements[0]
 	braceDirective, ok := firstStmt.(*ast.DirectiveStatement)
 	if !ok || braceDirective.Name != "brace" {
-		return fmt.Errorf("first statement must be @brace directive")
+		return a.fatal(errors.Errorf(errors.CodeMissingBrace, "first statement must be @brace directive"))
 	}
 
 	// Validate @brace version
 	err := a.validateBraceVersion(braceDirective)
 	if err != nil {
-		return err
+		return a.fatal(err)
+	}
+
+	// Environment policies must be known before any @env is evaluated
+	a.processEnvPolicies(program)
+
+	for _, stmt := range program.Statements {
+		if table, ok := stmt.(*ast.TableStatement); ok {
+			a.recordTable(table)
+		}
 	}
 
+	// Output path references are resolved lazily, in dependency order
+	a.outputs = indexOutputs(program)
+
 	// Process all directives to build symbol tables
 	for _, stmt := range program.Statements {
 		if directive, ok := stmt.(*ast.DirectiveStatement); ok {
 			err := a.processDirective(directive)
 			if err != nil {
-				a.errors = append(a.errors, err.Error())
+				a.addError(withPosition(err, directive.Token, directive.End))
 			}
 		}
 	}
@@ -66,6 +124,11 @@ func (a *Analyzer) Analyze(program *ast.Program) error {
 		a.resolveReferences(stmt)
 	}
 
+	// Types are only meaningful once every value resolved
+	if len(a.errors) == 0 {
+		a.checkTypes(program)
+	}
+
 	if len(a.errors) > 0 {
 		return fmt.Errorf("analysis errors: %v", a.errors)
 	}
@@ -76,31 +139,42 @@ func (a *Analyzer) Analyze(program *ast.Program) error {
 // validateBraceVersion validates the @brace directive version
 func (a *Analyzer) validateBraceVersion(directive *ast.DirectiveStatement) error {
 	if len(directive.Parameters) != 1 {
-		return fmt.Errorf("@brace directive requires exactly one version parameter")
+		return errorAt(errors.CodeMalformedBrace, directive.Token, directive.End, "@brace directive requires exactly one version parameter")
 	}
 
 	versionLiteral, ok := directive.Parameters[0].(*ast.StringLiteral)
 	if !ok {
-		return fmt.Errorf("@brace version must be a string literal")
+		tok := ast.ExpressionToken(directive.Parameters[0])
+		return errorAt(errors.CodeMalformedBrace, tok, ast.ExpressionEnd(directive.Parameters[0]), "@brace version must be a string literal")
 	}
 
-	version := versionLiteral.Value
-	if !supportedVersions[version] {
-		return fmt.Errorf("unsupported BRACE version: %s (supported versions: %v)",
-			version, getSupportedVersionsList())
+	v, err := version.Parse(versionLiteral.Value)
+	if err != nil || !version.IsSupported(v) {
+		return errorAt(errors.CodeUnsupportedVersion, versionLiteral.Token, versionLiteral.Token, "unsupported BRACE version: %s (supported versions: %v)",
+			versionLiteral.Value, version.Supported())
 	}
+	a.version, a.versionTok = v, versionLiteral.Token
+	a.inferEnv = version.EnvInference.Available(v)
 
 	return nil
 }
 
-// getSupportedVersionsList returns a sorted list of supported versions
-func getSupportedVersionsList() []string {
-	versions := make([]string, 0, len(supportedVersions))
-	for version := range supportedVersions {
-		versions = append(versions, version)
+// requireFeature returns an error at start..end if the declared version lacks a feature
+func (a *Analyzer) requireFeature(feature version.Feature, start, end token.Token) error {
+	if feature.Available(a.version) {
+		return nil
 	}
-	sort.Strings(versions)
-	return versions
+	err := &positionError{
+		code:    errors.CodeUnavailableFeature,
+		start:   start,
+		end:     end,
+		err:     fmt.Errorf("%s", feature.Unavailable(a.version)),
+		related: []errors.RelatedLocation{a.relatedAt(a.versionTok, "version declared here")},
+	}
+	if feature.Available(version.Latest()) {
+		err.help = append(err.help, fmt.Sprintf("run `brace migrate` to upgrade the file to BRACE %s", version.Latest()))
+	}
+	return err
 }
 
 // processDirective handles directive execution
@@ -114,9 +188,202 @@ func (a *Analyzer) processDirective(directive *ast.DirectiveStatement) error {
 	case "env":
 		// env directives are processed during reference resolution
 		return nil
+	case "env_policy":
+		// Already processed in processEnvPolicies
+		return nil
 	default:
-		return fmt.Errorf("unknown directive: %s", directive.Name)
+		return a.processCustomDirective(directive)
+	}
+}
+
+// processCustomDirective executes a registered directive used as a statement
+func (a *Analyzer) processCustomDirective(stmt *ast.DirectiveStatement) error {
+	result, err := a.callDirective(stmt.Token, stmt.End, stmt.Name, stmt.Parameters, stmt.Body, true)
+	if err != nil {
+		return err
+	}
+	if result == nil {
+		return nil
+	}
+
+	if len(result.Constants) > 0 {
+		namespace := result.Namespace
+		if namespace == "" {
+			namespace = "global"
+		} else {
+			a.recordNamespace(namespace, stmt.Token)
+		}
+		if a.constants[namespace] == nil {
+			a.constants[namespace] = make(map[string]interface{})
+		}
+		for name, value := range result.Constants {
+			a.constants[namespace][name] = value
+			a.recordConstant(namespace, name, constantDefinition{key: stmt.Token, directive: stmt.Name})
+		}
+	}
+
+	stmt.ResolvedOutput = result.Output
+	for key := range result.Output {
+		// A later assignment or table replaces the key in the output
+		if node, ok := a.outputs.children[key]; !ok || node.key.Position < stmt.Token.Position {
+			a.directiveOutputs[key] = stmt
+		}
+	}
+	return nil
+}
+
+// callDirective evaluates a custom directive's arguments and body and invokes its handler
+func (a *Analyzer) callDirective(tok, end token.Token, name string, args []ast.Expression, body *ast.ObjectLiteral, statement bool) (*directive.Result, error) {
+	handler, ok := a.directives.Lookup(name)
+	if !ok {
+		return nil, errorAt(errors.CodeUnknownDirective, tok, end, "unknown directive: %s", name)
+	}
+	if feature, ok := version.ForDirective(name); ok {
+		if err := a.requireFeature(feature, tok, end); err != nil {
+			return nil, err
+		}
+	}
+
+	call := &directive.Call{
+		Name:      name,
+		Token:     tok,
+		Filename:  a.filename,
+		Statement: statement,
+	}
+
+	for _, arg := range args {
+		value, err := a.evaluateExpression(arg)
+		if err != nil {
+			return nil, withPosition(fmt.Errorf("error evaluating @%s argument: %w", name, err), ast.ExpressionToken(arg), ast.ExpressionEnd(arg))
+		}
+		call.Args = append(call.Args, value)
+		call.ArgTokens = append(call.ArgTokens, ast.ExpressionToken(arg))
+	}
+
+	if body != nil {
+		call.Body = make(map[string]interface{})
+		call.BodyTokens = make(map[string]token.Token)
+		for key, value := range body.Pairs {
+			ident, ok := key.(*ast.Identifier)
+			if !ok {
+				continue
+			}
+			resolved, err := a.evaluateExpression(value)
+			if err != nil {
+				return nil, withPosition(fmt.Errorf("error evaluating @%s body entry %s: %w", name, ident.Value, err), ident.Token, ast.ExpressionEnd(value))
+			}
+			call.Body[ident.Value] = resolved
+			call.BodyTokens[ident.Value] = ident.Token
+		}
+	}
+
+	result, err := handler(call)
+	if err != nil {
+		return nil, subjectErrorAt(errors.CodeDirectiveFailed, "@"+name, tok, end, err)
 	}
+	return result, nil
+}
+
+// processEnvPolicies collects @env_policy directives into the file policy
+func (a *Analyzer) processEnvPolicies(program *ast.Program) {
+	for _, stmt := range program.Statements {
+		directive, ok := stmt.(*ast.DirectiveStatement)
+		if !ok || directive.Name != "env_policy" {
+			continue
+		}
+		policy, err := a.evaluateEnvPolicy(directive)
+		if err != nil {
+			a.addError(withPosition(err, directive.Token, directive.End))
+			continue
+		}
+		a.filePolicy = a.filePolicy.Merge(policy)
+	}
+}
+
+// evaluateEnvPolicy converts an @env_policy body into a policy
+func (a *Analyzer) evaluateEnvPolicy(directive *ast.DirectiveStatement) (env.Policy, error) {
+	var policy env.Policy
+
+	for key, value := range directive.Body.Pairs {
+		ident, ok := key.(*ast.Identifier)
+		if !ok {
+			return policy, errorAt(errors.CodeInvalidEnvPolicy, ast.ExpressionToken(key), ast.ExpressionEnd(key), "@env_policy keys must be identifiers")
+		}
+
+		patterns, err := a.evaluateStringList(value)
+		if err != nil {
+			return policy, subjectErrorAt(errors.CodeInvalidEnvPolicy, "@env_policy "+ident.Value, ident.Token, ast.ExpressionEnd(value), err)
+		}
+
+		switch ident.Value {
+		case "allow":
+			policy.Allow = append(policy.Allow, patterns...)
+		case "deny":
+			policy.Deny = append(policy.Deny, patterns...)
+		default:
+			return policy, subjectErrorAt(errors.CodeInvalidEnvPolicy, "@env_policy", ident.Token, ident.Token,
+				fmt.Errorf("unknown key %s (expected allow or deny)", ident.Value))
+		}
+	}
+
+	if err := policy.Validate(); err != nil {
+		return policy, subjectErrorAt(errors.CodeInvalidEnvPolicy, "@env_policy", directive.Token, directive.End, err)
+	}
+	return policy, nil
+}
+
+// evaluateStringList evaluates an expression that must be an array of strings
+func (a *Analyzer) evaluateStringList(expr ast.Expression) ([]string, error) {
+	arr, ok := expr.(*ast.ArrayLiteral)
+	if !ok {
+		return nil, fmt.Errorf("expected an array of strings")
+	}
+
+	var result []string
+	for _, element := range arr.Elements {
+		str, ok := element.(*ast.StringLiteral)
+		if !ok {
+			return nil, fmt.Errorf("expected an array of strings")
+		}
+		result = append(result, str.Value)
+	}
+	return result, nil
+}
+
+// checkEnvPolicy reports whether an @env lookup is permitted by the configured policies
+func (a *Analyzer) checkEnvPolicy(env *ast.EnvDirective) error {
+	if err := a.policy.Check(env.VarName); err != nil {
+		return err
+	}
+	return a.filePolicy.Check(env.VarName)
+}
+
+// AuditEnv lists every environment variable the program reads without evaluating it
+// Each access records whether the configured and in-file policies permit it
+func (a *Analyzer) AuditEnv(program *ast.Program) []env.Access {
+	a.processEnvPolicies(program)
+
+	var accesses []env.Access
+	ast.Inspect(program, func(node ast.Node) bool {
+		envDirective, ok := node.(*ast.EnvDirective)
+		if !ok {
+			return true
+		}
+		access := env.Access{
+			Name:       envDirective.VarName,
+			Type:       envDirective.Type,
+			HasDefault: envDirective.DefaultValue != nil,
+			Line:       envDirective.Token.Line,
+			Column:     envDirective.Token.Column,
+		}
+		if err := a.checkEnvPolicy(envDirective); err != nil {
+			access.Violation = err.Error()
+		}
+		accesses = append(accesses, access)
+		return true
+	})
+
+	return accesses
 }
 
 // processConstDirective processes @const directives
@@ -127,6 +394,7 @@ func (a *Analyzer) processConstDirective(directive *ast.DirectiveStatement) erro
 	if len(directive.Parameters) > 0 {
 		if str, ok := directive.Parameters[0].(*ast.StringLiteral); ok {
 			namespace = str.Value
+			a.recordNamespace(namespace, str.Token)
 		}
 	}
 
@@ -138,11 +406,15 @@ func (a *Analyzer) processConstDirective(directive *ast.DirectiveStatement) erro
 	// Process each constant in the body
 	for key, value := range directive.Body.Pairs {
 		if ident, ok := key.(*ast.Identifier); ok {
+			if err := checkConstOutputReferences(value); err != nil {
+				return err
+			}
 			resolvedValue, err := a.evaluateExpression(value)
 			if err != nil {
-				return fmt.Errorf("error evaluating constant %s: %v", ident.Value, err)
+				return withPosition(fmt.Errorf("error evaluating constant %s: %w", ident.Value, err), ident.Token, ast.ExpressionEnd(value))
 			}
 			a.constants[namespace][ident.Value] = resolvedValue
+			a.recordConstant(namespace, ident.Value, constantDefinition{key: ident.Token, value: value})
 		}
 	}
 
@@ -165,13 +437,57 @@ func (a *Analyzer) evaluateExpression(expr ast.Expression) (interface{}, error)
 	case *ast.Reference:
 		// Handle references to constants that might contain @env values
 		return a.resolveReferenceValue(e)
+	case *ast.DirectiveExpression:
+		return a.evaluateDirectiveExpression(e)
+	case *ast.TemplateStringLiteral:
+		return value.Interpolate(e, a.evaluateExpression)
+	case *ast.ArrayLiteral:
+		elements := make([]interface{}, 0, len(e.Elements))
+		for _, element := range e.Elements {
+			value, err := a.evaluateExpression(element)
+			if err != nil {
+				return nil, err
+			}
+			elements = append(elements, value)
+		}
+		return elements, nil
+	case *ast.ObjectLiteral:
+		result := make(map[string]interface{})
+		for key, value := range e.Pairs {
+			ident, ok := key.(*ast.Identifier)
+			if !ok {
+				return nil, errorAt(errors.CodeInvalidKey, ast.ExpressionToken(key), ast.ExpressionEnd(key), "object keys must be identifiers, got %T", key)
+			}
+			resolved, err := a.evaluateExpression(value)
+			if err != nil {
+				return nil, err
+			}
+			result[ident.Value] = resolved
+		}
+		return result, nil
 	default:
-		return nil, fmt.Errorf("cannot evaluate expression type: %T", expr)
+		return nil, errorAt(errors.CodeUnresolvedValue, ast.ExpressionToken(expr), ast.ExpressionEnd(expr), "cannot evaluate expression type: %T", expr)
+	}
+}
+
+// evaluateDirectiveExpression runs a custom directive used as a value
+func (a *Analyzer) evaluateDirectiveExpression(expr *ast.DirectiveExpression) (interface{}, error) {
+	result, err := a.callDirective(expr.Token, expr.End, expr.Name, expr.Arguments, expr.Body, false)
+	if err != nil {
+		return nil, err
+	}
+	if result == nil {
+		return nil, nil
 	}
+	return result.Value, nil
 }
 
 // resolveReferenceValue resolves a reference and returns its value
 func (a *Analyzer) resolveReferenceValue(ref *ast.Reference) (interface{}, error) {
+	if ref.Path != nil {
+		return a.resolveOutputReference(ref)
+	}
+
 	namespace := ref.Namespace
 	if namespace == "" {
 		namespace = "global"
@@ -183,7 +499,7 @@ func (a *Analyzer) resolveReferenceValue(ref *ast.Reference) (interface{}, error
 		}
 	}
 
-	return nil, fmt.Errorf("undefined reference: %s.%s", namespace, ref.Name)
+	return nil, a.undefinedReference(ref, namespace)
 }
 
 // resolveReferences recursively resolves all references in the AST
@@ -211,6 +527,13 @@ func (a *Analyzer) resolveReferences(node ast.Node) {
 	case *ast.EnvDirective:
 		// Resolve environment directives
 		a.resolveEnvDirective(n)
+	case *ast.DirectiveExpression:
+		value, err := a.evaluateDirectiveExpression(n)
+		if err != nil {
+			a.addError(err)
+			return
+		}
+		n.ResolvedValue = value
 	case *ast.TemplateStringLiteral:
 		// Resolve references within template strings
 		for _, part := range n.Parts {
@@ -221,8 +544,17 @@ func (a *Analyzer) resolveReferences(node ast.Node) {
 	}
 }
 
-// resolveReference resolves a single constant reference
+// resolveReference resolves a single constant or output path reference
 func (a *Analyzer) resolveReference(ref *ast.Reference) {
+	if ref.Path != nil {
+		value, err := a.resolveOutputReference(ref)
+		if _, ok := err.(*dependencyError); err != nil && !ok {
+			a.addError(err)
+		}
+		ref.ResolvedValue = value
+		return
+	}
+
 	namespace := ref.Namespace
 	if namespace == "" {
 		namespace = "global"
@@ -236,51 +568,131 @@ func (a *Analyzer) resolveReference(ref *ast.Reference) {
 		}
 	}
 
-	a.errors = append(a.errors, fmt.Sprintf("undefined reference: %s.%s", namespace, ref.Name))
+	a.addError(a.undefinedReference(ref, namespace))
 }
 
 // resolveEnvDirective resolves @env directives by evaluating them
 func (a *Analyzer) resolveEnvDirective(env *ast.EnvDirective) {
-	value, err := a.evaluateEnvDirectiveExpression(env)
-	if err != nil {
-		a.errors = append(a.errors, err.Error())
-		return
+	if _, err := a.evaluateEnvDirectiveExpression(env); err != nil {
+		a.addError(err)
 	}
-
-	// Store the resolved value for later use
-	env.ResolvedValue = value
 }
 
-// evaluateEnvDirectiveExpression evaluates @env directives
+// evaluateEnvDirectiveExpression evaluates @env directives and stores the
+// resolved value, so lookups inside @const values are typed by their result too
 func (a *Analyzer) evaluateEnvDirectiveExpression(env *ast.EnvDirective) (interface{}, error) {
-	// Get environment variable
-	value := os.Getenv(env.VarName)
+	value, err := a.lookupEnv(env)
+	if err != nil {
+		return nil, err
+	}
+	env.ResolvedValue, env.Resolved = value, true
+	return value, nil
+}
+
+// lookupEnv reads the variable of an @env lookup and converts it to its type
+func (a *Analyzer) lookupEnv(env *ast.EnvDirective) (interface{}, error) {
+	if err := a.checkEnvPolicy(env); err != nil {
+		return nil, subjectErrorAt(errors.CodeEnvDenied, env.String(), env.Token, env.End, err)
+	}
 
-	if value == "" {
+	// Get environment variable; a variable set to "" is set, not missing
+	value, ok := a.env.Lookup(env.VarName)
+	a.envLookups[env] = envLookup{value: value, set: ok}
+
+	if !ok {
 		// Check if default value was provided
 		if env.DefaultValue != nil {
 			return a.evaluateExpression(env.DefaultValue)
 		} else {
-			return nil, fmt.Errorf("environment variable %s not set and no default provided", env.VarName)
+			return nil, errorAt(errors.CodeEnvNotSet, env.Token, env.End, "environment variable %s not set and no default provided", env.VarName)
+		}
+	}
+
+	if env.Type == "" {
+		if a.inferEnv {
+			return inferEnvValue(value), nil
 		}
+		return value, nil
 	}
 
-	// Try to convert to appropriate type based on the value
+	converted, err := convertEnvValue(value, env.Type, env.Separator)
+	if err != nil {
+		return nil, subjectErrorAt(errors.CodeInvalidEnvValue, env.String(), env.Token, env.End,
+			fmt.Errorf("environment variable %s: %v", env.VarName, err))
+	}
+	return converted, nil
+}
+
+// convertEnvValue converts a variable's value for a typed @env.<type> lookup
+func convertEnvValue(raw, envType, separator string) (interface{}, error) {
+	switch envType {
+	case "string":
+		return raw, nil
+	case "int":
+		intVal, err := strconv.ParseInt(strings.TrimSpace(raw), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("cannot convert %q to int", raw)
+		}
+		return intVal, nil
+	case "float":
+		floatVal, err := strconv.ParseFloat(strings.TrimSpace(raw), 64)
+		if err != nil {
+			return nil, fmt.Errorf("cannot convert %q to float", raw)
+		}
+		return floatVal, nil
+	case "bool":
+		boolVal, err := strconv.ParseBool(strings.TrimSpace(raw))
+		if err != nil {
+			return nil, fmt.Errorf("cannot convert %q to bool", raw)
+		}
+		return boolVal, nil
+	case "json":
+		jsonVal, err := value.FromJSON([]byte(raw))
+		if err != nil {
+			return nil, fmt.Errorf("invalid JSON: %v", err)
+		}
+		return jsonVal, nil
+	case "list":
+		elements := []interface{}{}
+		if strings.TrimSpace(raw) == "" {
+			return elements, nil
+		}
+		for _, element := range strings.Split(raw, separator) {
+			elements = append(elements, strings.TrimSpace(element))
+		}
+		return elements, nil
+	default:
+		return nil, fmt.Errorf("unknown @env type: %s", envType)
+	}
+}
+
+// inferEnvValue guesses the type of an untyped @env value (legacy behavior)
+func inferEnvValue(value string) interface{} {
 	if value == "true" {
-		return true, nil
+		return true
 	}
 	if value == "false" {
-		return false, nil
+		return false
 	}
 	if intVal, err := strconv.ParseInt(value, 10, 64); err == nil {
-		return intVal, nil
+		return intVal
 	}
 	if floatVal, err := strconv.ParseFloat(value, 64); err == nil {
-		return floatVal, nil
+		return floatVal
 	}
 
 	// Default to string
-	return value, nil
+	return value
+}
+
+// Constant returns the value of a constant after analysis
+// The global namespace is ""
+func (a *Analyzer) Constant(namespace, name string) (interface{}, bool) {
+	if namespace == "" {
+		namespace = "global"
+	}
+	value, ok := a.constants[namespace][name]
+	return value, ok
 }
 
 // Errors returns the collected errors
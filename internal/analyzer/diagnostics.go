@@ -0,0 +1,112 @@
+package analyzer
+
+import (
+	stderrors "errors"
+	"fmt"
+	"strings"
+
+	"github.com/tomdoesdev/brace/internal/errors"
+	"github.com/tomdoesdev/brace/internal/token"
+)
+
+// positionError is an error at a range of source tokens
+// With a subject it reads "subject at line:column: message", matching the
+// analyzer's error strings; without one the position is only kept for Diagnostics
+type positionError struct {
+	code    string
+	subject string
+	start   token.Token
+	end     token.Token
+	err     error
+
+	related []errors.RelatedLocation // locations shown as notes
+	fixes   []errors.Fix             // suggested changes shown as help
+	help    []string
+}
+
+func (e *positionError) Error() string {
+	if e.subject == "" {
+		return e.err.Error()
+	}
+	return fmt.Sprintf("%s at %d:%d: %v", e.subject, e.start.Line, e.start.Column, e.err)
+}
+
+func (e *positionError) Unwrap() error {
+	return e.err
+}
+
+// ErrorCode returns the error's diagnostic code
+func (e *positionError) ErrorCode() string {
+	return e.code
+}
+
+// errorAt returns an error with a diagnostic code at the tokens from start to end
+func errorAt(code string, start, end token.Token, format string, args ...interface{}) error {
+	return &positionError{code: code, start: start, end: end, err: fmt.Errorf(format, args...)}
+}
+
+// subjectErrorAt returns an error reported as "subject at line:column: err"
+func subjectErrorAt(code, subject string, start, end token.Token, err error) error {
+	return &positionError{code: code, subject: subject, start: start, end: end, err: err}
+}
+
+// withPosition places err at start..end unless it already has a position
+// The error keeps the code of the error it wraps
+func withPosition(err error, start, end token.Token) error {
+	var positioned *positionError
+	if stderrors.As(err, &positioned) {
+		return err
+	}
+	return &positionError{start: start, end: end, err: err}
+}
+
+// addError records an analysis error
+func (a *Analyzer) addError(err error) {
+	a.errors = append(a.errors, err.Error())
+	a.diagnostics = append(a.diagnostics, a.diagnostic(err))
+}
+
+// fatal records an error that stops analysis and returns it
+func (a *Analyzer) fatal(err error) error {
+	a.diagnostics = append(a.diagnostics, a.diagnostic(err))
+	return err
+}
+
+// diagnostic converts an analysis error to a diagnostic, using the position
+// of the innermost positioned error and the code of the innermost coded error it wraps
+func (a *Analyzer) diagnostic(err error) errors.Diagnostic {
+	d := errors.Diagnostic{Severity: errors.SeverityError, Code: errors.CodeOf(err), Message: diagnosticMessage(err), File: a.filename}
+
+	var positioned *positionError
+	for current := err; stderrors.As(current, &positioned); current = positioned.err {
+		d.Range = errors.TokenRange(positioned.start, positioned.end)
+		d.Related = append(d.Related, positioned.related...)
+		d.Fixes = append(d.Fixes, positioned.fixes...)
+		d.Help = append(d.Help, positioned.help...)
+	}
+	return d
+}
+
+// diagnosticMessage returns the message of err without the positions of the
+// positioned errors it wraps, as a diagnostic carries its position separately
+// Each wrapping error contributes the text it adds in front of the error it
+// wraps; errors that wrap another in any other way are kept as they are
+func diagnosticMessage(err error) string {
+	if positioned, ok := err.(*positionError); ok {
+		if positioned.subject == "" {
+			return diagnosticMessage(positioned.err)
+		}
+		return positioned.subject + ": " + diagnosticMessage(positioned.err)
+	}
+
+	inner := stderrors.Unwrap(err)
+	if inner == nil || !strings.HasSuffix(err.Error(), inner.Error()) {
+		return err.Error()
+	}
+	return strings.TrimSuffix(err.Error(), inner.Error()) + diagnosticMessage(inner)
+}
+
+// Diagnostics returns the errors found by Analyze as structured diagnostics
+func (a *Analyzer) Diagnostics() []errors.Diagnostic {
+	return a.diagnostics
+}
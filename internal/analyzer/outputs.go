@@ -0,0 +1,288 @@
+package analyzer
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/tomdoesdev/brace/internal/ast"
+	"github.com/tomdoesdev/brace/internal/errors"
+	"github.com/tomdoesdev/brace/internal/query"
+	"github.com/tomdoesdev/brace/internal/token"
+)
+
+// outputNode is a key of the output and the expression that defines it, so
+// :$.path references can be resolved before the output is built
+type outputNode struct {
+	expr     ast.Expression         // value of the key; nil for objects created by table headers
+	children map[string]*outputNode // keys of objects, whose values are their children
+	key      token.Token            // key the value was assigned at, or the header of table
+	table    *ast.TableStatement    // last #table header that created or extended the object
+
+	state outputState
+	value interface{}
+	err   error
+}
+
+// outputState tracks the evaluation of an output node
+type outputState int
+
+const (
+	unevaluated outputState = iota
+	evaluating              // on the evaluation stack; reaching it again is a cycle
+	evaluated
+)
+
+// outputFrame is a key being evaluated, for reporting cycles
+type outputFrame struct {
+	path query.Path
+	ref  *ast.Reference // reference the key is evaluated for
+}
+
+// outputResult is the resolved value of an output path reference
+type outputResult struct {
+	value interface{}
+	err   error
+}
+
+// dependencyError is the failure of a value a reference depends on
+// It is reported where it occurred, not at every reference to the value
+type dependencyError struct {
+	err error
+}
+
+func (e *dependencyError) Error() string {
+	return e.err.Error()
+}
+
+func (e *dependencyError) Unwrap() error {
+	return e.err
+}
+
+func newObjectNode() *outputNode {
+	return &outputNode{children: make(map[string]*outputNode)}
+}
+
+// indexOutputs indexes the keys defined by assignments and tables, with where
+// they were defined
+// Later definitions replace earlier ones, as in the output; keys produced by
+// custom directive statements are only known once they run, so they are not indexed
+func indexOutputs(program *ast.Program) *outputNode {
+	root := newObjectNode()
+	for _, stmt := range program.Statements {
+		switch s := stmt.(type) {
+		case *ast.AssignmentStatement:
+			root.children[s.Name.Value] = indexExpression(s.Name.Token, s.Value)
+		case *ast.TableStatement:
+			if len(s.Path) == 0 {
+				continue
+			}
+			parent := root
+			for _, segment := range s.Path[:len(s.Path)-1] {
+				child, ok := parent.children[segment]
+				if !ok || child.children == nil {
+					child = newObjectNode()
+					parent.children[segment] = child
+				}
+				child.key, child.table = s.Token, s
+				parent = child
+			}
+			node := indexExpression(s.Token, s.Body)
+			node.table = s
+			parent.children[s.Path[len(s.Path)-1]] = node
+		}
+	}
+	return root
+}
+
+// indexExpression indexes the keys of object literals down to their values
+func indexExpression(key token.Token, expr ast.Expression) *outputNode {
+	obj, ok := expr.(*ast.ObjectLiteral)
+	if !ok {
+		return &outputNode{expr: expr, key: key}
+	}
+	node := newObjectNode()
+	node.key = key
+	if obj == nil {
+		return node
+	}
+	node.expr = obj
+	// In source order, so the last of duplicate keys wins
+	for _, k := range ast.SortedKeys(obj) {
+		node.children[ast.KeyName(k)] = indexExpression(ast.ExpressionToken(k), obj.Pairs[k])
+	}
+	return node
+}
+
+// resolveOutputReference returns the value at the path of a :$.path reference,
+// evaluating the keys it depends on first
+// Each reference is resolved once; failures of the values it depends on are
+// returned as a *dependencyError
+func (a *Analyzer) resolveOutputReference(ref *ast.Reference) (interface{}, error) {
+	if result, ok := a.outputRefs[ref]; ok {
+		return result.value, result.err
+	}
+	value, err := a.lookupOutput(ref)
+
+	// A cycle through ref resolves it again while it is looked up, and
+	// reports the cycle there
+	if result, ok := a.outputRefs[ref]; ok {
+		return result.value, result.err
+	}
+	a.outputRefs[ref] = outputResult{value: value, err: err}
+	return value, err
+}
+
+// lookupOutput finds the output node a reference names and evaluates it
+// Paths reaching into a computed value, such as an @env.json lookup, are
+// followed through the value
+func (a *Analyzer) lookupOutput(ref *ast.Reference) (interface{}, error) {
+	path := outputPath(ref.Path)
+	node := a.outputs
+	for i, key := range ref.Path {
+		if node.children == nil {
+			value, err := a.evaluateOutput(node, path[:i], ref)
+			if err != nil {
+				return nil, err
+			}
+			for j := i; j < len(ref.Path); j++ {
+				object, ok := value.(map[string]interface{})
+				if !ok {
+					return nil, a.undefinedOutputPath(ref, j, nil)
+				}
+				if value, ok = object[ref.Path[j]]; !ok {
+					return nil, a.undefinedOutputPath(ref, j, sortedKeys(object))
+				}
+			}
+			return value, nil
+		}
+
+		child, ok := node.children[key]
+		if !ok {
+			return nil, a.undefinedOutputPath(ref, i, sortedKeys(node.children))
+		}
+		node = child
+	}
+	return a.evaluateOutput(node, path, ref)
+}
+
+// evaluateOutput evaluates the value of an output node once
+// It returns a cycle error if the node is already being evaluated; other
+// errors are failures of the node's own expressions, wrapped as dependencies
+func (a *Analyzer) evaluateOutput(node *outputNode, path query.Path, ref *ast.Reference) (interface{}, error) {
+	switch node.state {
+	case evaluated:
+		return node.value, node.err
+	case evaluating:
+		return nil, a.referenceCycle(ref, path)
+	}
+
+	node.state = evaluating
+	a.evaluating = append(a.evaluating, outputFrame{path: path, ref: ref})
+	defer func() {
+		a.evaluating = a.evaluating[:len(a.evaluating)-1]
+		node.state = evaluated
+	}()
+
+	if node.children == nil {
+		node.value, node.err = a.evaluateExpression(node.expr)
+		if node.err != nil {
+			node.err = asDependency(node.err)
+		}
+		return node.value, node.err
+	}
+
+	object := make(map[string]interface{}, len(node.children))
+	for _, key := range sortedKeys(node.children) {
+		childPath := append(append(query.Path{}, path...), query.Segment{Kind: query.KeySegment, Key: key})
+		value, err := a.evaluateOutput(node.children[key], childPath, ref)
+		if err != nil {
+			// A cycle through a key of the object is ref's own error
+			node.err = asDependency(err)
+			return nil, err
+		}
+		object[key] = value
+	}
+	node.value = object
+	return object, nil
+}
+
+// asDependency marks err as the failure of a value other references depend on
+func asDependency(err error) error {
+	if _, ok := err.(*dependencyError); ok {
+		return err
+	}
+	return &dependencyError{err: err}
+}
+
+// referenceCycle returns the error for a reference that needs the value at
+// path while that value is being evaluated, naming every key in the cycle
+func (a *Analyzer) referenceCycle(ref *ast.Reference, path query.Path) error {
+	start := 0
+	for i, frame := range a.evaluating {
+		if frame.path.String() == path.String() {
+			start = i
+			break
+		}
+	}
+
+	var names []string
+	err := &positionError{code: errors.CodeReferenceCycle, start: ref.Token, end: ref.End}
+	for i, frame := range a.evaluating[start:] {
+		names = append(names, frame.path.String())
+		if i > 0 && frame.ref != a.evaluating[start+i-1].ref {
+			err.related = append(err.related, a.relatedAt(frame.ref.Token, fmt.Sprintf("`%s` references `%s` here", a.evaluating[start+i-1].path, frame.ref)))
+		}
+	}
+	names = append(names, path.String())
+	err.err = fmt.Errorf("reference cycle: %s", strings.Join(names, " -> "))
+	err.help = append(err.help, "give one of the keys in the cycle a value that does not reference the others")
+	return err
+}
+
+// undefinedOutputPath returns the error for a reference whose path has no
+// key at index i, suggesting a likely typo among the keys that exist there
+func (a *Analyzer) undefinedOutputPath(ref *ast.Reference, i int, keys []string) error {
+	err := &positionError{
+		code:  errors.CodeUndefinedReference,
+		start: ref.Token,
+		end:   ref.End,
+		err:   fmt.Errorf("undefined output path: %s", outputPath(ref.Path[:i+1])),
+	}
+	if suggestion, ok := errors.Suggest(ref.Path[i], keys); ok {
+		replacement := &ast.Reference{Path: append([]string{}, ref.Path...)}
+		replacement.Path[i] = suggestion
+		err.fixes = append(err.fixes, errors.Fix{
+			Message: fmt.Sprintf("did you mean `%s`?", replacement),
+			Edits: []errors.TextEdit{{
+				Range:   errors.TokenRange(ref.Token, ref.End),
+				NewText: replacement.String(),
+			}},
+		})
+	}
+	if keys == nil && i > 0 {
+		err.help = append(err.help, fmt.Sprintf("%s is not an object", outputPath(ref.Path[:i])))
+	}
+	return err
+}
+
+// outputPath converts the keys of a reference to a query path
+func outputPath(keys []string) query.Path {
+	path := make(query.Path, len(keys))
+	for i, key := range keys {
+		path[i] = query.Segment{Kind: query.KeySegment, Key: key}
+	}
+	return path
+}
+
+// checkConstOutputReferences reports output path references in @const values,
+// which are evaluated before any key of the output
+func checkConstOutputReferences(value ast.Expression) error {
+	var err error
+	ast.Inspect(value, func(node ast.Node) bool {
+		if ref, ok := node.(*ast.Reference); ok && ref.Path != nil && err == nil {
+			err = errorAt(errors.CodeUndefinedReference, ref.Token, ref.End, "@const values cannot reference output paths such as %s", ref)
+		}
+		return err == nil
+	})
+	return err
+}
@@ -0,0 +1,301 @@
+package analyzer
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/tomdoesdev/brace/internal/ast"
+	"github.com/tomdoesdev/brace/internal/query"
+	"github.com/tomdoesdev/brace/internal/token"
+)
+
+// OriginKind classifies a step in a value's provenance
+type OriginKind string
+
+const (
+	OriginLiteral   OriginKind = "literal"   // a value written in the file
+	OriginKey       OriginKind = "key"       // an assignment or object key the value is stored under
+	OriginTable     OriginKind = "table"     // an object created by a #table header
+	OriginReference OriginKind = "reference" // a :namespace.NAME or :$.path reference
+	OriginConstant  OriginKind = "constant"  // the @const entry a reference resolved to
+	OriginEnv       OriginKind = "env"       // an @env lookup
+	OriginEnvValue  OriginKind = "env_value" // the environment variable's value
+	OriginDefault   OriginKind = "default"   // the default of an @env lookup whose variable was unset
+	OriginDirective OriginKind = "directive" // a value produced by a directive such as @file
+	OriginTemplate  OriginKind = "template"  // a template string; From lists its interpolations
+)
+
+// Origin is one step in the chain explaining where a value came from
+// Most values have a single chain; template strings branch into one chain per
+// interpolated expression
+type Origin struct {
+	Kind        OriginKind `json:"kind"`
+	Description string     `json:"description"`
+	Line        int        `json:"line,omitempty"`
+	Column      int        `json:"column,omitempty"`
+	From        []*Origin  `json:"from,omitempty"`
+}
+
+// constantDefinition records where a constant was defined
+type constantDefinition struct {
+	key       token.Token
+	value     ast.Expression // nil for constants defined by a custom directive
+	directive string         // custom directive that defined the constant
+}
+
+// envLookup records the outcome of an @env lookup
+type envLookup struct {
+	value string
+	set   bool
+}
+
+// Explain returns the provenance of the value at path in the output of an
+// analyzed program, following the keys recorded during analysis
+func (a *Analyzer) Explain(path query.Path) (*Origin, error) {
+	if path.HasWildcard() {
+		return nil, fmt.Errorf("cannot explain a path with wildcards: %s", path)
+	}
+	if len(path) == 0 {
+		return nil, fmt.Errorf("cannot explain the whole document; give a path")
+	}
+	if path[0].Kind != query.KeySegment {
+		return nil, fmt.Errorf("%w: %s", query.ErrNotFound, path)
+	}
+
+	if s, ok := a.directiveOutputs[path[0].Key]; ok {
+		return &Origin{
+			Kind:        OriginDirective,
+			Description: fmt.Sprintf("output of @%s", s.Name),
+			Line:        s.Token.Line,
+			Column:      s.Token.Column,
+		}, nil
+	}
+
+	node := a.outputs
+	for i, segment := range path {
+		if node.children == nil {
+			// The rest of the path lies inside the value of a key
+			return a.explainExpression(node.expr, path[i:])
+		}
+		child, ok := node.children[segment.Key]
+		if segment.Kind != query.KeySegment || !ok {
+			return nil, fmt.Errorf("%w: %s", query.ErrNotFound, path)
+		}
+		node = child
+	}
+
+	if node.table != nil {
+		return &Origin{
+			Kind:        OriginTable,
+			Description: "#" + strings.Join(node.table.Path, "."),
+			Line:        node.table.Token.Line,
+			Column:      node.table.Token.Column,
+		}, nil
+	}
+	return a.explainKey(node.key, path[len(path)-1].Key, node.expr, nil)
+}
+
+// explainKey explains a value stored under a key
+func (a *Analyzer) explainKey(tok token.Token, name string, value ast.Expression, rest query.Path) (*Origin, error) {
+	if len(rest) > 0 {
+		return a.explainExpression(value, rest)
+	}
+	from, err := a.explainExpression(value, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &Origin{
+		Kind:        OriginKey,
+		Description: name,
+		Line:        tok.Line,
+		Column:      tok.Column,
+		From:        []*Origin{from},
+	}, nil
+}
+
+// explainExpression explains the value at rest inside expr
+func (a *Analyzer) explainExpression(expr ast.Expression, rest query.Path) (*Origin, error) {
+	tok := ast.ExpressionToken(expr)
+	origin := &Origin{Line: tok.Line, Column: tok.Column}
+
+	switch e := expr.(type) {
+	case *ast.ObjectLiteral:
+		if len(rest) == 0 {
+			origin.Kind, origin.Description = OriginLiteral, "object"
+			return origin, nil
+		}
+		if rest[0].Kind == query.KeySegment {
+			// The last of duplicate keys wins, as in the output
+			var found ast.Expression
+			for _, key := range ast.SortedKeys(e) {
+				if ast.KeyName(key) == rest[0].Key {
+					found = key
+				}
+			}
+			if found != nil {
+				return a.explainKey(ast.ExpressionToken(found), rest[0].Key, e.Pairs[found], rest[1:])
+			}
+		}
+		return nil, fmt.Errorf("%w: %s", query.ErrNotFound, rest)
+
+	case *ast.ArrayLiteral:
+		if len(rest) == 0 {
+			origin.Kind, origin.Description = OriginLiteral, "array"
+			return origin, nil
+		}
+		if rest[0].Kind == query.IndexSegment {
+			index := rest[0].Index
+			if index < 0 {
+				index += len(e.Elements)
+			}
+			if index >= 0 && index < len(e.Elements) {
+				return a.explainExpression(e.Elements[index], rest[1:])
+			}
+		}
+		return nil, fmt.Errorf("%w: %s", query.ErrNotFound, rest)
+
+	case *ast.Reference:
+		origin.Kind, origin.Description = OriginReference, e.String()
+		if e.Path != nil {
+			target, err := a.Explain(append(outputPath(e.Path), rest...))
+			if err != nil {
+				return nil, err
+			}
+			origin.From = []*Origin{target}
+			return origin, nil
+		}
+		constant, err := a.explainConstant(e, rest)
+		if err != nil {
+			return nil, err
+		}
+		origin.From = []*Origin{constant}
+		return origin, nil
+
+	case *ast.EnvDirective:
+		return a.explainEnv(e, origin, rest)
+
+	case *ast.DirectiveExpression:
+		origin.Kind, origin.Description = OriginDirective, e.String()
+		if len(rest) > 0 {
+			origin.Description += " (value at " + rest.String() + ")"
+		}
+		return origin, nil
+
+	case *ast.TemplateStringLiteral:
+		origin.Kind, origin.Description = OriginTemplate, "template string"
+		for _, part := range e.Parts {
+			if part.IsLiteral || part.Expr == nil {
+				continue
+			}
+			from, err := a.explainExpression(part.Expr, nil)
+			if err != nil {
+				return nil, err
+			}
+			origin.From = append(origin.From, from)
+		}
+		return origin, nil
+
+	default:
+		if len(rest) > 0 {
+			return nil, fmt.Errorf("%w: %s", query.ErrNotFound, rest)
+		}
+		origin.Kind, origin.Description = OriginLiteral, expr.String()
+		return origin, nil
+	}
+}
+
+// explainConstant explains the constant a reference resolved to
+func (a *Analyzer) explainConstant(ref *ast.Reference, rest query.Path) (*Origin, error) {
+	namespace := ref.Namespace
+	if namespace == "" {
+		namespace = "global"
+	}
+	definition, ok := a.constantDefinitions[namespace][ref.Name]
+	if !ok {
+		return nil, fmt.Errorf("undefined reference: %s.%s", namespace, ref.Name)
+	}
+
+	origin := &Origin{
+		Kind:        OriginConstant,
+		Description: namespace + "." + ref.Name,
+		Line:        definition.key.Line,
+		Column:      definition.key.Column,
+	}
+	if definition.value == nil {
+		origin.Description += " defined by @" + definition.directive
+		return origin, nil
+	}
+
+	from, err := a.explainExpression(definition.value, rest)
+	if err != nil {
+		return nil, err
+	}
+	origin.From = []*Origin{from}
+	return origin, nil
+}
+
+// explainEnv explains an @env lookup using the outcome recorded during analysis
+func (a *Analyzer) explainEnv(e *ast.EnvDirective, origin *Origin, rest query.Path) (*Origin, error) {
+	origin.Kind = OriginEnv
+	origin.Description = "@env"
+	if e.Type != "" {
+		origin.Description += "." + e.Type
+	}
+	origin.Description += fmt.Sprintf("(%q)", e.VarName)
+
+	lookup, ok := a.envLookups[e]
+	switch {
+	case !ok:
+		return nil, fmt.Errorf("%s at %d:%d was not evaluated", origin.Description, origin.Line, origin.Column)
+	case lookup.set:
+		value, _ := json.Marshal(lookup.value)
+		description := fmt.Sprintf("%s = %s", e.VarName, value)
+		if len(rest) > 0 {
+			description += " (value at " + rest.String() + ")"
+		}
+		origin.From = []*Origin{{Kind: OriginEnvValue, Description: description}}
+	default:
+		from, err := a.explainExpression(e.DefaultValue, rest)
+		if err != nil {
+			return nil, err
+		}
+		origin.From = []*Origin{{
+			Kind:        OriginDefault,
+			Description: e.VarName + " is unset, using the default",
+			Line:        from.Line,
+			Column:      from.Column,
+			From:        []*Origin{from},
+		}}
+	}
+	return origin, nil
+}
+
+// Resolve returns the expression a constant reference or an unset @env lookup
+// with a default evaluated to, or nil for any other expression
+// It is the transform.Resolver used to map output values to their source
+func (a *Analyzer) Resolve(expr ast.Expression) ast.Expression {
+	switch e := expr.(type) {
+	case *ast.Reference:
+		namespace := e.Namespace
+		if namespace == "" {
+			namespace = "global"
+		}
+		if definition, ok := a.constantDefinitions[namespace][e.Name]; ok && e.Path == nil {
+			return definition.value
+		}
+	case *ast.EnvDirective:
+		if lookup, ok := a.envLookups[e]; ok && !lookup.set {
+			return e.DefaultValue
+		}
+	}
+	return nil
+}
+
+// recordConstant records where a constant was defined
+func (a *Analyzer) recordConstant(namespace, name string, definition constantDefinition) {
+	if a.constantDefinitions[namespace] == nil {
+		a.constantDefinitions[namespace] = make(map[string]constantDefinition)
+	}
+	a.constantDefinitions[namespace][name] = definition
+}
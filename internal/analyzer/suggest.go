@@ -0,0 +1,110 @@
+package analyzer
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/tomdoesdev/brace/internal/ast"
+	"github.com/tomdoesdev/brace/internal/errors"
+	"github.com/tomdoesdev/brace/internal/token"
+	"github.com/tomdoesdev/brace/internal/version"
+)
+
+// recordNamespace records where a constant namespace was first declared
+func (a *Analyzer) recordNamespace(namespace string, tok token.Token) {
+	if _, ok := a.namespaces[namespace]; !ok {
+		a.namespaces[namespace] = tok
+	}
+}
+
+// recordTable records where a table path was first declared
+func (a *Analyzer) recordTable(table *ast.TableStatement) {
+	path := strings.Join(table.Path, ".")
+	if _, ok := a.tables[path]; !ok {
+		a.tables[path] = table.Token
+	}
+}
+
+// undefinedReference returns the error for a reference to an unknown constant,
+// with suggestions for likely typos in the name or namespace
+func (a *Analyzer) undefinedReference(ref *ast.Reference, namespace string) error {
+	err := &positionError{
+		code:  errors.CodeUndefinedReference,
+		start: ref.Token,
+		end:   ref.End,
+		err:   fmt.Errorf("undefined reference: %s.%s", namespace, ref.Name),
+	}
+
+	constants, known := a.constants[namespace]
+	if known {
+		if suggestion, ok := errors.Suggest(ref.Name, sortedKeys(constants)); ok {
+			err.fixes = append(err.fixes, a.referenceFix(ref, namespace, suggestion))
+		} else {
+			// The name may exist in a namespace the reference left out
+			for _, other := range sortedKeys(a.constants) {
+				if _, ok := a.constants[other][ref.Name]; ok && other != namespace {
+					err.fixes = append(err.fixes, a.referenceFix(ref, other, ref.Name))
+					break
+				}
+			}
+		}
+		if tok, ok := a.namespaces[namespace]; ok {
+			err.related = append(err.related, a.relatedAt(tok, fmt.Sprintf("namespace `%s` declared here", namespace)))
+		}
+		return err
+	}
+
+	if tok, ok := a.tables[namespace]; ok {
+		err.related = append(err.related, a.relatedAt(tok, fmt.Sprintf("`%s` is a table declared here", namespace)))
+		if version.OutputReferences.Available(a.version) {
+			replacement := &ast.Reference{Path: append(strings.Split(namespace, "."), ref.Name)}
+			err.fixes = append(err.fixes, errors.Fix{
+				Message: fmt.Sprintf("to reference a key of the output, write `%s`", replacement),
+				Edits: []errors.TextEdit{{
+					Range:   errors.TokenRange(ref.Token, ref.End),
+					NewText: replacement.String(),
+				}},
+			})
+		} else {
+			err.help = append(err.help, "references can only name constants; move shared values into @const")
+		}
+	}
+	if suggestion, ok := errors.Suggest(namespace, sortedKeys(a.constants)); ok {
+		err.fixes = append(err.fixes, a.referenceFix(ref, suggestion, ref.Name))
+		if tok, ok := a.namespaces[suggestion]; ok {
+			err.related = append(err.related, a.relatedAt(tok, fmt.Sprintf("namespace `%s` declared here", suggestion)))
+		}
+	}
+	return err
+}
+
+// referenceFix suggests replacing a reference with one to namespace.name
+func (a *Analyzer) referenceFix(ref *ast.Reference, namespace, name string) errors.Fix {
+	replacement := &ast.Reference{Name: name}
+	if namespace != "global" {
+		replacement.Namespace = namespace
+	}
+	return errors.Fix{
+		Message: fmt.Sprintf("did you mean `%s`?", replacement),
+		Edits: []errors.TextEdit{{
+			Range:   errors.TokenRange(ref.Token, ref.End),
+			NewText: replacement.String(),
+		}},
+	}
+}
+
+// relatedAt returns a related location at a token in the file being analyzed
+func (a *Analyzer) relatedAt(tok token.Token, message string) errors.RelatedLocation {
+	return errors.RelatedLocation{Message: message, File: a.filename, Range: errors.TokenRange(tok, tok)}
+}
+
+// sortedKeys returns the keys of m in order
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,195 @@
+package analyzer
+
+import (
+	"fmt"
+
+	"github.com/tomdoesdev/brace/internal/ast"
+	"github.com/tomdoesdev/brace/internal/errors"
+	"github.com/tomdoesdev/brace/internal/value"
+)
+
+// checkTypes infers the type of every value in the program and reports
+// arrays whose elements have different types
+func (a *Analyzer) checkTypes(program *ast.Program) {
+	for _, stmt := range program.Statements {
+		switch s := stmt.(type) {
+		case *ast.AssignmentStatement:
+			a.typeOf(s.Value)
+		case *ast.TableStatement:
+			a.typeOf(s.Body)
+		case *ast.DirectiveStatement:
+			if s.Name == "const" && s.Body != nil {
+				a.typeOf(s.Body)
+			}
+		}
+	}
+}
+
+// typeOf returns the type of a resolved expression, reporting mixed arrays inside it
+func (a *Analyzer) typeOf(expr ast.Expression) *value.Type {
+	switch e := expr.(type) {
+	case *ast.StringLiteral, *ast.TemplateStringLiteral:
+		return &value.Type{Kind: value.String, Source: expr}
+	case *ast.NumberLiteral:
+		return typeOfValue(e.Value, expr)
+	case *ast.BooleanLiteral:
+		return &value.Type{Kind: value.Boolean, Source: expr}
+	case *ast.NullLiteral:
+		return &value.Type{Kind: value.Null, Source: expr}
+	case *ast.Reference:
+		if e.ResolvedValue != nil {
+			return typeOfValue(e.ResolvedValue, expr)
+		}
+		// References inside @const bodies are evaluated without being resolved in place
+		resolved, err := a.resolveReferenceValue(e)
+		if err != nil {
+			return &value.Type{Kind: value.Any, Source: expr}
+		}
+		return typeOfValue(resolved, expr)
+	case *ast.EnvDirective:
+		a.checkEnvDefault(e)
+		// A resolved lookup may be null, from an unset variable's null default
+		if e.Resolved {
+			return typeOfValue(e.ResolvedValue, expr)
+		}
+		return envType(e)
+	case *ast.DirectiveExpression:
+		// Custom directives may resolve to null
+		return typeOfValue(e.ResolvedValue, expr)
+	case *ast.ArrayLiteral:
+		return a.arrayType(e)
+	case *ast.ObjectLiteral:
+		t := &value.Type{Kind: value.Object, Fields: make(map[string]*value.Field), Source: expr}
+		for _, key := range ast.SortedKeys(e) {
+			t.Fields[ast.KeyName(key)] = &value.Field{Type: a.typeOf(e.Pairs[key])}
+		}
+		return t
+	default:
+		return &value.Type{Kind: value.Any, Source: expr}
+	}
+}
+
+// arrayType returns the type of an array literal, reporting the first element
+// whose type differs from the elements before it
+func (a *Analyzer) arrayType(arr *ast.ArrayLiteral) *value.Type {
+	var elem *value.Type
+	reported := false
+	for _, element := range arr.Elements {
+		t := a.typeOf(element)
+		if elem == nil {
+			elem = t
+			continue
+		}
+		merged, mismatch := value.Unify(elem, t)
+		if mismatch != nil && !reported {
+			a.addError(a.mixedArrayError(mismatch))
+			reported = true
+		}
+		elem = merged
+	}
+	if elem != nil && elem.Kind == value.Null {
+		elem = nil
+	}
+	return &value.Type{Kind: value.Array, Elem: elem, Source: arr}
+}
+
+// mixedArrayError reports an array element whose type differs from an earlier one
+func (a *Analyzer) mixedArrayError(mismatch *value.Mismatch) error {
+	expected, found := mismatch.Expected, mismatch.Found
+	message := fmt.Sprintf("mixed array element types: expected %s, found %s", expected, found)
+	note := fmt.Sprintf("element type %s set here", expected)
+	if mismatch.Field != "" {
+		message = fmt.Sprintf("mixed array element types: expected %s for field `%s`, found %s", expected, mismatch.Field, found)
+		note = fmt.Sprintf("field `%s` is %s here", mismatch.Field, expected)
+	}
+
+	at := found.Source.(ast.Expression)
+	return &positionError{
+		code:    errors.CodeMixedArrayTypes,
+		start:   ast.ExpressionToken(at),
+		end:     ast.ExpressionEnd(at),
+		err:     fmt.Errorf("%s", message),
+		related: []errors.RelatedLocation{a.relatedExpression(expected.Source.(ast.Expression), note)},
+		help:    []string{"arrays must hold elements of one type; null may appear in any array"},
+	}
+}
+
+// relatedExpression returns a related location spanning an expression
+func (a *Analyzer) relatedExpression(expr ast.Expression, message string) errors.RelatedLocation {
+	return errors.RelatedLocation{Message: message, File: a.filename, Range: errors.TokenRange(ast.ExpressionToken(expr), ast.ExpressionEnd(expr))}
+}
+
+// checkEnvDefault reports the default of a typed @env lookup if it does not
+// have the lookup's type, whether or not the variable is set
+func (a *Analyzer) checkEnvDefault(e *ast.EnvDirective) {
+	if e.DefaultValue == nil {
+		return
+	}
+	found := a.typeOf(e.DefaultValue)
+	if e.Type == "" || fitsEnvType(envType(e), found) {
+		return
+	}
+	a.addError(&positionError{
+		code:  errors.CodeInvalidEnvDirective,
+		start: ast.ExpressionToken(e.DefaultValue),
+		end:   ast.ExpressionEnd(e.DefaultValue),
+		err:   fmt.Errorf("default of @env.%s must be %s, found %s", e.Type, envTypeName(e.Type), found),
+		help:  []string{"use a default of the lookup's type, or null"},
+	})
+}
+
+// fitsEnvType reports whether a default of type found may stand in for a
+// lookup of type expected; null fits every lookup
+func fitsEnvType(expected, found *value.Type) bool {
+	switch {
+	case expected.Kind == value.Any, found.Kind == value.Any, found.Kind == value.Null:
+		return true
+	case found.Kind != expected.Kind, expected.Integer && !found.Integer:
+		return false
+	case expected.Elem != nil && found.Elem != nil:
+		return fitsEnvType(expected.Elem, found.Elem)
+	}
+	return true
+}
+
+// envTypeName describes the values a typed @env lookup produces
+func envTypeName(envType string) string {
+	switch envType {
+	case "int":
+		return "an integer"
+	case "float":
+		return "a number"
+	case "bool":
+		return "a boolean"
+	case "list":
+		return "an array of strings"
+	default:
+		return "a string"
+	}
+}
+
+// envType returns the type an @env lookup produces before it is evaluated
+func envType(e *ast.EnvDirective) *value.Type {
+	switch e.Type {
+	case "int":
+		return &value.Type{Kind: value.Number, Integer: true, Source: e}
+	case "float":
+		return &value.Type{Kind: value.Number, Source: e}
+	case "bool":
+		return &value.Type{Kind: value.Boolean, Source: e}
+	case "list":
+		return &value.Type{Kind: value.Array, Elem: &value.Type{Kind: value.String, Source: e}, Source: e}
+	case "json":
+		return &value.Type{Kind: value.Any, Source: e}
+	default:
+		return &value.Type{Kind: value.String, Source: e}
+	}
+}
+
+// typeOfValue returns the type of a resolved value, positioned at the
+// expression that produced it
+// Values from outside the file, such as @file contents, are not required to be
+// homogeneous; their mixed arrays get elements of any type
+func typeOfValue(v interface{}, at ast.Expression) *value.Type {
+	return value.TypeOf(v, func(string) interface{} { return at })
+}
@@ -1,6 +1,11 @@
 package ast
 
-import "github.com/tomdoesdev/brace/internal/token"
+import (
+	"strconv"
+	"strings"
+
+	"github.com/tomdoesdev/brace/internal/token"
+)
 
 // Node represents any node in the AST
 // All AST nodes implement this interface
@@ -59,10 +64,12 @@ func (as *AssignmentStatement) String() string {
 
 // Directive represents @directive statements
 type DirectiveStatement struct {
-	Token      token.Token // the @ token
-	Name       string
-	Parameters []Expression
-	Body       *ObjectLiteral
+	Token          token.Token // the @ token
+	Name           string
+	Parameters     []Expression
+	Body           *ObjectLiteral
+	End            token.Token            // last token of the directive
+	ResolvedOutput map[string]interface{} // top-level output contributed by custom directives
 }
 
 func (ds *DirectiveStatement) statementNode()       { /* marker method for Statement interface */ }
@@ -74,18 +81,53 @@ func (ds *DirectiveStatement) String() string {
 // EnvDirective represents @env directives used as expressions
 type EnvDirective struct {
 	Token         token.Token // the @ token
+	Type          string      // conversion for typed lookups (@env.int etc), empty for plain @env
 	VarName       string
+	Separator     string      // element separator for @env.list
 	DefaultValue  Expression  // optional default value
 	ResolvedValue interface{} // resolved value after analysis
+	Resolved      bool        // the lookup was evaluated; ResolvedValue may be null from a default
+	End           token.Token // the closing )
 }
 
 func (ed *EnvDirective) expressionNode()      { /* marker method for Expression interface */ }
 func (ed *EnvDirective) TokenLiteral() string { return ed.Token.Literal }
 func (ed *EnvDirective) String() string {
+	out := "@env"
+	if ed.Type != "" {
+		out += "." + ed.Type
+	}
+	out += "(\"" + ed.VarName + "\""
+	if ed.Type == "list" {
+		out += ", \"" + ed.Separator + "\""
+	}
 	if ed.DefaultValue != nil {
-		return "@env(\"" + ed.VarName + "\", " + ed.DefaultValue.String() + ")"
+		out += ", " + ed.DefaultValue.String()
 	}
-	return "@env(\"" + ed.VarName + "\")"
+	return out + ")"
+}
+
+// DirectiveExpression represents custom @directive(...) expressions
+type DirectiveExpression struct {
+	Token         token.Token // the @ token
+	Name          string
+	Arguments     []Expression
+	Body          *ObjectLiteral // optional { ... } body
+	ResolvedValue interface{}    // resolved value after analysis
+	End           token.Token    // last token of the directive
+}
+
+func (de *DirectiveExpression) expressionNode()      { /* marker method for Expression interface */ }
+func (de *DirectiveExpression) TokenLiteral() string { return de.Token.Literal }
+func (de *DirectiveExpression) String() string {
+	out := "@" + de.Name + "("
+	for i, arg := range de.Arguments {
+		if i > 0 {
+			out += ", "
+		}
+		out += arg.String()
+	}
+	return out + ")"
 }
 
 // Table represents #table statements
@@ -154,6 +196,7 @@ func (nl *NullLiteral) String() string       { return "null" }
 type ArrayLiteral struct {
 	Token    token.Token // the '[' token
 	Elements []Expression
+	End      token.Token // the closing ]
 }
 
 func (al *ArrayLiteral) expressionNode()      { /* marker method for Expression interface */ }
@@ -164,23 +207,40 @@ func (al *ArrayLiteral) String() string       { return "[...]" }
 type ObjectLiteral struct {
 	Token token.Token // the '{' token
 	Pairs map[Expression]Expression
+	End   token.Token // the closing }
 }
 
 func (ol *ObjectLiteral) expressionNode()      { /* marker method for Expression interface */ }
 func (ol *ObjectLiteral) TokenLiteral() string { return ol.Token.Literal }
 func (ol *ObjectLiteral) String() string       { return "{...}" }
 
-// Reference represents constant references like :namespace.CONSTANT
+// Reference represents constant references like :namespace.CONSTANT, and
+// output path references like :$.server.host
 type Reference struct {
 	Token         token.Token // the ':' token
 	Namespace     string      // optional namespace
 	Name          string      // constant name
+	Path          []string    // keys of an output path reference; nil for constant references
 	ResolvedValue interface{} // resolved value after analysis
+	End           token.Token // the constant name, or the last key of the path
 }
 
 func (r *Reference) expressionNode()      { /* marker method for Expression interface */ }
 func (r *Reference) TokenLiteral() string { return r.Token.Literal }
 func (r *Reference) String() string {
+	if r.Path != nil {
+		var b strings.Builder
+		b.WriteString(":$")
+		for _, key := range r.Path {
+			b.WriteByte('.')
+			if isIdentifier(key) {
+				b.WriteString(key)
+			} else {
+				b.WriteString(strconv.Quote(key))
+			}
+		}
+		return b.String()
+	}
 	if r.Namespace != "" {
 		return ":" + r.Namespace + "." + r.Name
 	}
@@ -203,3 +263,14 @@ type TemplateStringPart struct {
 func (tsl *TemplateStringLiteral) expressionNode()      {}
 func (tsl *TemplateStringLiteral) TokenLiteral() string { return tsl.Token.Literal }
 func (tsl *TemplateStringLiteral) String() string       { return "`" + tsl.Value + "`" }
+
+// isIdentifier reports whether key can be written without quotes
+func isIdentifier(key string) bool {
+	for i, c := range key {
+		letter := 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z'
+		if !letter && (i == 0 || c != '_' && (c < '0' || c > '9')) {
+			return false
+		}
+	}
+	return key != ""
+}
@@ -0,0 +1,149 @@
+package ast
+
+import (
+	"sort"
+
+	"github.com/tomdoesdev/brace/internal/token"
+)
+
+// Inspect traverses the AST in depth-first order, calling f for each node
+// If f returns false, the children of that node are skipped
+// Object pairs are visited in source order
+func Inspect(node Node, f func(Node) bool) {
+	if node == nil || !f(node) {
+		return
+	}
+
+	switch n := node.(type) {
+	case *Program:
+		for _, stmt := range n.Statements {
+			Inspect(stmt, f)
+		}
+	case *AssignmentStatement:
+		Inspect(n.Name, f)
+		if n.Value != nil {
+			Inspect(n.Value, f)
+		}
+	case *DirectiveStatement:
+		for _, param := range n.Parameters {
+			Inspect(param, f)
+		}
+		if n.Body != nil {
+			Inspect(n.Body, f)
+		}
+	case *TableStatement:
+		if n.Body != nil {
+			Inspect(n.Body, f)
+		}
+	case *ObjectLiteral:
+		for _, key := range SortedKeys(n) {
+			Inspect(key, f)
+			Inspect(n.Pairs[key], f)
+		}
+	case *ArrayLiteral:
+		for _, element := range n.Elements {
+			Inspect(element, f)
+		}
+	case *EnvDirective:
+		if n.DefaultValue != nil {
+			Inspect(n.DefaultValue, f)
+		}
+	case *DirectiveExpression:
+		for _, arg := range n.Arguments {
+			Inspect(arg, f)
+		}
+		if n.Body != nil {
+			Inspect(n.Body, f)
+		}
+	case *TemplateStringLiteral:
+		for _, part := range n.Parts {
+			if !part.IsLiteral && part.Expr != nil {
+				Inspect(part.Expr, f)
+			}
+		}
+	}
+}
+
+// SortedKeys returns the keys of an object literal in source order
+func SortedKeys(obj *ObjectLiteral) []Expression {
+	keys := make([]Expression, 0, len(obj.Pairs))
+	for key := range obj.Pairs {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keyPosition(keys[i]) < keyPosition(keys[j])
+	})
+	return keys
+}
+
+// KeyName returns the output key of an object literal key
+func KeyName(key Expression) string {
+	switch k := key.(type) {
+	case *Identifier:
+		return k.Value
+	case *StringLiteral:
+		return k.Value
+	default:
+		return key.String()
+	}
+}
+
+// keyPosition returns the byte offset of an object key
+func keyPosition(key Expression) int {
+	switch k := key.(type) {
+	case *Identifier:
+		return k.Token.Position
+	case *StringLiteral:
+		return k.Token.Position
+	default:
+		return 0
+	}
+}
+
+// ExpressionToken returns the token an expression starts at
+func ExpressionToken(expr Expression) token.Token {
+	switch e := expr.(type) {
+	case *StringLiteral:
+		return e.Token
+	case *NumberLiteral:
+		return e.Token
+	case *BooleanLiteral:
+		return e.Token
+	case *NullLiteral:
+		return e.Token
+	case *ArrayLiteral:
+		return e.Token
+	case *ObjectLiteral:
+		return e.Token
+	case *Reference:
+		return e.Token
+	case *EnvDirective:
+		return e.Token
+	case *DirectiveExpression:
+		return e.Token
+	case *TemplateStringLiteral:
+		return e.Token
+	case *Identifier:
+		return e.Token
+	default:
+		return token.Token{}
+	}
+}
+
+// ExpressionEnd returns the last token of an expression
+func ExpressionEnd(expr Expression) token.Token {
+	switch e := expr.(type) {
+	case *ArrayLiteral:
+		return e.End
+	case *ObjectLiteral:
+		return e.End
+	case *Reference:
+		return e.End
+	case *EnvDirective:
+		return e.End
+	case *DirectiveExpression:
+		return e.End
+	default:
+		return ExpressionToken(expr)
+	}
+}
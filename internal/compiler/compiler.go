@@ -4,28 +4,159 @@ import (
 	"fmt"
 
 	"github.com/tomdoesdev/brace/internal/analyzer"
+	"github.com/tomdoesdev/brace/internal/ast"
+	"github.com/tomdoesdev/brace/internal/cst"
+	"github.com/tomdoesdev/brace/internal/directive"
+	"github.com/tomdoesdev/brace/internal/encrypt"
+	"github.com/tomdoesdev/brace/internal/env"
+	"github.com/tomdoesdev/brace/internal/errors"
 	"github.com/tomdoesdev/brace/internal/lexer"
 	"github.com/tomdoesdev/brace/internal/parser"
+	"github.com/tomdoesdev/brace/internal/secret"
 	"github.com/tomdoesdev/brace/internal/transform"
 )
 
 // Compiler orchestrates the compilation pipeline with enhanced error reporting
+// A Compiler keeps the results of its most recent compilation (see
+// Dependencies, Locations, Diagnostics and SourceMap), so it is not safe for
+// concurrent use; compile in parallel with one Compiler per goroutine
 type Compiler struct {
 	outputFormat transform.OutputFormat
+	directives   *directive.Registry
+	fileRoot     string // directory @file paths must stay inside (default: the source file's directory)
+	baseDir      string // directory relative @file paths resolve against (default: the source file's directory)
+	env          env.Provider
+	envPolicy    env.Policy
+	secrets      secret.Provider
+	redact       bool // replace secrets with secret.Redacted in the output
+	keepSecrets  bool // leave secrets wrapped in secret.Value in CompileValue's result
+
+	identities     []encrypt.Identity                 // keys used to decrypt @encrypted values
+	identityLoader func() ([]encrypt.Identity, error) // loads more keys the first time one is needed
+	allowEncrypted bool                               // emit encrypt.Placeholder for values no key can decrypt
+
+	syntax       *cst.Node                     // concrete syntax tree of the most recently parsed file
+	program      *ast.Program                  // analyzed program of the most recent compilation
+	analyzer     *analyzer.Analyzer            // analyzer of the most recent compilation
+	dependencies []string                      // files read by @file during the most recent compilation
+	mappings     map[string]transform.Mapping  // source of output values in the most recent compilation
+	locations    map[string]transform.Location // source locations of output values in the most recent compilation
+
+	diagnostics []errors.Diagnostic // errors of the most recent compilation
+
+	buildSourceMap bool       // build a source map for each compilation
+	sourceMap      *SourceMap // source map of the most recent compilation
 }
 
-// New creates a new compiler instance with JSON as default format
-func New() *Compiler {
-	return &Compiler{
-		outputFormat: transform.FormatJSON,
+// Option configures a Compiler
+type Option func(*Compiler)
+
+// WithDirective registers a custom directive handler for @name
+// The directive can then be used as a statement (@name(args) { body }) or as a
+// value (key = @name(args)); the handler receives the evaluated arguments and
+// body along with their positions
+func WithDirective(name string, handler directive.Handler) Option {
+	return func(c *Compiler) {
+		c.directives.Register(name, handler)
+	}
+}
+
+// WithFileRoot restricts @file to paths inside dir
+func WithFileRoot(dir string) Option {
+	return func(c *Compiler) {
+		c.fileRoot = dir
+	}
+}
+
+// WithBaseDir resolves relative @file paths against dir instead of the source
+// file's directory, e.g. when the source is read from stdin
+func WithBaseDir(dir string) Option {
+	return func(c *Compiler) {
+		c.baseDir = dir
+	}
+}
+
+// WithEnvProvider sets where @env directives read variables from
+// Use env.Map{} for hermetic compiles that ignore the process environment
+func WithEnvProvider(provider env.Provider) Option {
+	return func(c *Compiler) {
+		c.env = provider
+	}
+}
+
+// WithEnvPolicy restricts which environment variables @env may read
+func WithEnvPolicy(policy env.Policy) Option {
+	return func(c *Compiler) {
+		c.envPolicy = policy
+	}
+}
+
+// WithSecretProvider sets where @secret directives read secrets from
+func WithSecretProvider(provider secret.Provider) Option {
+	return func(c *Compiler) {
+		c.secrets = provider
+	}
+}
+
+// WithRedaction replaces every secret in the output with secret.Redacted
+func WithRedaction() Option {
+	return func(c *Compiler) {
+		c.redact = true
+	}
+}
+
+// WithSecretValues leaves secrets wrapped in secret.Value in the result of
+// CompileValue, so callers can compare them and decide how to show them
+func WithSecretValues() Option {
+	return func(c *Compiler) {
+		c.keepSecrets = true
+	}
+}
+
+// WithIdentities sets the keys used to decrypt @encrypted values
+func WithIdentities(identities ...encrypt.Identity) Option {
+	return func(c *Compiler) {
+		c.identities = append(c.identities, identities...)
+	}
+}
+
+// WithIdentityLoader adds the keys returned by load to those used to decrypt
+// @encrypted values
+// load runs once, the first time a value is decrypted, so files without
+// @encrypted never fail because of it
+func WithIdentityLoader(load func() ([]encrypt.Identity, error)) Option {
+	return func(c *Compiler) {
+		c.identityLoader = load
 	}
 }
 
+// WithAllowEncrypted emits encrypt.Placeholder instead of failing when an
+// @encrypted value cannot be decrypted with the available keys
+func WithAllowEncrypted() Option {
+	return func(c *Compiler) {
+		c.allowEncrypted = true
+	}
+}
+
+// New creates a new compiler instance with JSON as default format
+func New(opts ...Option) *Compiler {
+	return NewWithFormat(transform.FormatJSON, opts...)
+}
+
 // NewWithFormat creates a new compiler instance with specified output format
-func NewWithFormat(format transform.OutputFormat) *Compiler {
-	return &Compiler{
+func NewWithFormat(format transform.OutputFormat, opts ...Option) *Compiler {
+	c := &Compiler{
 		outputFormat: format,
+		directives:   directive.NewRegistry(),
+		env:          env.OS{},
 	}
+	c.directives.Register("file", c.fileDirective)
+	c.directives.Register("secret", c.secretDirective)
+	c.directives.Register("encrypted", c.encryptedDirective)
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
 }
 
 // SetOutputFormat sets the output format for the compiler
@@ -61,33 +192,197 @@ func (c *Compiler) CompileFileToFormat(source, filename string, format transform
 	return result, err
 }
 
-// compileWithFilename handles compilation with filename for error reporting
-func (c *Compiler) compileWithFilename(source, filename string) (string, error) {
+// Dependencies returns the files embedded with @file during the most recent
+// compilation, as absolute paths in the order they were first read
+func (c *Compiler) Dependencies() []string {
+	return append([]string(nil), c.dependencies...)
+}
+
+// Locations maps the JSON pointer of every value in the most recent
+// compilation's output to the position it was defined at (see transform.Locations)
+func (c *Compiler) Locations() map[string]transform.Location {
+	return c.locations
+}
+
+// SyntaxTree returns the concrete syntax tree of the most recently parsed file,
+// with its comments and whitespace
+func (c *Compiler) SyntaxTree() *cst.Node {
+	return c.syntax
+}
+
+// Program returns the analyzed program of the most recent compilation, with
+// its references and directives resolved in place, or nil if analysis failed
+func (c *Compiler) Program() *ast.Program {
+	return c.program
+}
+
+// Analyzer returns the analyzer of the most recent compilation, which holds its
+// constants (see analyzer.Analyzer.Constant) and the provenance of its output
+// values (see analyzer.Analyzer.Explain), or nil if analysis failed
+func (c *Compiler) Analyzer() *analyzer.Analyzer {
+	return c.analyzer
+}
+
+// Diagnostics returns the errors of the most recent compilation as structured
+// diagnostics with source ranges, for editors and CI tools
+func (c *Compiler) Diagnostics() []errors.Diagnostic {
+	return c.diagnostics
+}
+
+// addDependency records a file read during compilation
+func (c *Compiler) addDependency(path string) {
+	for _, existing := range c.dependencies {
+		if existing == path {
+			return
+		}
+	}
+	c.dependencies = append(c.dependencies, path)
+}
+
+// AuditEnv lists every environment variable a file reads, with locations and
+// policy violations, without evaluating or compiling it
+func (c *Compiler) AuditEnv(source, filename string) ([]env.Access, error) {
+	c.diagnostics = nil
+
+	if err := c.envPolicy.Validate(); err != nil {
+		return nil, c.fail(filename, err)
+	}
+
+	program, err := c.parse(source, filename)
+	if err != nil {
+		return nil, err
+	}
+
+	a := c.newAnalyzer(filename)
+	accesses := a.AuditEnv(program)
+	if errs := a.Errors(); len(errs) > 0 {
+		c.diagnostics = a.Diagnostics()
+		return accesses, fmt.Errorf("analysis errors: %v", errs)
+	}
+	return accesses, nil
+}
+
+// Parse parses a file without analyzing it, for tools such as lint rules that
+// work on the syntax alone; parse errors are also returned by Diagnostics
+func (c *Compiler) Parse(source, filename string) (*ast.Program, error) {
+	c.diagnostics = nil
+	return c.parse(source, filename)
+}
+
+// parse runs the lexer and parser over source
+func (c *Compiler) parse(source, filename string) (*ast.Program, error) {
 	// Phase 1: Lexical Analysis
 	l := lexer.New(source)
 
 	// Phase 2: Parsing with enhanced error reporting
 	p := parser.New(l, source, filename)
+	p.SetDirectives(c.directives)
 	program := p.ParseProgram()
+	c.syntax = p.SyntaxTree()
 
 	// Check for parsing errors with detailed reporting
-	if errors := p.Errors(); len(errors) > 0 {
-		return "", fmt.Errorf("parsing errors:\n%s", errors[0])
+	if errs := p.Errors(); len(errs) > 0 {
+		c.diagnostics = p.Diagnostics()
+		return nil, fmt.Errorf("parsing errors:\n%s", errs[0])
 	}
 
-	// Phase 3: Semantic Analysis
+	return program, nil
+}
+
+// newAnalyzer creates an analyzer configured with the compiler's settings
+func (c *Compiler) newAnalyzer(filename string) *analyzer.Analyzer {
 	a := analyzer.New()
-	err := a.Analyze(program)
+	a.SetDirectives(c.directives)
+	a.SetFilename(filename)
+	a.SetEnvProvider(c.env)
+	a.SetEnvPolicy(c.envPolicy)
+	return a
+}
+
+// CompileValue compiles a file to its value tree instead of a serialized document
+func (c *Compiler) CompileValue(source, filename string) (map[string]interface{}, error) {
+	program, _, err := c.analyze(source, filename)
 	if err != nil {
-		return "", fmt.Errorf("analysis error: %v", err)
+		return nil, err
 	}
+	return c.evaluate(program, filename, c.keepSecrets)
+}
 
-	// Phase 4: Code Generation with specified format
+// evaluate converts an analyzed program to its value tree, leaving secrets
+// wrapped if keepSecrets is set
+func (c *Compiler) evaluate(program *ast.Program, filename string, keepSecrets bool) (map[string]interface{}, error) {
 	t := transform.NewWithFormat(c.outputFormat)
-	output, err := t.Transform(program)
+	t.SetRedact(c.redact)
+	t.SetKeepSecrets(keepSecrets)
+	output, err := t.Evaluate(program)
+	if err != nil {
+		return nil, c.fail(filename, fmt.Errorf("generation error: %w", err))
+	}
+	return output, nil
+}
+
+// fail records err as a diagnostic for the whole file unless the failing phase
+// already reported positioned diagnostics, and returns it
+func (c *Compiler) fail(filename string, err error) error {
+	if len(c.diagnostics) == 0 {
+		c.diagnostics = []errors.Diagnostic{{Severity: errors.SeverityError, Code: errors.CodeOf(err), Message: err.Error(), File: filename}}
+	}
+	return err
+}
+
+// analyze runs every phase before code generation
+func (c *Compiler) analyze(source, filename string) (*ast.Program, *analyzer.Analyzer, error) {
+	c.dependencies = nil
+	c.program = nil
+	c.analyzer = nil
+	c.mappings = nil
+	c.locations = nil
+	c.sourceMap = nil
+	c.diagnostics = nil
+
+	if err := c.envPolicy.Validate(); err != nil {
+		return nil, nil, c.fail(filename, errors.Errorf(errors.CodeInvalidEnvPolicy, "%w", err))
+	}
+
+	// Phases 1 and 2: Lexical Analysis and Parsing
+	program, err := c.parse(source, filename)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Phase 3: Semantic Analysis
+	a := c.newAnalyzer(filename)
+	err = a.Analyze(program)
+	if err != nil {
+		c.diagnostics = a.Diagnostics()
+		return nil, nil, c.fail(filename, fmt.Errorf("analysis error: %v", err))
+	}
+
+	c.program, c.analyzer = program, a
+	c.mappings = transform.Mappings(program, source, a.Resolve)
+	c.locations = transform.Locations(c.mappings)
+	return program, a, nil
+}
+
+// compileWithFilename handles compilation with filename for error reporting
+func (c *Compiler) compileWithFilename(source, filename string) (string, error) {
+	program, _, err := c.analyze(source, filename)
 	if err != nil {
-		return "", fmt.Errorf("generation error: %v", err)
+		return "", err
 	}
 
+	// Phase 4: Code Generation with specified format
+	value, err := c.evaluate(program, filename, false)
+	if err != nil {
+		return "", err
+	}
+	output, err := transform.NewWithFormat(c.outputFormat).Encode(value)
+	if err != nil {
+		return "", c.fail(filename, fmt.Errorf("generation error: %w", err))
+	}
+
+	if c.buildSourceMap {
+		c.sourceMap = newSourceMap(filename, c.mappings, value)
+	}
 	return output, nil
 }
@@ -1,10 +1,20 @@
 package compiler
 
 import (
+	"errors"
+	"fmt"
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 
+	"github.com/tomdoesdev/brace/internal/analyzer"
+	"github.com/tomdoesdev/brace/internal/directive"
+	"github.com/tomdoesdev/brace/internal/encrypt"
+	"github.com/tomdoesdev/brace/internal/env"
+	braceerrors "github.com/tomdoesdev/brace/internal/errors"
+	"github.com/tomdoesdev/brace/internal/query"
+	"github.com/tomdoesdev/brace/internal/secret"
 	"github.com/tomdoesdev/brace/internal/transform"
 )
 
@@ -84,6 +94,7 @@ enabled = true
 
 func TestFormatSwitching(t *testing.T) {
 	source := `
+@brace "1.0.0"
 name = "test"
 value = 42
 `
@@ -116,10 +127,6 @@ value = 42
 }
 
 func TestEnvDirectiveYAML(t *testing.T) {
-	// Set test environment variable
-	os.Setenv("TEST_VAR", "test_value")
-	defer os.Unsetenv("TEST_VAR")
-
 	source := `
 @brace "0.0.1"
 
@@ -132,7 +139,7 @@ test_value = :env.TEST_VALUE
 default_value = :env.DEFAULT_VALUE
 `
 
-	compiler := NewWithFormat(transform.FormatYAML)
+	compiler := NewWithFormat(transform.FormatYAML, WithEnvProvider(env.Map{"TEST_VAR": "test_value"}))
 	output, err := compiler.Compile(source)
 	if err != nil {
 		t.Fatalf("YAML compilation failed: %v", err)
@@ -148,3 +155,771 @@ default_value = :env.DEFAULT_VALUE
 
 	t.Logf("YAML environment test output:\n%s", output)
 }
+
+func TestCustomDirectives(t *testing.T) {
+	region := func(call *directive.Call) (*directive.Result, error) {
+		if call.Statement {
+			return &directive.Result{
+				Namespace: "region",
+				Constants: map[string]interface{}{"NAME": call.Body["name"]},
+				Output:    map[string]interface{}{"region": call.Body["name"]},
+			}, nil
+		}
+		return nil, fmt.Errorf("@region must be used as a statement")
+	}
+	vaultpath := func(call *directive.Call) (*directive.Result, error) {
+		if len(call.Args) != 1 {
+			return nil, fmt.Errorf("expected 1 argument, got %d", len(call.Args))
+		}
+		return directive.Value(fmt.Sprintf("secret/data/%v", call.Args[0])), nil
+	}
+
+	source := `
+@brace "1.0.0"
+
+@region { name = "eu-west-1" }
+
+bucket = :region.NAME
+path = @vaultpath("db")
+`
+
+	compiler := New(WithDirective("region", region), WithDirective("vaultpath", vaultpath))
+	output, err := compiler.Compile(source)
+	if err != nil {
+		t.Fatalf("compilation failed: %v", err)
+	}
+
+	for _, want := range []string{`"bucket": "eu-west-1"`, `"region": "eu-west-1"`, `"path": "secret/data/db"`} {
+		if !strings.Contains(output, want) {
+			t.Errorf("expected output to contain %s, got:\n%s", want, output)
+		}
+	}
+
+	_, err = compiler.Compile("@brace \"1.0.0\"\nx = @region { name = \"a\" }\n")
+	if err == nil || !strings.Contains(err.Error(), "@region at 2:5") {
+		t.Errorf("expected positioned handler error, got %v", err)
+	}
+
+	_, err = New().Compile("@brace \"1.0.0\"\npath = @vaultpath(\"db\")\n")
+	if err == nil || !strings.Contains(err.Error(), "unknown directive in expression context: vaultpath") {
+		t.Errorf("expected unknown directive error, got %v", err)
+	}
+}
+
+func TestFileDirective(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"cert.pem":     "-----BEGIN CERTIFICATE-----",
+		"limits.json":  `{"max": 10, "ratio": 0.5}`,
+		"replicas.yml": "primary: db1\nreplicas: [db2, db3]\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	source := `@brace "1.0.0"
+cert = @file("cert.pem")
+cert_b64 = @file("cert.pem", "base64")
+limits = @file("limits.json", "json")
+db = @file("replicas.yml", "yaml")
+`
+
+	compiler := New()
+	output, err := compiler.CompileFile(source, filepath.Join(dir, "config.brace"))
+	if err != nil {
+		t.Fatalf("compilation failed: %v", err)
+	}
+
+	for _, want := range []string{
+		`"cert": "-----BEGIN CERTIFICATE-----"`,
+		`"cert_b64": "LS0tLS1CRUdJTiBDRVJUSUZJQ0FURS0tLS0t"`,
+		`"max": 10`,
+		`"ratio": 0.5`,
+		`"primary": "db1"`,
+	} {
+		if !strings.Contains(output, want) {
+			t.Errorf("expected output to contain %s, got:\n%s", want, output)
+		}
+	}
+
+	if deps := compiler.Dependencies(); len(deps) != 3 || filepath.Base(deps[0]) != "cert.pem" {
+		t.Errorf("expected cert.pem, limits.json and replicas.yml as dependencies, got %v", deps)
+	}
+
+	_, err = compiler.CompileFile("@brace \"1.0.0\"\nkey = @file(\"missing.pem\")\n", filepath.Join(dir, "config.brace"))
+	if err == nil || !strings.Contains(err.Error(), "@file at 2:7: file not found: missing.pem") {
+		t.Errorf("expected positioned missing file error, got %v", err)
+	}
+
+	_, err = compiler.CompileFile("@brace \"1.0.0\"\nkey = @file(\"../config.brace\")\n", filepath.Join(dir, "sub", "config.brace"))
+	if err == nil || !strings.Contains(err.Error(), "outside the allowed root") {
+		t.Errorf("expected root escape error, got %v", err)
+	}
+
+	output, err = New(WithBaseDir(dir)).CompileFile("@brace \"1.0.0\"\ncert = @file(\"cert.pem\")\n", "gen/app.brace")
+	if err != nil || !strings.Contains(output, "BEGIN CERTIFICATE") {
+		t.Errorf("expected @file to resolve against the base directory, got %v:\n%s", err, output)
+	}
+}
+
+func TestTypedEnvDirectives(t *testing.T) {
+	vars := env.Map{
+		"VERSION":  "1.10",
+		"ZIP":      "01234",
+		"EMPTY":    "",
+		"PORT":     "8080",
+		"RATIO":    "0.25",
+		"DEBUG":    "true",
+		"HOSTS":    "a.example.com, b.example.com",
+		"FEATURES": `{"beta": true, "limit": 3}`,
+	}
+
+	source := `@brace "1.0.0"
+version = @env("VERSION")
+zip = @env("ZIP")
+empty = @env("EMPTY", "default")
+port = @env.int("PORT")
+ratio = @env.float("RATIO")
+debug = @env.bool("DEBUG")
+hosts = @env.list("HOSTS", ",")
+features = @env.json("FEATURES")
+timeout = @env.int("TIMEOUT", 30)
+`
+
+	output, err := New(WithEnvProvider(vars)).Compile(source)
+	if err != nil {
+		t.Fatalf("compilation failed: %v", err)
+	}
+
+	for _, want := range []string{
+		`"version": "1.10"`,
+		`"zip": "01234"`,
+		`"empty": ""`,
+		`"port": 8080`,
+		`"ratio": 0.25`,
+		`"debug": true`,
+		`"b.example.com"`,
+		`"limit": 3`,
+		`"timeout": 30`,
+	} {
+		if !strings.Contains(output, want) {
+			t.Errorf("expected output to contain %s, got:\n%s", want, output)
+		}
+	}
+
+	_, err = New(WithEnvProvider(env.Map{"PORT": "eighty"})).Compile("@brace \"1.0.0\"\nport = @env.int(\"PORT\")\n")
+	if err == nil || !strings.Contains(err.Error(), `@env.int("PORT") at 2:8: environment variable PORT: cannot convert "eighty" to int`) {
+		t.Errorf("expected positioned conversion error, got %v", err)
+	}
+
+	// Defaults must have the lookup's type, whether or not the variable is set
+	for _, tt := range []struct {
+		expr    string
+		message string
+		column  int
+	}{
+		{`@env.int("UNSET", "abc")`, `default of @env.int must be an integer, found string`, 23},
+		{`@env.bool("UNSET", 5)`, `default of @env.bool must be a boolean, found number`, 24},
+		{`@env.int("PORT", 1.5)`, `default of @env.int must be an integer, found number`, 22},
+		{`@env.list("UNSET", ",", [1])`, `default of @env.list must be an array of strings, found array of number`, 29},
+	} {
+		c := New(WithEnvProvider(env.Map{"PORT": "8080"}))
+		_, err = c.Compile("@brace \"1.0.0\"\nx = " + tt.expr + "\n")
+		if err == nil || !strings.Contains(err.Error(), tt.message) {
+			t.Errorf("%s: expected %q, got %v", tt.expr, tt.message, err)
+			continue
+		}
+		if d := c.Diagnostics(); len(d) != 1 || d[0].Code != braceerrors.CodeInvalidEnvDirective || d[0].Range.Start.Line != 2 || d[0].Range.Start.Column != tt.column {
+			t.Errorf("%s: expected one %s diagnostic at 2:%d, got %+v", tt.expr, braceerrors.CodeInvalidEnvDirective, tt.column, d)
+		}
+	}
+	for _, expr := range []string{`@env.float("UNSET", 5)`, `@env.int("UNSET", null)`, `@env.json("UNSET", { a = 1 })`, `@env.list("UNSET", ",", [])`} {
+		if _, err = New(WithEnvProvider(env.Map{})).Compile("@brace \"1.0.0\"\nx = " + expr + "\n"); err != nil {
+			t.Errorf("%s: expected the default to be accepted, got %v", expr, err)
+		}
+	}
+
+	// 0.0.1 files keep the old type inference for untyped @env
+	output, err = New(WithEnvProvider(vars)).Compile("@brace \"0.0.1\"\nversion = @env(\"VERSION\")\n")
+	if err != nil {
+		t.Fatalf("compilation failed: %v", err)
+	}
+	if !strings.Contains(output, `"version": 1.1`) {
+		t.Errorf("expected legacy inference for 0.0.1, got:\n%s", output)
+	}
+}
+
+func TestEnvPolicy(t *testing.T) {
+	vars := env.Map{"APP_PORT": "8080", "AWS_SECRET": "hunter2"}
+	source := `@brace "1.0.0"
+port = @env.int("APP_PORT")
+secret = @env("AWS_SECRET")
+`
+
+	_, err := New(WithEnvProvider(vars), WithEnvPolicy(env.Policy{Allow: []string{"APP_*"}})).Compile(source)
+	if err == nil || !strings.Contains(err.Error(), `@env("AWS_SECRET") at 3:10: access to environment variable AWS_SECRET denied by policy (not in allowlist)`) {
+		t.Errorf("expected allowlist violation, got %v", err)
+	}
+
+	filePolicy := "@brace \"1.0.0\"\n@env_policy { deny = [\"*_SECRET\"] }\nsecret = @env(\"AWS_SECRET\")\n"
+	_, err = New(WithEnvProvider(vars)).Compile(filePolicy)
+	if err == nil || !strings.Contains(err.Error(), `matches deny pattern "*_SECRET"`) {
+		t.Errorf("expected in-file deny violation, got %v", err)
+	}
+
+	accesses, err := New(WithEnvPolicy(env.Policy{Deny: []string{"AWS_*"}})).AuditEnv(source, "config.brace")
+	if err != nil {
+		t.Fatalf("audit failed: %v", err)
+	}
+	if len(accesses) != 2 {
+		t.Fatalf("expected 2 accesses, got %d", len(accesses))
+	}
+	if accesses[0].Name != "APP_PORT" || accesses[0].Type != "int" || accesses[0].Line != 2 || accesses[0].Violation != "" {
+		t.Errorf("unexpected first access: %+v", accesses[0])
+	}
+	if accesses[1].Name != "AWS_SECRET" || accesses[1].Violation == "" {
+		t.Errorf("expected AWS_SECRET to violate the policy: %+v", accesses[1])
+	}
+}
+
+func TestSecrets(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "db"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "db", "password"), []byte("hunter2\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	source := `@brace "1.0.0"
+@const { PASSWORD = @secret("db/password") }
+password = :PASSWORD
+token = @secret("api/token")
+dsn = ` + "`postgres://app:${:PASSWORD}@db`" + `
+`
+	provider := secret.Chain{
+		secret.Dir(dir),
+		secret.Env{Provider: env.Map{"BRACE_SECRET_API_TOKEN": "t0k3n"}, Prefix: secret.DefaultEnvPrefix},
+	}
+
+	output, err := New(WithSecretProvider(provider)).Compile(source)
+	if err != nil {
+		t.Fatalf("compilation failed: %v", err)
+	}
+	for _, want := range []string{`"password": "hunter2"`, `"token": "t0k3n"`, `"dsn": "postgres://app:hunter2@db"`} {
+		if !strings.Contains(output, want) {
+			t.Errorf("expected output to contain %s, got:\n%s", want, output)
+		}
+	}
+
+	output, err = New(WithSecretProvider(provider), WithRedaction()).Compile(source)
+	if err != nil {
+		t.Fatalf("compilation failed: %v", err)
+	}
+	if strings.Contains(output, "hunter2") || strings.Contains(output, "t0k3n") {
+		t.Errorf("expected secrets to be redacted, got:\n%s", output)
+	}
+
+	value := secret.New("db/password", "hunter2")
+	for _, formatted := range []string{fmt.Sprint(value), fmt.Sprintf("%q", value), fmt.Sprintf("%#v", value)} {
+		if strings.Contains(formatted, "hunter2") {
+			t.Errorf("formatting a secret revealed its plaintext: %s", formatted)
+		}
+	}
+
+	_, err = New(WithSecretProvider(provider)).Compile("@brace \"1.0.0\"\nx = @secret(\"missing\")\n")
+	if err == nil || !strings.Contains(err.Error(), "@secret at 2:5: secret missing not found") {
+		t.Errorf("expected positioned missing secret error, got %v", err)
+	}
+}
+
+func TestEncryptedValues(t *testing.T) {
+	key, err := encrypt.GenerateSymmetricKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := encrypt.GenerateX25519Identity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ciphertext, err := encrypt.Encrypt("hunter2", []encrypt.Recipient{key})
+	if err != nil {
+		t.Fatal(err)
+	}
+	source := "@brace \"1.0.0\"\npassword = @encrypted(\"" + ciphertext + "\")\n"
+
+	output, err := New(WithIdentities(key)).Compile(source)
+	if err != nil {
+		t.Fatalf("compilation failed: %v", err)
+	}
+	if !strings.Contains(output, `"password": "hunter2"`) {
+		t.Errorf("expected decrypted value, got:\n%s", output)
+	}
+
+	_, err = New(WithIdentities(other)).Compile(source)
+	if err == nil || !strings.Contains(err.Error(), "@encrypted at 2:12: no matching key available") {
+		t.Errorf("expected positioned decryption error, got %v", err)
+	}
+
+	output, err = New(WithAllowEncrypted()).Compile(source)
+	if err != nil {
+		t.Fatalf("compilation failed: %v", err)
+	}
+	if !strings.Contains(output, `"password": "`+encrypt.Placeholder+`"`) {
+		t.Errorf("expected placeholder, got:\n%s", output)
+	}
+}
+
+func TestIdentityLoader(t *testing.T) {
+	key, err := encrypt.GenerateSymmetricKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ciphertext, err := encrypt.Encrypt("hunter2", []encrypt.Recipient{key})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	broken := WithIdentityLoader(func() ([]encrypt.Identity, error) {
+		return nil, fmt.Errorf("malformed key file")
+	})
+	if _, err := New(broken).Compile("@brace \"1.0.0\"\nx = 1\n"); err != nil {
+		t.Errorf("expected keys to be loaded only for @encrypted, got %v", err)
+	}
+	source := "@brace \"1.0.0\"\nx = @encrypted(\"" + ciphertext + "\")\n"
+	if _, err := New(broken).Compile(source); err == nil || !strings.Contains(err.Error(), "malformed key file") {
+		t.Errorf("expected the loader error, got %v", err)
+	}
+
+	loads := 0
+	c := New(WithIdentityLoader(func() ([]encrypt.Identity, error) {
+		loads++
+		return []encrypt.Identity{key}, nil
+	}))
+	for i := 0; i < 2; i++ {
+		if _, err := c.Compile(source); err != nil {
+			t.Fatalf("compilation failed: %v", err)
+		}
+	}
+	if loads != 1 {
+		t.Errorf("expected keys to be loaded once, got %d loads", loads)
+	}
+}
+
+func TestCompileValueLocations(t *testing.T) {
+	source := `@brace "1.0.0"
+name = "svc"
+#database.primary {
+  hosts = ["a", "b"]
+  pool = { size = 10 }
+}
+`
+
+	c := New()
+	value, err := c.CompileValue(source, "config.brace")
+	if err != nil {
+		t.Fatalf("compilation failed: %v", err)
+	}
+	if value["name"] != "svc" {
+		t.Errorf("expected name to be svc, got %v", value["name"])
+	}
+
+	expected := map[string]transform.Location{
+		"/name":                       {Line: 2, Column: 1},
+		"/database":                   {Line: 3, Column: 1},
+		"/database/primary/hosts/1":   {Line: 4, Column: 17},
+		"/database/primary/pool/size": {Line: 5, Column: 12},
+	}
+	for pointer, want := range expected {
+		if got := c.Locations()[pointer]; got != want {
+			t.Errorf("%s: expected %v, got %v", pointer, want, got)
+		}
+	}
+}
+
+func TestExplain(t *testing.T) {
+	source := `@brace "1.0.0"
+@const "db" {
+  HOST = @env("DB_HOST", "db.internal")
+  PORT = @env.int("DB_PORT", 5432)
+}
+#database {
+  host = :db.HOST
+  port = :db.PORT
+}
+#t { "a b" = { "c" = 1 } }
+`
+
+	tests := []struct {
+		path  string
+		value interface{}
+		chain []analyzer.OriginKind
+	}{
+		{"database.host", "db.internal", []analyzer.OriginKind{
+			analyzer.OriginKey, analyzer.OriginReference, analyzer.OriginConstant,
+			analyzer.OriginEnv, analyzer.OriginDefault, analyzer.OriginLiteral,
+		}},
+		{"database.port", int64(6000), []analyzer.OriginKind{
+			analyzer.OriginKey, analyzer.OriginReference, analyzer.OriginConstant,
+			analyzer.OriginEnv, analyzer.OriginEnvValue,
+		}},
+		{"database", nil, []analyzer.OriginKind{analyzer.OriginTable}},
+		{`t."a b".c`, int64(1), []analyzer.OriginKind{analyzer.OriginKey, analyzer.OriginLiteral}},
+	}
+
+	c := New(WithEnvProvider(env.Map{"DB_PORT": "6000"}))
+	explain := func(source string, path query.Path) (interface{}, *analyzer.Origin, error) {
+		output, err := c.CompileValue(source, "config.brace")
+		if err != nil {
+			return nil, nil, err
+		}
+		values, err := query.Eval(output, path)
+		if err != nil {
+			return nil, nil, err
+		}
+		origin, err := c.Analyzer().Explain(path)
+		return values[0], origin, err
+	}
+	for _, tt := range tests {
+		path, _ := query.Parse(tt.path)
+		value, origin, err := explain(source, path)
+		if err != nil {
+			t.Errorf("%s: explain failed: %v", tt.path, err)
+			continue
+		}
+		if tt.value != nil && value != tt.value {
+			t.Errorf("%s: expected value %v, got %v", tt.path, tt.value, value)
+		}
+
+		var chain []analyzer.OriginKind
+		for step := origin; step != nil; {
+			chain = append(chain, step.Kind)
+			if len(step.From) == 0 {
+				break
+			}
+			step = step.From[0]
+		}
+		if fmt.Sprint(chain) != fmt.Sprint(tt.chain) {
+			t.Errorf("%s: expected chain %v, got %v", tt.path, tt.chain, chain)
+		}
+	}
+
+	path, _ := query.Parse("database.user")
+	if _, _, err := explain(source, path); !errors.Is(err, query.ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+
+	// The last of duplicate keys wins in the output, its location and its explanation
+	duplicates := "@brace \"1.0.0\"\nx = { a = 1, a = 2, a = 3, a = 4 }\n"
+	path, _ = query.Parse("x.a")
+	for i := 0; i < 20; i++ {
+		value, origin, err := explain(duplicates, path)
+		if err != nil {
+			t.Fatalf("explain failed: %v", err)
+		}
+		if value != int64(4) || origin.Column != 28 {
+			t.Fatalf("expected the last key to win, got %v at column %d", value, origin.Column)
+		}
+		if location := c.Locations()["/x/a"]; location.Column != 28 {
+			t.Fatalf("expected the last key's location, got %+v", location)
+		}
+	}
+}
+
+func TestSourceMap(t *testing.T) {
+	source := `@brace "1.0.0"
+@const { OPTS = { pool = [1, 2] } }
+#database {
+  host = @env("DB_HOST", "db.internal")
+  opts = :OPTS,
+  "a b" = 1
+}
+`
+
+	c := New(WithEnvProvider(env.Map{}), WithSourceMap())
+	if _, err := c.CompileFile(source, "config.brace"); err != nil {
+		t.Fatalf("compilation failed: %v", err)
+	}
+	sm := c.SourceMap()
+	if sm == nil || sm.File != "config.brace" {
+		t.Fatalf("expected a source map for config.brace, got %+v", sm)
+	}
+
+	host := sm.Mappings["/database/host"]
+	if host.Key == nil || *host.Key != (transform.Span{Line: 4, Column: 3, EndLine: 4, EndColumn: 7}) {
+		t.Errorf("expected host key span at 4:3-4:7, got %+v", host.Key)
+	}
+	if host.Value != (transform.Span{Line: 4, Column: 26, EndLine: 4, EndColumn: 39}) || len(host.Via) != 1 {
+		t.Errorf("expected host mapped to the @env default via the lookup, got %+v", host)
+	}
+
+	element := sm.Mappings["/database/opts/pool/1"]
+	if element.Value != (transform.Span{Line: 2, Column: 30, EndLine: 2, EndColumn: 31}) {
+		t.Errorf("expected referenced element mapped to the constant definition, got %+v", element)
+	}
+	if len(element.Via) != 1 || element.Via[0].Line != 5 {
+		t.Errorf("expected referenced element via the reference at line 5, got %+v", element.Via)
+	}
+
+	quoted := sm.Mappings["/database/a b"]
+	if quoted.Key == nil || *quoted.Key != (transform.Span{Line: 6, Column: 3, EndLine: 6, EndColumn: 8}) {
+		t.Errorf("expected quoted key span at 6:3-6:8, got %+v", quoted.Key)
+	}
+	if c.Locations()["/database/a b"] != (transform.Location{Line: 6, Column: 3}) {
+		t.Errorf("expected quoted key location at 6:3, got %+v", c.Locations()["/database/a b"])
+	}
+
+	if New().SourceMap() != nil {
+		t.Errorf("expected no source map without WithSourceMap")
+	}
+}
+
+func TestDiagnostics(t *testing.T) {
+	source := `@brace "1.0.0"
+@const { A = 1 }
+x = :B
+y = @env("MISSING")
+`
+
+	c := New(WithEnvProvider(env.Map{}))
+	if _, err := c.CompileFile(source, "config.brace"); err == nil {
+		t.Fatalf("expected compilation to fail")
+	}
+	diagnostics := c.Diagnostics()
+	if len(diagnostics) != 2 {
+		t.Fatalf("expected 2 diagnostics, got %+v", diagnostics)
+	}
+
+	ref := diagnostics[0]
+	if ref.Code != braceerrors.CodeUndefinedReference || ref.Message != "undefined reference: global.B" || ref.File != "config.brace" {
+		t.Errorf("unexpected reference diagnostic: %+v", ref)
+	}
+	expected := braceerrors.Range{Start: braceerrors.Position{Line: 3, Column: 5}, End: braceerrors.Position{Line: 3, Column: 7}}
+	if ref.Range != expected {
+		t.Errorf("expected reference range %+v, got %+v", expected, ref.Range)
+	}
+	if diagnostics[1].Range.Start.Line != 4 || diagnostics[1].Code != braceerrors.CodeEnvNotSet {
+		t.Errorf("expected @env diagnostic on line 4, got %+v", diagnostics[1])
+	}
+
+	_, err := c.CompileFile("@brace \"1.0.0\"\nx = \n", "broken.brace")
+	if err == nil {
+		t.Fatalf("expected parse to fail")
+	}
+	if diagnostics := c.Diagnostics(); len(diagnostics) == 0 || diagnostics[0].Range.IsZero() || diagnostics[0].Code != braceerrors.CodeUnexpectedToken {
+		t.Errorf("expected a positioned E0002 parse diagnostic, got %+v", diagnostics)
+	}
+	if !strings.Contains(err.Error(), "error[E0002]: ") {
+		t.Errorf("expected the report header to include the code, got %s", err)
+	}
+
+	// Positions are left out of messages, as diagnostics carry them separately
+	typed := New(WithEnvProvider(env.Map{"PORT": "x"}))
+	if _, err := typed.CompileFile("@brace \"1.0.0\"\n#server { port = @env.int(\"PORT\") }\n", "typed.brace"); err == nil {
+		t.Fatalf("expected invalid @env.int value to fail")
+	}
+	want := `@env.int("PORT"): environment variable PORT: cannot convert "x" to int`
+	if diagnostics := typed.Diagnostics(); len(diagnostics) != 1 || diagnostics[0].Message != want || diagnostics[0].Range.Start.Column != 18 {
+		t.Errorf("expected message %q at column 18, got %+v", want, diagnostics)
+	}
+
+	if _, err := c.CompileFile("@brace \"1.0.0\"\nx = 1\n", "ok.brace"); err != nil || len(c.Diagnostics()) != 0 {
+		t.Errorf("expected no diagnostics after a successful compile, got %v %+v", err, c.Diagnostics())
+	}
+}
+
+func TestSuggestions(t *testing.T) {
+	source := `@brace "1.0.0"
+@const { VERSION = "1.2" }
+@const "db" { HOST = "x" }
+#database { host = "h" }
+v = :VERSON
+d = :dv.HOST
+t = :database.host
+`
+
+	c := New()
+	if _, err := c.CompileFile(source, "config.brace"); err == nil {
+		t.Fatalf("expected compilation to fail")
+	}
+	diagnostics := c.Diagnostics()
+	if len(diagnostics) != 3 {
+		t.Fatalf("expected 3 diagnostics, got %+v", diagnostics)
+	}
+
+	version := diagnostics[0]
+	if len(version.Fixes) != 1 || version.Fixes[0].Message != "did you mean `:VERSION`?" || version.Fixes[0].Edits[0].NewText != ":VERSION" {
+		t.Errorf("expected a fix for :VERSON, got %+v", version.Fixes)
+	}
+
+	namespace := diagnostics[1]
+	if len(namespace.Fixes) != 1 || namespace.Fixes[0].Edits[0].NewText != ":db.HOST" {
+		t.Errorf("expected a fix for :dv.HOST, got %+v", namespace.Fixes)
+	}
+	if len(namespace.Related) != 1 || namespace.Related[0].Range.Start != (braceerrors.Position{Line: 3, Column: 8}) {
+		t.Errorf("expected a note where namespace db was declared, got %+v", namespace.Related)
+	}
+
+	table := diagnostics[2]
+	if len(table.Related) != 1 || !strings.Contains(table.Related[0].Message, "is a table") {
+		t.Errorf("expected a note that database is a table, got %+v", table.Related)
+	}
+
+	report := braceerrors.NewErrorReporter(source, "config.brace").ReportDiagnostic(namespace)
+	for _, expected := range []string{"3 | @const \"db\" { HOST = \"x\" }\n  |        ---- namespace `db` declared here", "= help: did you mean `:db.HOST`?"} {
+		if !strings.Contains(report, expected) {
+			t.Errorf("expected report to contain %q, got:\n%s", expected, report)
+		}
+	}
+
+	_, err := c.CompileFile("@brace \"1.0.0\"\nkey = @fiel(\"key.pem\")\n", "config.brace")
+	if err == nil || !strings.Contains(err.Error(), "= help: did you mean `@file`?") {
+		t.Errorf("expected a directive suggestion, got %v", err)
+	}
+}
+
+func TestArrayTypes(t *testing.T) {
+	valid := []string{
+		"x = [1, 2.5, -3]",
+		"x = [\"a\", `b`, null]",
+		"x = [[1], [], [2, 3]]",
+		"x = [{ host = \"a\" }, { host = \"b\", port = 1 }, { port = 2 }]",
+		"@const { A = \"a\" }\nx = [\"b\", :A]",
+		"x = [@env.int(\"BRACE_UNSET_PORT\", 1), 2]",
+		"x = [@env(\"BRACE_UNSET_HOST\", null), 1]",
+		"@const { A = [@env(\"BRACE_UNSET_HOST\", null), 1] }\nx = :A",
+		"x = [{ \"a b\" = 1 }, { \"a b\" = 2 }]",
+	}
+	for _, source := range valid {
+		if _, err := New().Compile("@brace \"1.0.0\"\n" + source + "\n"); err != nil {
+			t.Errorf("expected %q to compile, got %v", source, err)
+		}
+	}
+
+	tests := []struct {
+		source  string
+		message string
+		column  int // of the mismatching element on line 2 or 3
+		related int // column of the element that set the type
+	}{
+		{"x = [1, \"a\", true]", "expected number, found string", 9, 6},
+		{"x = [null, \"a\", 2]", "expected string, found number", 17, 12},
+		{"x = [[1], [\"a\"]]", "expected array of number, found array of string", 11, 6},
+		{"x = [{ port = 1 }, { port = \"2\" }]", "expected number for field `port`, found string", 29, 15},
+		{"@const { A = 1 }\nx = [\"a\", :A]", "expected string, found number", 11, 6},
+		{"@const { L = [true, 1] }", "expected boolean, found number", 21, 15},
+		{"x = [{ \"a b\" = 1 }, { \"a b\" = \"2\" }]", "expected number for field `a b`, found string", 31, 16},
+	}
+	for _, tt := range tests {
+		c := New()
+		_, err := c.Compile("@brace \"1.0.0\"\n" + tt.source + "\n")
+		if err == nil || !strings.Contains(err.Error(), tt.message) {
+			t.Errorf("expected %q to fail with %q, got %v", tt.source, tt.message, err)
+			continue
+		}
+		diagnostics := c.Diagnostics()
+		if len(diagnostics) != 1 || diagnostics[0].Code != braceerrors.CodeMixedArrayTypes {
+			t.Errorf("expected one %s diagnostic for %q, got %+v", braceerrors.CodeMixedArrayTypes, tt.source, diagnostics)
+			continue
+		}
+		d := diagnostics[0]
+		if d.Range.Start.Column != tt.column || len(d.Related) != 1 || d.Related[0].Range.Start.Column != tt.related {
+			t.Errorf("%q: expected element at column %d and note at column %d, got %+v", tt.source, tt.column, tt.related, d)
+		}
+	}
+}
+
+func TestVersionFeatures(t *testing.T) {
+	tests := []struct {
+		source  string
+		message string
+	}{
+		{"x = @env.int(\"BRACE_UNSET_PORT\", 1)", "typed @env.<type> lookups require BRACE 1.0.0 or later (the file declares 0.0.1)"},
+		{"@env_policy { allow = [\"HOME\"] }", "@env_policy directives require BRACE 1.0.0 or later"},
+		{"x = @secret(\"s\")", "@secret directives require BRACE 1.0.0 or later"},
+	}
+	for _, tt := range tests {
+		c := New()
+		_, err := c.Compile("@brace \"0.0.1\"\n" + tt.source + "\n")
+		if err == nil || !strings.Contains(err.Error(), tt.message) {
+			t.Errorf("expected %q to fail with %q, got %v", tt.source, tt.message, err)
+			continue
+		}
+		diagnostics := c.Diagnostics()
+		if len(diagnostics) == 0 || diagnostics[0].Code != braceerrors.CodeUnavailableFeature {
+			t.Errorf("expected a %s diagnostic for %q, got %+v", braceerrors.CodeUnavailableFeature, tt.source, diagnostics)
+		}
+	}
+
+	if _, err := New().Compile("@brace \"1.0.0\"\nx = @env.int(\"BRACE_UNSET_PORT\", 1)\n"); err != nil {
+		t.Errorf("expected typed @env under 1.0.0 to compile, got %v", err)
+	}
+
+	for _, v := range []string{"01.0.0", "1.00.0", "1.0.+0", "1.0.-0", "1.0."} {
+		if _, err := New().Compile("@brace \"" + v + "\"\nx = 1\n"); err == nil || !strings.Contains(err.Error(), "unsupported BRACE version") {
+			t.Errorf("expected version %q to be rejected, got %v", v, err)
+		}
+	}
+}
+
+func TestOutputReferences(t *testing.T) {
+	source := `@brace "1.1.0"
+
+#api {
+    url = ` + "`http://${:$.server.host}:${:$.server.port}/v1`" + `
+    health = :$.api.url
+}
+
+#server {
+    host = @env("HOST", "localhost")
+    port = 8080
+}
+
+upstream = :$.server
+settings = @env.json("SETTINGS")
+mode = :$.settings.mode
+`
+	output, err := New(WithEnvProvider(env.Map{"SETTINGS": `{"mode": "fast"}`})).Compile(source)
+	if err != nil {
+		t.Fatalf("Compile failed: %v", err)
+	}
+	for _, want := range []string{
+		`"health": "http://localhost:8080/v1"`,
+		`"upstream": {`,
+		`"mode": "fast"`,
+	} {
+		if !strings.Contains(output, want) {
+			t.Errorf("expected output to contain %s, got\n%s", want, output)
+		}
+	}
+
+	tests := []struct {
+		source  string
+		code    string
+		message string
+	}{
+		{"#a {\n    x = :$.b.y\n}\n#b {\n    y = :$.a.x\n}\n", braceerrors.CodeReferenceCycle, "reference cycle: b.y -> a.x -> b.y"},
+		{"s = { a = :$.s }\n", braceerrors.CodeReferenceCycle, "reference cycle: s -> s.a -> s"},
+		{"#server { host = \"h\" }\nx = :$.server.hots\n", braceerrors.CodeUndefinedReference, "undefined output path: server.hots"},
+		{"@const { X = :$.y }\ny = 1\n", braceerrors.CodeUndefinedReference, "@const values cannot reference output paths"},
+	}
+	for _, tt := range tests {
+		c := New()
+		_, err := c.Compile("@brace \"1.1.0\"\n" + tt.source)
+		if err == nil || !strings.Contains(err.Error(), tt.message) {
+			t.Errorf("expected %q to fail with %q, got %v", tt.source, tt.message, err)
+			continue
+		}
+		diagnostics := c.Diagnostics()
+		if len(diagnostics) != 1 || diagnostics[0].Code != tt.code || diagnostics[0].Range.Start.Line == 0 {
+			t.Errorf("expected one positioned %s diagnostic for %q, got %+v", tt.code, tt.source, diagnostics)
+		}
+	}
+
+	c := New()
+	if _, err := c.Compile("@brace \"1.0.0\"\nx = 1\ny = :$.x\n"); err == nil || c.Diagnostics()[0].Code != braceerrors.CodeUnavailableFeature {
+		t.Errorf("expected output references to require BRACE 1.1.0, got %v", err)
+	}
+}
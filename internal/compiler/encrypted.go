@@ -0,0 +1,49 @@
+package compiler
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/tomdoesdev/brace/internal/directive"
+	"github.com/tomdoesdev/brace/internal/encrypt"
+	"github.com/tomdoesdev/brace/internal/secret"
+)
+
+// encryptedDirective implements @encrypted("...") which decrypts a value encrypted with brace encrypt
+// Decrypted values are secrets, so they are redacted in errors and with -redact
+func (c *Compiler) encryptedDirective(call *directive.Call) (*directive.Result, error) {
+	if call.Statement {
+		return nil, fmt.Errorf("@encrypted can only be used as a value")
+	}
+	if len(call.Args) != 1 {
+		return nil, fmt.Errorf("@encrypted expects exactly one ciphertext argument, got %d", len(call.Args))
+	}
+
+	ciphertext, ok := call.Args[0].(string)
+	if !ok {
+		return nil, fmt.Errorf("@encrypted ciphertext must be a string, got %T", call.Args[0])
+	}
+
+	if c.identityLoader != nil {
+		identities, err := c.identityLoader()
+		if err != nil {
+			return nil, err
+		}
+		c.identities = append(c.identities, identities...)
+		c.identityLoader = nil
+	}
+
+	plaintext, err := encrypt.Decrypt(ciphertext, c.identities)
+	if errors.Is(err, encrypt.ErrNoIdentity) {
+		if c.allowEncrypted {
+			return directive.Value(encrypt.Placeholder), nil
+		}
+		ids, _ := encrypt.RecipientIDs(ciphertext)
+		return nil, fmt.Errorf("%v (encrypted to %v)", err, ids)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return directive.Value(secret.New("encrypted", plaintext)), nil
+}
@@ -0,0 +1,147 @@
+package compiler
+
+import (
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/tomdoesdev/brace/internal/directive"
+	"github.com/tomdoesdev/brace/internal/value"
+)
+
+// File embedding modes supported by @file
+const (
+	fileModeText   = "text"
+	fileModeBase64 = "base64"
+	fileModeJSON   = "json"
+	fileModeYAML   = "yaml"
+)
+
+// fileDirective implements @file("path", "mode") which embeds a file's contents as a value
+// Paths are resolved relative to the directory of the file being compiled and
+// must stay inside the compiler's file root
+func (c *Compiler) fileDirective(call *directive.Call) (*directive.Result, error) {
+	if call.Statement {
+		return nil, fmt.Errorf("@file can only be used as a value")
+	}
+	if len(call.Args) < 1 || len(call.Args) > 2 {
+		return nil, fmt.Errorf("@file expects a path and an optional mode, got %d arguments", len(call.Args))
+	}
+
+	path, ok := call.Args[0].(string)
+	if !ok {
+		return nil, fmt.Errorf("@file path must be a string, got %T", call.Args[0])
+	}
+
+	mode := fileModeText
+	if len(call.Args) == 2 {
+		mode, ok = call.Args[1].(string)
+		if !ok {
+			return nil, fmt.Errorf("@file mode must be a string, got %T", call.Args[1])
+		}
+	}
+
+	resolved, err := c.resolveFilePath(path, call.Filename)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(resolved)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("file not found: %s", path)
+		}
+		return nil, fmt.Errorf("cannot read file %s: %v", path, err)
+	}
+
+	decoded, err := decodeFile(data, mode)
+	if err != nil {
+		return nil, fmt.Errorf("cannot decode %s as %s: %v", path, mode, err)
+	}
+	return directive.Value(decoded), nil
+}
+
+// resolveFilePath resolves path relative to the including file and enforces the file root
+func (c *Compiler) resolveFilePath(path, filename string) (string, error) {
+	baseDir := c.sourceDir(filename)
+
+	resolved := path
+	if !filepath.IsAbs(resolved) {
+		resolved = filepath.Join(baseDir, resolved)
+	}
+	resolved, err := filepath.Abs(resolved)
+	if err != nil {
+		return "", fmt.Errorf("cannot resolve path %s: %v", path, err)
+	}
+
+	root := c.fileRoot
+	if root == "" {
+		root = baseDir
+	}
+	root, err = filepath.Abs(root)
+	if err != nil {
+		return "", fmt.Errorf("cannot resolve file root %s: %v", c.fileRoot, err)
+	}
+
+	if !withinRoot(root, resolved) {
+		return "", fmt.Errorf("file %s is outside the allowed root %s", path, root)
+	}
+
+	// Record the file even if it is missing so watchers notice when it appears
+	c.addDependency(resolved)
+
+	// Compare real paths too so symlinks cannot be used to escape the root
+	realPath, err := filepath.EvalSymlinks(resolved)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", fmt.Errorf("file not found: %s", path)
+		}
+		return "", fmt.Errorf("cannot read file %s: %v", path, err)
+	}
+	if realRoot, err := filepath.EvalSymlinks(root); err == nil {
+		root = realRoot
+	}
+	if !withinRoot(root, realPath) {
+		return "", fmt.Errorf("file %s is outside the allowed root %s", path, root)
+	}
+
+	return realPath, nil
+}
+
+// withinRoot reports whether path is root or inside it
+func withinRoot(root, path string) bool {
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		return false
+	}
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// sourceDir returns the directory relative paths in filename are resolved against
+func (c *Compiler) sourceDir(filename string) string {
+	if c.baseDir != "" {
+		return c.baseDir
+	}
+	if filename == "" || filename == "<stdin>" {
+		return "."
+	}
+	return filepath.Dir(filename)
+}
+
+// decodeFile converts raw file contents according to an @file mode
+func decodeFile(data []byte, mode string) (interface{}, error) {
+	switch mode {
+	case fileModeText:
+		return string(data), nil
+	case fileModeBase64:
+		return base64.StdEncoding.EncodeToString(data), nil
+	case fileModeJSON:
+		return value.FromJSON(data)
+	case fileModeYAML:
+		return value.FromYAML(data)
+	default:
+		return nil, fmt.Errorf("unknown mode %q (supported modes: text, base64, json, yaml)", mode)
+	}
+}
@@ -0,0 +1,40 @@
+package compiler
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/tomdoesdev/brace/internal/directive"
+	"github.com/tomdoesdev/brace/internal/secret"
+)
+
+// secretDirective implements @secret("name") which resolves a secret from the configured provider
+// The value stays wrapped in secret.Value until output generation so it is never
+// shown in error messages or debug output
+func (c *Compiler) secretDirective(call *directive.Call) (*directive.Result, error) {
+	if call.Statement {
+		return nil, fmt.Errorf("@secret can only be used as a value")
+	}
+	if len(call.Args) != 1 {
+		return nil, fmt.Errorf("@secret expects exactly one name argument, got %d", len(call.Args))
+	}
+
+	name, ok := call.Args[0].(string)
+	if !ok {
+		return nil, fmt.Errorf("@secret name must be a string, got %T", call.Args[0])
+	}
+
+	if c.secrets == nil {
+		return nil, fmt.Errorf("cannot resolve secret %s: no secret provider configured", name)
+	}
+
+	plaintext, err := c.secrets.Secret(name)
+	if errors.Is(err, secret.ErrNotFound) {
+		return nil, fmt.Errorf("secret %s not found", name)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return directive.Value(secret.New(name, plaintext)), nil
+}
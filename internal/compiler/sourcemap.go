@@ -0,0 +1,70 @@
+package compiler
+
+import (
+	"strconv"
+
+	"github.com/tomdoesdev/brace/internal/query"
+	"github.com/tomdoesdev/brace/internal/transform"
+)
+
+// SourceMapVersion is the version of the source map format
+const SourceMapVersion = 1
+
+// SourceMap maps the JSON pointer of every value in a compiled document to
+// the BRACE source it came from
+type SourceMap struct {
+	Version  int                          `json:"version"`
+	File     string                       `json:"file"`
+	Mappings map[string]transform.Mapping `json:"mappings"`
+}
+
+// WithSourceMap makes the compiler build a source map for each compilation,
+// available from SourceMap
+func WithSourceMap() Option {
+	return func(c *Compiler) {
+		c.buildSourceMap = true
+	}
+}
+
+// SourceMap returns the source map of the most recent compilation, or nil if
+// the compiler was not created with WithSourceMap
+func (c *Compiler) SourceMap() *SourceMap {
+	return c.sourceMap
+}
+
+// newSourceMap copies the mappings of a compilation and maps every remaining
+// value in output (such as the contents of @file) to its parent
+func newSourceMap(filename string, mappings map[string]transform.Mapping, output map[string]interface{}) *SourceMap {
+	sm := &SourceMap{
+		Version:  SourceMapVersion,
+		File:     filename,
+		Mappings: make(map[string]transform.Mapping, len(mappings)),
+	}
+	for pointer, m := range mappings {
+		sm.Mappings[pointer] = m
+	}
+	fillSourceMap(sm.Mappings, "", output)
+	return sm
+}
+
+// fillSourceMap gives values without a mapping the mapping of their parent
+func fillSourceMap(mappings map[string]transform.Mapping, pointer string, value interface{}) {
+	parent := mappings[pointer]
+	visit := func(child string, v interface{}) {
+		if _, ok := mappings[child]; !ok {
+			mappings[child] = transform.Mapping{Value: parent.Value, Via: parent.Via}
+		}
+		fillSourceMap(mappings, child, v)
+	}
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, child := range v {
+			visit(pointer+"/"+query.EscapePointer(key), child)
+		}
+	case []interface{}:
+		for i, child := range v {
+			visit(pointer+"/"+strconv.Itoa(i), child)
+		}
+	}
+}
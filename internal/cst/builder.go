@@ -0,0 +1,133 @@
+package cst
+
+import (
+	"strings"
+
+	"github.com/tomdoesdev/brace/internal/token"
+)
+
+// Builder builds a tree from the tokens a parser reads
+// Tokens are added as they are read from the lexer and placed in the
+// innermost open node once the parser has moved past them, so a parser
+// with lookahead can open a node at its current token
+type Builder struct {
+	source      string
+	stack       []*Node
+	pending     []*Token // tokens read but not yet placed
+	nextLeading []Trivia // trivia read since the last token that leads the next
+	last        *Token   // last non-comment token read
+	offset      int      // end of the last token read
+	newline     bool     // whether a line break was read since last
+	eof         bool     // whether the EOF token was read
+}
+
+// NewBuilder returns a builder for source with the file node open
+func NewBuilder(source string) *Builder {
+	return &Builder{source: source, stack: []*Node{{Kind: File}}}
+}
+
+// Add records a token read from the lexer
+// Comments and the whitespace before each token become trivia
+// Tokens after the first EOF are ignored
+func (b *Builder) Add(tok token.Token) {
+	if b.eof {
+		return
+	}
+	b.eof = tok.Type == token.EOF
+
+	start := min(max(tok.Position, b.offset), len(b.source))
+	end := min(start+tok.Length, len(b.source))
+
+	b.trivia(Whitespace, b.source[b.offset:start])
+	b.offset = end
+	if tok.Type == token.COMMENT {
+		b.trivia(Comment, b.source[start:end])
+		return
+	}
+
+	leading := b.leading()
+	t := &Token{Token: tok, Text: b.source[start:end], Leading: leading}
+	b.pending = append(b.pending, t)
+	b.last, b.newline = t, false
+}
+
+// leading returns the trivia collected for the next token
+func (b *Builder) leading() []Trivia {
+	leading := b.nextLeading
+	b.nextLeading = nil
+	return leading
+}
+
+// trivia adds trivia, as trailing trivia of the last token until a line break
+func (b *Builder) trivia(kind TriviaKind, text string) {
+	if text == "" {
+		return
+	}
+	if b.last != nil && !b.newline {
+		trailing := text
+		if kind == Whitespace {
+			if i := strings.IndexByte(text, '\n'); i >= 0 {
+				trailing, text = text[:i], text[i:]
+				b.newline = true
+			} else {
+				text = ""
+			}
+		} else {
+			text = ""
+		}
+		if trailing != "" {
+			b.last.Trailing = append(b.last.Trailing, Trivia{Kind: kind, Text: trailing})
+		}
+		if text == "" {
+			return
+		}
+	}
+	b.nextLeading = append(b.nextLeading, Trivia{Kind: kind, Text: text})
+}
+
+// place moves pending tokens that start before end, or at it if inclusive,
+// into the innermost open node
+func (b *Builder) place(end int, inclusive bool) {
+	node := b.stack[len(b.stack)-1]
+	i := 0
+	for ; i < len(b.pending); i++ {
+		pos := b.pending[i].Position
+		if pos > end || (pos == end && !inclusive) {
+			break
+		}
+		node.Children = append(node.Children, b.pending[i])
+	}
+	b.pending = b.pending[i:]
+}
+
+// Start opens a node beginning at tok
+func (b *Builder) Start(kind Kind, tok token.Token) *Node {
+	b.place(tok.Position, false)
+	node := &Node{Kind: kind}
+	parent := b.stack[len(b.stack)-1]
+	parent.Children = append(parent.Children, node)
+	b.stack = append(b.stack, node)
+	return node
+}
+
+// Finish closes node, and any nodes left open inside it, ending at tok
+func (b *Builder) Finish(node *Node, tok token.Token) {
+	b.place(tok.Position, true)
+	for i := len(b.stack) - 1; i > 0; i-- {
+		if b.stack[i] == node {
+			b.stack = b.stack[:i]
+			return
+		}
+	}
+}
+
+// Tree closes every open node and returns the file node
+// Call it once the EOF token has been added
+func (b *Builder) Tree() *Node {
+	b.stack = b.stack[:1]
+	for _, tok := range b.pending {
+		b.stack[0].Children = append(b.stack[0].Children, tok)
+	}
+	b.pending = nil
+	return b.stack[0]
+}
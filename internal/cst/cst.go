@@ -0,0 +1,220 @@
+package cst
+
+import (
+	"strings"
+
+	"github.com/tomdoesdev/brace/internal/token"
+)
+
+// Kind is the kind of syntax a node covers
+type Kind int
+
+const (
+	File          Kind = iota
+	Directive          // @name ... statements
+	Assignment         // name = value
+	Table              // #path { ... }
+	Object             // { ... }
+	Pair               // key = value inside an object
+	Array              // [ ... ]
+	Env                // @env(...) and @env.<type>(...)
+	DirectiveCall      // custom @name(...) expressions
+	Reference          // :name and :namespace.name
+	Literal            // identifiers, strings, numbers, booleans, null and template strings
+)
+
+func (k Kind) String() string {
+	switch k {
+	case File:
+		return "File"
+	case Directive:
+		return "Directive"
+	case Assignment:
+		return "Assignment"
+	case Table:
+		return "Table"
+	case Object:
+		return "Object"
+	case Pair:
+		return "Pair"
+	case Array:
+		return "Array"
+	case Env:
+		return "Env"
+	case DirectiveCall:
+		return "DirectiveCall"
+	case Reference:
+		return "Reference"
+	case Literal:
+		return "Literal"
+	default:
+		return "Unknown"
+	}
+}
+
+// TriviaKind is the kind of source text between tokens
+type TriviaKind int
+
+const (
+	Whitespace TriviaKind = iota // spaces, tabs and line breaks
+	Comment                      // // and /* */ comments
+)
+
+// Trivia is source text between tokens that the parser skips
+type Trivia struct {
+	Kind TriviaKind
+	Text string
+}
+
+// Element is a child of a node: a *Node or a *Token
+type Element interface {
+	element()
+}
+
+// Token is a token with its source text and the trivia around it
+// Trivia after a token up to the end of its line is trailing; the rest,
+// including the line break, leads the next token
+type Token struct {
+	token.Token
+	Text     string
+	Leading  []Trivia
+	Trailing []Trivia
+}
+
+func (t *Token) element() {}
+
+func (t *Token) write(out *strings.Builder) {
+	for _, trivia := range t.Leading {
+		out.WriteString(trivia.Text)
+	}
+	out.WriteString(t.Text)
+	for _, trivia := range t.Trailing {
+		out.WriteString(trivia.Text)
+	}
+}
+
+// Node is a piece of syntax and the tokens and nodes inside it
+// The file node ends with the EOF token, whose leading trivia is the text
+// after the last token
+type Node struct {
+	Kind     Kind
+	Children []Element
+}
+
+func (n *Node) element() {}
+
+// String returns the source text the node was parsed from, byte for byte
+func (n *Node) String() string {
+	var out strings.Builder
+	for _, tok := range n.Tokens() {
+		tok.write(&out)
+	}
+	return out.String()
+}
+
+// Tokens returns the tokens in the node in source order
+func (n *Node) Tokens() []*Token {
+	var tokens []*Token
+	for _, child := range n.Children {
+		switch c := child.(type) {
+		case *Token:
+			tokens = append(tokens, c)
+		case *Node:
+			tokens = append(tokens, c.Tokens()...)
+		}
+	}
+	return tokens
+}
+
+// Nodes returns the nodes directly inside n
+func (n *Node) Nodes() []*Node {
+	var nodes []*Node
+	for _, child := range n.Children {
+		if node, ok := child.(*Node); ok {
+			nodes = append(nodes, node)
+		}
+	}
+	return nodes
+}
+
+// Leading returns the trivia before the node
+func (n *Node) Leading() []Trivia {
+	if tokens := n.Tokens(); len(tokens) > 0 {
+		return tokens[0].Leading
+	}
+	return nil
+}
+
+// Trailing returns the trivia after the node on its last line
+func (n *Node) Trailing() []Trivia {
+	if tokens := n.Tokens(); len(tokens) > 0 {
+		return tokens[len(tokens)-1].Trailing
+	}
+	return nil
+}
+
+// Inspect traverses the tree in depth-first order, calling f for each node
+// If f returns false, the children of that node are skipped
+func Inspect(n *Node, f func(*Node) bool) {
+	if n == nil || !f(n) {
+		return
+	}
+	for _, child := range n.Nodes() {
+		Inspect(child, f)
+	}
+}
+
+// DocComment returns the text of the comments documenting the node: the
+// block of comments directly above it, or else a comment after it on its
+// last line
+// Comment markers are removed, and comments holding tool directives such as
+// // brace:ignore are left out
+func (n *Node) DocComment() string {
+	var block []string
+	for _, trivia := range n.Leading() {
+		switch {
+		case trivia.Kind == Comment:
+			if text := commentText(trivia.Text); text != "" {
+				block = append(block, text)
+			}
+		case strings.Count(trivia.Text, "\n") > 1:
+			// A blank line separates the comments above from the node
+			block = nil
+		}
+	}
+	if len(block) > 0 {
+		return strings.Join(block, "\n")
+	}
+
+	for _, trivia := range n.Trailing() {
+		if trivia.Kind == Comment {
+			if text := commentText(trivia.Text); text != "" {
+				block = append(block, text)
+			}
+		}
+	}
+	return strings.Join(block, "\n")
+}
+
+// commentText returns the text of a comment without its markers, or "" for
+// comments holding tool directives
+func commentText(comment string) string {
+	var lines []string
+	if strings.HasPrefix(comment, "//") {
+		lines = []string{strings.TrimPrefix(comment, "//")}
+	} else {
+		comment = strings.TrimSuffix(strings.TrimPrefix(comment, "/*"), "*/")
+		for _, line := range strings.Split(comment, "\n") {
+			lines = append(lines, strings.TrimLeft(strings.TrimSpace(line), "*"))
+		}
+	}
+
+	for i, line := range lines {
+		lines[i] = strings.TrimSpace(line)
+	}
+	text := strings.TrimSpace(strings.Join(lines, "\n"))
+	if strings.HasPrefix(text, "brace:") {
+		return ""
+	}
+	return text
+}
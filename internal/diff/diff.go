@@ -0,0 +1,114 @@
+package diff
+
+import (
+	"reflect"
+	"sort"
+
+	"github.com/tomdoesdev/brace/internal/query"
+	"github.com/tomdoesdev/brace/internal/secret"
+)
+
+// Kind classifies a change
+type Kind string
+
+const (
+	Added   Kind = "added"
+	Removed Kind = "removed"
+	Changed Kind = "changed"
+)
+
+// Change is a difference between two value trees at one path
+type Change struct {
+	Kind Kind
+	Path query.Path
+	Old  interface{} // nil for Added
+	New  interface{} // nil for Removed
+}
+
+// Compare returns the changes that turn old into new, ordered by path
+// Objects are compared key by key and arrays index by index; any other
+// difference, including a change of type, is reported at the differing path
+// Secrets are equal if their names and plaintexts are, and equal a string
+// holding their plaintext, as in compiled output
+func Compare(old, new interface{}) []Change {
+	var changes []Change
+	compare(&changes, nil, old, new)
+	return changes
+}
+
+func compare(changes *[]Change, path query.Path, old, new interface{}) {
+	switch o := old.(type) {
+	case map[string]interface{}:
+		if n, ok := new.(map[string]interface{}); ok {
+			compareObjects(changes, path, o, n)
+			return
+		}
+	case []interface{}:
+		if n, ok := new.([]interface{}); ok {
+			compareArrays(changes, path, o, n)
+			return
+		}
+	}
+
+	if !reflect.DeepEqual(plain(old, new), plain(new, old)) {
+		*changes = append(*changes, Change{Kind: Changed, Path: path, Old: old, New: new})
+	}
+}
+
+func compareObjects(changes *[]Change, path query.Path, old, new map[string]interface{}) {
+	keys := make([]string, 0, len(old)+len(new))
+	for key := range old {
+		keys = append(keys, key)
+	}
+	for key := range new {
+		if _, ok := old[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		child := extend(path, query.Segment{Kind: query.KeySegment, Key: key})
+		oldValue, inOld := old[key]
+		newValue, inNew := new[key]
+		switch {
+		case !inNew:
+			*changes = append(*changes, Change{Kind: Removed, Path: child, Old: oldValue})
+		case !inOld:
+			*changes = append(*changes, Change{Kind: Added, Path: child, New: newValue})
+		default:
+			compare(changes, child, oldValue, newValue)
+		}
+	}
+}
+
+func compareArrays(changes *[]Change, path query.Path, old, new []interface{}) {
+	for i := 0; i < len(old) || i < len(new); i++ {
+		child := extend(path, query.Segment{Kind: query.IndexSegment, Index: i})
+		switch {
+		case i >= len(new):
+			*changes = append(*changes, Change{Kind: Removed, Path: child, Old: old[i]})
+		case i >= len(old):
+			*changes = append(*changes, Change{Kind: Added, Path: child, New: new[i]})
+		default:
+			compare(changes, child, old[i], new[i])
+		}
+	}
+}
+
+// plain returns the plaintext of v if it is a secret compared with a string
+func plain(v, other interface{}) interface{} {
+	if s, ok := v.(secret.Value); ok {
+		if _, ok := other.(string); ok {
+			return s.Reveal()
+		}
+	}
+	return v
+}
+
+// extend returns a copy of path with segment appended
+func extend(path query.Path, segment query.Segment) query.Path {
+	result := make(query.Path, len(path), len(path)+1)
+	copy(result, path)
+	return append(result, segment)
+}
@@ -0,0 +1,64 @@
+package diff
+
+import (
+	"testing"
+
+	"github.com/tomdoesdev/brace/internal/secret"
+)
+
+func TestCompare(t *testing.T) {
+	old := map[string]interface{}{
+		"name": "svc",
+		"database": map[string]interface{}{
+			"port":  int64(5432),
+			"user":  "admin",
+			"hosts": []interface{}{"a", "b"},
+		},
+		"debug": false,
+	}
+	new := map[string]interface{}{
+		"name": "svc",
+		"database": map[string]interface{}{
+			"port":  int64(5433),
+			"tls":   true,
+			"hosts": []interface{}{"a", "c", "d"},
+		},
+		"debug": "false",
+	}
+
+	expected := []struct {
+		kind Kind
+		path string
+	}{
+		{Changed, "database.hosts[1]"},
+		{Added, "database.hosts[2]"},
+		{Changed, "database.port"},
+		{Added, "database.tls"},
+		{Removed, "database.user"},
+		{Changed, "debug"},
+	}
+
+	changes := Compare(old, new)
+	if len(changes) != len(expected) {
+		t.Fatalf("expected %d changes, got %d: %v", len(expected), len(changes), changes)
+	}
+	for i, want := range expected {
+		if changes[i].Kind != want.kind || changes[i].Path.String() != want.path {
+			t.Errorf("change %d: expected %s %s, got %s %s", i, want.kind, want.path, changes[i].Kind, changes[i].Path)
+		}
+	}
+
+	if changes := Compare(old, old); len(changes) != 0 {
+		t.Errorf("expected no changes comparing a value with itself, got %v", changes)
+	}
+}
+
+func TestCompareSecrets(t *testing.T) {
+	old := map[string]interface{}{"a": secret.New("db", "hunter2"), "b": secret.New("db", "hunter2"), "c": "hunter2"}
+	new := map[string]interface{}{"a": secret.New("db", "swordfish"), "b": secret.New("other", "hunter2"), "c": secret.New("db", "hunter2")}
+
+	changes := Compare(old, new)
+	if len(changes) != 2 || changes[0].Path.String() != "a" || changes[1].Path.String() != "b" {
+		t.Errorf("expected changes to a and b, got %v", changes)
+	}
+}
@@ -0,0 +1,119 @@
+package directive
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/tomdoesdev/brace/internal/token"
+)
+
+// Builtin directive names that cannot be overridden by custom handlers
+var builtins = map[string]bool{
+	"brace":      true,
+	"const":      true,
+	"env":        true,
+	"env_policy": true,
+}
+
+// Call describes a single use of a custom directive
+type Call struct {
+	Name       string                 // directive name without the @
+	Token      token.Token            // the @ token
+	Args       []interface{}          // evaluated arguments from (...)
+	ArgTokens  []token.Token          // first token of each argument
+	Body       map[string]interface{} // evaluated { ... } body, nil when absent
+	BodyTokens map[string]token.Token // key token of each body entry
+	Filename   string                 // file the directive appears in
+	Statement  bool                   // true when used as a statement rather than a value
+}
+
+// Result is what a directive handler produces
+// Expression directives return a Value; statement directives may define
+// constants and contribute top-level output keys
+type Result struct {
+	Value     interface{}            // value of a directive used as an expression
+	Namespace string                 // namespace for Constants (default "global")
+	Constants map[string]interface{} // constants defined by a statement directive
+	Output    map[string]interface{} // keys merged into the top-level output
+}
+
+// Handler executes a custom directive
+type Handler func(call *Call) (*Result, error)
+
+// Registry holds the custom directives known to a compilation
+type Registry struct {
+	handlers map[string]Handler
+}
+
+// NewRegistry creates an empty directive registry
+func NewRegistry() *Registry {
+	return &Registry{
+		handlers: make(map[string]Handler),
+	}
+}
+
+// Register adds a handler for @name
+// It panics if the name is empty, reserved by a builtin directive or already registered
+func (r *Registry) Register(name string, handler Handler) {
+	if name == "" {
+		panic("directive: empty directive name")
+	}
+	if handler == nil {
+		panic(fmt.Sprintf("directive: nil handler for @%s", name))
+	}
+	if builtins[name] {
+		panic(fmt.Sprintf("directive: @%s is a builtin directive", name))
+	}
+	if _, exists := r.handlers[name]; exists {
+		panic(fmt.Sprintf("directive: @%s registered twice", name))
+	}
+	r.handlers[name] = handler
+}
+
+// Lookup returns the handler registered for name
+func (r *Registry) Lookup(name string) (Handler, bool) {
+	if r == nil {
+		return nil, false
+	}
+	handler, ok := r.handlers[name]
+	return handler, ok
+}
+
+// Names returns the registered directive names in order
+func (r *Registry) Names() []string {
+	if r == nil {
+		return nil
+	}
+	names := make([]string, 0, len(r.handlers))
+	for name := range r.handlers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Has reports whether a handler is registered for name
+func (r *Registry) Has(name string) bool {
+	_, ok := r.Lookup(name)
+	return ok
+}
+
+// IsBuiltin reports whether name is a builtin directive
+func IsBuiltin(name string) bool {
+	return builtins[name]
+}
+
+// Builtins returns the names of the builtin directives in order
+func Builtins() []string {
+	names := make([]string, 0, len(builtins))
+	for name := range builtins {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Value is a convenience for handlers that only produce a value
+func Value(v interface{}) *Result {
+	return &Result{Value: v}
+}
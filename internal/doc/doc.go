@@ -0,0 +1,243 @@
+package doc
+
+import (
+	"encoding/json"
+	"fmt"
+	"unicode/utf8"
+
+	"github.com/tomdoesdev/brace/internal/ast"
+	"github.com/tomdoesdev/brace/internal/cst"
+	"github.com/tomdoesdev/brace/internal/query"
+	"github.com/tomdoesdev/brace/internal/secret"
+	"github.com/tomdoesdev/brace/internal/value"
+)
+
+// Kind is the kind of a documented item
+type Kind string
+
+const (
+	KindKey      Kind = "key"      // an assignment or an object key
+	KindTable    Kind = "table"    // a #table
+	KindConstant Kind = "constant" // an @const value
+)
+
+// Entry documents a key, table or constant
+type Entry struct {
+	Kind        Kind        `json:"kind"`
+	Path        string      `json:"path"`             // key path such as server.port, or :namespace.NAME for constants
+	Type        string      `json:"type"`             // type of the resolved value, such as number or array of string
+	Value       interface{} `json:"value,omitempty"`  // resolved value, with secrets redacted
+	Secret      bool        `json:"secret,omitempty"` // whether the value holds a secret
+	Env         string      `json:"env,omitempty"`    // environment variable the value is read from
+	Default     string      `json:"default,omitempty"`
+	Description string      `json:"description,omitempty"`
+	Line        int         `json:"line"`
+}
+
+// Reference documents the keys, tables and constants of a file
+type Reference struct {
+	File      string  `json:"file"`
+	Entries   []Entry `json:"entries"`   // keys and tables in source order
+	Constants []Entry `json:"constants"` // constants in source order
+}
+
+// ConstantFunc returns the value of a constant; the global namespace is ""
+type ConstantFunc func(namespace, name string) (interface{}, bool)
+
+// Build documents a compiled file
+// Descriptions are the doc comments of the syntax tree (see cst.Node.DocComment),
+// values are looked up in output and constant
+func Build(file string, program *ast.Program, tree *cst.Node, output map[string]interface{}, constant ConstantFunc) *Reference {
+	b := &builder{
+		ref:      &Reference{File: file, Entries: []Entry{}, Constants: []Entry{}},
+		comments: make(map[int]string),
+		output:   output,
+		constant: constant,
+		seen:     make(map[string]int),
+	}
+
+	cst.Inspect(tree, func(n *cst.Node) bool {
+		switch n.Kind {
+		case cst.Assignment, cst.Table, cst.Pair:
+			if comment := n.DocComment(); comment != "" {
+				b.comments[n.Tokens()[0].Position] = comment
+			}
+		}
+		return true
+	})
+
+	for _, stmt := range program.Statements {
+		switch s := stmt.(type) {
+		case *ast.AssignmentStatement:
+			b.value(query.Path{key(s.Name.Value)}, s.Token.Position, s.Token.Line, s.Value)
+		case *ast.TableStatement:
+			var path query.Path
+			for _, segment := range s.Path {
+				path = append(path, key(segment))
+			}
+			b.add(KindTable, path, s.Token.Position, s.Token.Line)
+			b.pairs(path, s.Body)
+		case *ast.DirectiveStatement:
+			if s.Name == "const" && s.Body != nil {
+				b.constants(s)
+			}
+		}
+	}
+	return b.ref
+}
+
+type builder struct {
+	ref      *Reference
+	comments map[int]string // doc comments by the position of the node they document
+	output   map[string]interface{}
+	constant ConstantFunc
+	seen     map[string]int // index of the entry for each path, as tables can be declared in parts
+}
+
+func key(name string) query.Segment {
+	return query.Segment{Kind: query.KeySegment, Key: name}
+}
+
+// value documents the value expr assigned at path, and the keys of objects inside it
+func (b *builder) value(path query.Path, position, line int, expr ast.Expression) {
+	entry := b.add(KindKey, path, position, line)
+	if entry == nil {
+		entry = &Entry{} // already documented
+	}
+	switch e := expr.(type) {
+	case *ast.EnvDirective:
+		entry.Env = e.VarName
+		if e.DefaultValue != nil {
+			entry.Default = e.DefaultValue.String()
+		}
+	case *ast.Reference:
+		entry.Secret = hasSecret(e.ResolvedValue)
+	case *ast.DirectiveExpression:
+		entry.Secret = hasSecret(e.ResolvedValue)
+	}
+	if obj, ok := expr.(*ast.ObjectLiteral); ok {
+		b.pairs(path, obj)
+	}
+}
+
+// pairs documents the keys of an object at path
+func (b *builder) pairs(path query.Path, obj *ast.ObjectLiteral) {
+	if obj == nil {
+		return
+	}
+	for _, k := range ast.SortedKeys(obj) {
+		tok := ast.ExpressionToken(k)
+		child := append(append(query.Path{}, path...), key(ast.KeyName(k)))
+		b.value(child, tok.Position, tok.Line, obj.Pairs[k])
+	}
+}
+
+// add adds an entry for path, or fills in the description of an existing one
+// It returns the new entry, or nil if path was already documented
+func (b *builder) add(kind Kind, path query.Path, position, line int) *Entry {
+	name := path.String()
+	if i, ok := b.seen[name]; ok {
+		if b.ref.Entries[i].Description == "" {
+			b.ref.Entries[i].Description = b.comments[position]
+		}
+		return nil
+	}
+
+	v := lookup(b.output, path)
+	b.seen[name] = len(b.ref.Entries)
+	b.ref.Entries = append(b.ref.Entries, Entry{
+		Kind:        kind,
+		Path:        name,
+		Type:        value.TypeOf(v, nil).String(),
+		Value:       v,
+		Description: b.comments[position],
+		Line:        line,
+	})
+	return &b.ref.Entries[len(b.ref.Entries)-1]
+}
+
+// constants documents the values of an @const directive
+func (b *builder) constants(directive *ast.DirectiveStatement) {
+	namespace := ""
+	if len(directive.Parameters) > 0 {
+		if str, ok := directive.Parameters[0].(*ast.StringLiteral); ok {
+			namespace = str.Value
+		}
+	}
+
+	for _, k := range ast.SortedKeys(directive.Body) {
+		ident, ok := k.(*ast.Identifier)
+		if !ok {
+			continue
+		}
+		v, _ := b.constant(namespace, ident.Value)
+		redacted := value.RevealSecrets(v, true)
+		path := ":" + ident.Value
+		if namespace != "" {
+			path = ":" + namespace + "." + ident.Value
+		}
+		b.ref.Constants = append(b.ref.Constants, Entry{
+			Kind:        KindConstant,
+			Path:        path,
+			Type:        value.TypeOf(redacted, nil).String(),
+			Value:       redacted,
+			Secret:      hasSecret(v),
+			Description: b.comments[ident.Token.Position],
+			Line:        ident.Token.Line,
+		})
+	}
+}
+
+// lookup returns the value at a path of keys in output, which has secrets redacted
+func lookup(output map[string]interface{}, path query.Path) interface{} {
+	var value interface{} = output
+	for _, segment := range path {
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		value = object[segment.Key]
+	}
+	return value
+}
+
+// hasSecret reports whether a value is or contains a secret
+func hasSecret(value interface{}) bool {
+	switch v := value.(type) {
+	case secret.Value:
+		return true
+	case map[string]interface{}:
+		for _, element := range v {
+			if hasSecret(element) {
+				return true
+			}
+		}
+	case []interface{}:
+		for _, element := range v {
+			if hasSecret(element) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// valueText returns a value as compact JSON for a table cell, or "" for
+// objects whose keys are documented separately
+func valueText(e Entry) string {
+	if _, ok := e.Value.(map[string]interface{}); ok && e.Kind != KindConstant {
+		return ""
+	}
+	data, err := json.Marshal(e.Value)
+	if err != nil {
+		return fmt.Sprint(e.Value)
+	}
+	text := string(data)
+	if utf8.RuneCountInString(text) > maxValueLength {
+		text = string([]rune(text)[:maxValueLength-3]) + "..."
+	}
+	return text
+}
+
+// maxValueLength is the longest value shown in full, in runes
+const maxValueLength = 80
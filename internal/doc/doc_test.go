@@ -0,0 +1,128 @@
+package doc
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/tomdoesdev/brace/internal/compiler"
+	"github.com/tomdoesdev/brace/internal/env"
+	"github.com/tomdoesdev/brace/internal/secret"
+)
+
+func TestBuild(t *testing.T) {
+	source := `@brace "1.0.0"
+
+@const "net" {
+    // Default HTTP port
+    PORT = 8080
+}
+
+// Name of the service,
+// shown in logs
+name = "api"
+
+// Not attached: a blank line follows
+
+debug = false // Enable verbose logging
+
+// HTTP server settings
+#server {
+    // Port to listen on
+    port = @env.int("PORT", :net.PORT)
+    host = @env("HOST")
+    token = @secret("api/token")
+}
+`
+	c := compiler.New(
+		compiler.WithEnvProvider(env.Map{"HOST": "example.com"}),
+		compiler.WithSecretProvider(secret.Env{Provider: env.Map{"BRACE_SECRET_API_TOKEN": "s3cret"}, Prefix: secret.DefaultEnvPrefix}),
+		compiler.WithRedaction(),
+	)
+	output, err := c.CompileValue(source, "config.brace")
+	if err != nil {
+		t.Fatalf("compilation failed: %v", err)
+	}
+	ref := Build("config.brace", c.Program(), c.SyntaxTree(), output, c.Analyzer().Constant)
+
+	expected := []struct {
+		path, typ, description string
+		value                  interface{}
+	}{
+		{"name", "string", "Name of the service,\nshown in logs", "api"},
+		{"debug", "boolean", "Enable verbose logging", false},
+		{"server", "object", "HTTP server settings", nil},
+		{"server.port", "number", "Port to listen on", int64(8080)},
+		{"server.host", "string", "", "example.com"},
+		{"server.token", "string", "", secret.Redacted},
+	}
+	if len(ref.Entries) != len(expected) {
+		t.Fatalf("expected %d entries, got %+v", len(expected), ref.Entries)
+	}
+	for i, tt := range expected {
+		e := ref.Entries[i]
+		if e.Path != tt.path || e.Type != tt.typ || e.Description != tt.description {
+			t.Errorf("expected %s (%s) %q, got %s (%s) %q", tt.path, tt.typ, tt.description, e.Path, e.Type, e.Description)
+		}
+		if tt.value != nil && e.Value != tt.value {
+			t.Errorf("%s: expected value %v, got %v", tt.path, tt.value, e.Value)
+		}
+	}
+	if port := ref.Entries[3]; port.Env != "PORT" || port.Default != ":net.PORT" {
+		t.Errorf("expected server.port to document its @env default, got %+v", port)
+	}
+
+	if len(ref.Constants) != 1 || ref.Constants[0].Path != ":net.PORT" || ref.Constants[0].Description != "Default HTTP port" {
+		t.Errorf("expected the documented constant, got %+v", ref.Constants)
+	}
+
+	markdown := ref.Markdown()
+	for _, want := range []string{
+		"| `server.port` | number | `8080` | `$PORT`, default `:net.PORT` | Port to listen on |",
+		"| `name` | string | `\"api\"` |  | Name of the service,<br>shown in logs |",
+	} {
+		if !strings.Contains(markdown, want) {
+			t.Errorf("expected the Markdown page to contain\n%s\ngot\n%s", want, markdown)
+		}
+	}
+	if page := ref.HTML(); strings.Contains(page, "s3cret") || !strings.Contains(page, `<tr id="key-server.port">`) {
+		t.Errorf("expected an HTML page without secrets, got\n%s", page)
+	}
+}
+
+func TestValueText(t *testing.T) {
+	long := strings.Repeat("a", 100)
+	accented := strings.Repeat("é", 100)
+	tests := []struct {
+		entry Entry
+		text  string
+	}{
+		{Entry{Kind: KindKey, Value: "api"}, `"api"`},
+		{Entry{Kind: KindKey, Value: []interface{}{int64(1), true}}, `[1,true]`},
+		{Entry{Kind: KindTable, Value: map[string]interface{}{"a": int64(1)}}, ""},
+		{Entry{Kind: KindConstant, Value: map[string]interface{}{"a": int64(1)}}, `{"a":1}`},
+		{Entry{Kind: KindKey, Value: long}, `"` + long[:maxValueLength-4] + "..."},
+		{Entry{Kind: KindKey, Value: accented}, `"` + strings.Repeat("é", maxValueLength-4) + "..."},
+	}
+	for _, tt := range tests {
+		if text := valueText(tt.entry); text != tt.text {
+			t.Errorf("valueText(%v): expected %s, got %s", tt.entry.Value, tt.text, text)
+		}
+	}
+}
+
+func TestMarkdownCode(t *testing.T) {
+	tests := []struct {
+		text, code string
+	}{
+		{"", ""},
+		{"8080", "`8080`"},
+		{"a|b\nc", "`a\\|b<br>c`"},
+		{"`x`", "`` `x` ``"},
+		{"a``b", "```a``b```"},
+	}
+	for _, tt := range tests {
+		if code := markdownCode(tt.text); code != tt.code {
+			t.Errorf("markdownCode(%q): expected %q, got %q", tt.text, tt.code, code)
+		}
+	}
+}
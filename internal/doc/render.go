@@ -0,0 +1,130 @@
+package doc
+
+import (
+	"fmt"
+	"html"
+	"strings"
+)
+
+// Markdown renders the reference as a Markdown page with a table of keys and
+// a table of constants
+func (r *Reference) Markdown() string {
+	var out strings.Builder
+	fmt.Fprintf(&out, "# %s\n", r.File)
+
+	if len(r.Entries) > 0 {
+		out.WriteString("\n## Keys\n\n| Key | Type | Value | Default | Description |\n| --- | --- | --- | --- | --- |\n")
+		for _, e := range r.Entries {
+			fmt.Fprintf(&out, "| %s | %s | %s | %s | %s |\n",
+				markdownCode(e.Path), e.Type, markdownCode(valueText(e)), markdownDefault(e), markdownCell(e.Description))
+		}
+	}
+
+	if len(r.Constants) > 0 {
+		out.WriteString("\n## Constants\n\n| Constant | Type | Value | Description |\n| --- | --- | --- | --- |\n")
+		for _, e := range r.Constants {
+			fmt.Fprintf(&out, "| %s | %s | %s | %s |\n",
+				markdownCode(e.Path), e.Type, markdownCode(valueText(e)), markdownCell(e.Description))
+		}
+	}
+	return out.String()
+}
+
+// markdownDefault describes where an @env value comes from
+func markdownDefault(e Entry) string {
+	switch {
+	case e.Env == "":
+		return ""
+	case e.Default == "":
+		return markdownCode("$"+e.Env) + " (required)"
+	default:
+		return markdownCode("$"+e.Env) + ", default " + markdownCode(e.Default)
+	}
+}
+
+// markdownCode formats text as inline code in a table cell
+func markdownCode(text string) string {
+	if text == "" {
+		return ""
+	}
+	fence := "`"
+	for strings.Contains(text, fence) {
+		fence += "`"
+	}
+	if strings.HasPrefix(text, "`") || strings.HasSuffix(text, "`") {
+		text = " " + text + " "
+	}
+	return fence + markdownCell(text) + fence
+}
+
+// markdownCell escapes text for a table cell
+func markdownCell(text string) string {
+	text = strings.ReplaceAll(text, "|", "\\|")
+	return strings.ReplaceAll(text, "\n", "<br>")
+}
+
+// HTML renders the reference as a standalone HTML page
+// Rows have ids of the form key-<path> and constant-<path> to link to
+func (r *Reference) HTML() string {
+	var out strings.Builder
+	title := html.EscapeString(r.File)
+	fmt.Fprintf(&out, `<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>%s</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; }
+th, td { border: 1px solid #ccc; padding: 0.3em 0.6em; text-align: left; vertical-align: top; }
+</style>
+</head>
+<body>
+<h1>%s</h1>
+`, title, title)
+
+	if len(r.Entries) > 0 {
+		out.WriteString("<h2>Keys</h2>\n<table>\n<thead><tr><th>Key</th><th>Type</th><th>Value</th><th>Default</th><th>Description</th></tr></thead>\n<tbody>\n")
+		for _, e := range r.Entries {
+			fmt.Fprintf(&out, "<tr id=\"key-%s\"><td>%s</td><td>%s</td><td>%s</td><td>%s</td><td>%s</td></tr>\n",
+				html.EscapeString(e.Path), htmlCode(e.Path), html.EscapeString(e.Type), htmlCode(valueText(e)), htmlDefault(e), htmlText(e.Description))
+		}
+		out.WriteString("</tbody>\n</table>\n")
+	}
+
+	if len(r.Constants) > 0 {
+		out.WriteString("<h2>Constants</h2>\n<table>\n<thead><tr><th>Constant</th><th>Type</th><th>Value</th><th>Description</th></tr></thead>\n<tbody>\n")
+		for _, e := range r.Constants {
+			fmt.Fprintf(&out, "<tr id=\"constant-%s\"><td>%s</td><td>%s</td><td>%s</td><td>%s</td></tr>\n",
+				html.EscapeString(strings.TrimPrefix(e.Path, ":")), htmlCode(e.Path), html.EscapeString(e.Type), htmlCode(valueText(e)), htmlText(e.Description))
+		}
+		out.WriteString("</tbody>\n</table>\n")
+	}
+
+	out.WriteString("</body>\n</html>\n")
+	return out.String()
+}
+
+// htmlDefault describes where an @env value comes from
+func htmlDefault(e Entry) string {
+	switch {
+	case e.Env == "":
+		return ""
+	case e.Default == "":
+		return htmlCode("$"+e.Env) + " (required)"
+	default:
+		return htmlCode("$"+e.Env) + ", default " + htmlCode(e.Default)
+	}
+}
+
+func htmlCode(text string) string {
+	if text == "" {
+		return ""
+	}
+	return "<code>" + html.EscapeString(text) + "</code>"
+}
+
+// htmlText escapes text, keeping its line breaks
+func htmlText(text string) string {
+	return strings.ReplaceAll(html.EscapeString(text), "\n", "<br>")
+}
@@ -0,0 +1,476 @@
+package encrypt
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Prefixes of the textual key formats
+const (
+	SymmetricKeyPrefix = "BRACE-SYMMETRIC-KEY-"
+	X25519SecretPrefix = "BRACE-X25519-SECRET-KEY-"
+	X25519PublicPrefix = "brace-x25519-"
+)
+
+// Placeholder is emitted instead of plaintext when encrypted values are allowed but cannot be decrypted
+const Placeholder = "[ENCRYPTED]"
+
+// ErrNoIdentity is returned when none of the available identities can decrypt a value
+var ErrNoIdentity = errors.New("no matching key available to decrypt value")
+
+const (
+	envelopeVersion = 1
+	envelopeAAD     = "brace-encrypted-v1"
+	keySize         = 32
+)
+
+var encoding = base64.RawURLEncoding
+
+// Recipient is someone a value is encrypted to
+type Recipient interface {
+	wrap(dataKey []byte) (stanza, error)
+	// String returns the textual form of the recipient
+	String() string
+}
+
+// Identity can decrypt values encrypted to its recipient
+type Identity interface {
+	unwrap(s stanza) ([]byte, error)
+	// Recipient returns the recipient matching this identity
+	Recipient() Recipient
+	// String returns the textual (secret) form of the identity
+	String() string
+}
+
+// stanza holds the data key wrapped for one recipient
+type stanza struct {
+	Type      string `json:"type"`
+	ID        string `json:"id"`
+	Ephemeral string `json:"epk,omitempty"`
+	Wrapped   string `json:"key"`
+}
+
+// envelope is the decoded form of an @encrypted("...") value
+type envelope struct {
+	Version int      `json:"v"`
+	Stanzas []stanza `json:"recipients"`
+	Nonce   string   `json:"nonce"`
+	Data    string   `json:"data"`
+}
+
+// Encrypt encrypts plaintext to every recipient and returns the encoded ciphertext
+func Encrypt(plaintext string, recipients []Recipient) (string, error) {
+	if len(recipients) == 0 {
+		return "", fmt.Errorf("no recipients")
+	}
+
+	dataKey := make([]byte, keySize)
+	if _, err := rand.Read(dataKey); err != nil {
+		return "", err
+	}
+
+	nonce, data, err := seal(dataKey, []byte(plaintext), []byte(envelopeAAD))
+	if err != nil {
+		return "", err
+	}
+
+	env := envelope{
+		Version: envelopeVersion,
+		Nonce:   encoding.EncodeToString(nonce),
+		Data:    encoding.EncodeToString(data),
+	}
+	for _, recipient := range recipients {
+		s, err := recipient.wrap(dataKey)
+		if err != nil {
+			return "", err
+		}
+		env.Stanzas = append(env.Stanzas, s)
+	}
+
+	raw, err := json.Marshal(env)
+	if err != nil {
+		return "", err
+	}
+	return encoding.EncodeToString(raw), nil
+}
+
+// Decrypt decrypts a value produced by Encrypt using the first matching identity
+// If no identity unwraps a stanza, the first error other than a mismatch is
+// returned, or ErrNoIdentity if every identity simply did not match
+func Decrypt(ciphertext string, identities []Identity) (string, error) {
+	env, err := decodeEnvelope(ciphertext)
+	if err != nil {
+		return "", err
+	}
+
+	var unwrapErr error
+	for _, s := range env.Stanzas {
+		for _, identity := range identities {
+			dataKey, err := identity.unwrap(s)
+			if err != nil {
+				// Another identity or stanza may still hold the key
+				if !errors.Is(err, errStanzaMismatch) && unwrapErr == nil {
+					unwrapErr = err
+				}
+				continue
+			}
+
+			nonce, err := encoding.DecodeString(env.Nonce)
+			if err != nil {
+				return "", fmt.Errorf("malformed encrypted value: %v", err)
+			}
+			data, err := encoding.DecodeString(env.Data)
+			if err != nil {
+				return "", fmt.Errorf("malformed encrypted value: %v", err)
+			}
+			plaintext, err := open(dataKey, nonce, data, []byte(envelopeAAD))
+			if err != nil {
+				return "", fmt.Errorf("decrypting value: %v", err)
+			}
+			return string(plaintext), nil
+		}
+	}
+
+	if unwrapErr != nil {
+		return "", unwrapErr
+	}
+	return "", ErrNoIdentity
+}
+
+// Rekey re-encrypts a value to a new set of recipients
+func Rekey(ciphertext string, identities []Identity, recipients []Recipient) (string, error) {
+	plaintext, err := Decrypt(ciphertext, identities)
+	if err != nil {
+		return "", err
+	}
+	return Encrypt(plaintext, recipients)
+}
+
+// RecipientIDs returns the key ids a value is encrypted to
+func RecipientIDs(ciphertext string) ([]string, error) {
+	env, err := decodeEnvelope(ciphertext)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]string, 0, len(env.Stanzas))
+	for _, s := range env.Stanzas {
+		ids = append(ids, s.Type+":"+s.ID)
+	}
+	return ids, nil
+}
+
+// decodeEnvelope parses the encoded ciphertext
+func decodeEnvelope(ciphertext string) (*envelope, error) {
+	raw, err := encoding.DecodeString(strings.TrimSpace(ciphertext))
+	if err != nil {
+		return nil, fmt.Errorf("malformed encrypted value: %v", err)
+	}
+	var env envelope
+	if err := json.Unmarshal(raw, &env); err != nil {
+		return nil, fmt.Errorf("malformed encrypted value: %v", err)
+	}
+	if env.Version != envelopeVersion {
+		return nil, fmt.Errorf("unsupported encrypted value version %d", env.Version)
+	}
+	return &env, nil
+}
+
+var errStanzaMismatch = errors.New("stanza is for a different identity")
+
+// SymmetricKey is a shared secret key; it is both an Identity and a Recipient
+type SymmetricKey struct {
+	key []byte
+}
+
+// GenerateSymmetricKey creates a new random symmetric key
+func GenerateSymmetricKey() (*SymmetricKey, error) {
+	key := make([]byte, keySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	return &SymmetricKey{key: key}, nil
+}
+
+func (k *SymmetricKey) id() string {
+	sum := sha256.Sum256(k.key)
+	return hex.EncodeToString(sum[:8])
+}
+
+func (k *SymmetricKey) wrap(dataKey []byte) (stanza, error) {
+	nonce, wrapped, err := seal(deriveKey(k.key, nil, "brace symmetric wrap"), dataKey, nil)
+	if err != nil {
+		return stanza{}, err
+	}
+	return stanza{Type: "symmetric", ID: k.id(), Wrapped: encoding.EncodeToString(append(nonce, wrapped...))}, nil
+}
+
+func (k *SymmetricKey) unwrap(s stanza) ([]byte, error) {
+	if s.Type != "symmetric" || s.ID != k.id() {
+		return nil, errStanzaMismatch
+	}
+	return unwrapKey(deriveKey(k.key, nil, "brace symmetric wrap"), s.Wrapped)
+}
+
+// Recipient implements Identity
+func (k *SymmetricKey) Recipient() Recipient { return k }
+
+// String implements Identity and Recipient
+func (k *SymmetricKey) String() string { return SymmetricKeyPrefix + encoding.EncodeToString(k.key) }
+
+// X25519Identity is the private half of an X25519 key pair
+type X25519Identity struct {
+	key *ecdh.PrivateKey
+}
+
+// X25519Recipient is the public half of an X25519 key pair
+type X25519Recipient struct {
+	key *ecdh.PublicKey
+}
+
+// GenerateX25519Identity creates a new random X25519 key pair
+func GenerateX25519Identity() (*X25519Identity, error) {
+	key, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	return &X25519Identity{key: key}, nil
+}
+
+// Recipient implements Identity
+func (i *X25519Identity) Recipient() Recipient { return &X25519Recipient{key: i.key.PublicKey()} }
+
+// String implements Identity
+func (i *X25519Identity) String() string {
+	return X25519SecretPrefix + encoding.EncodeToString(i.key.Bytes())
+}
+
+func (i *X25519Identity) unwrap(s stanza) ([]byte, error) {
+	recipient := &X25519Recipient{key: i.key.PublicKey()}
+	if s.Type != "x25519" || s.ID != recipient.id() {
+		return nil, errStanzaMismatch
+	}
+
+	ephemeralBytes, err := encoding.DecodeString(s.Ephemeral)
+	if err != nil {
+		return nil, fmt.Errorf("malformed encrypted value: %v", err)
+	}
+	ephemeral, err := ecdh.X25519().NewPublicKey(ephemeralBytes)
+	if err != nil {
+		return nil, fmt.Errorf("malformed encrypted value: %v", err)
+	}
+	shared, err := i.key.ECDH(ephemeral)
+	if err != nil {
+		return nil, err
+	}
+
+	salt := append(append([]byte{}, ephemeralBytes...), i.key.PublicKey().Bytes()...)
+	return unwrapKey(deriveKey(shared, salt, "brace x25519 wrap"), s.Wrapped)
+}
+
+func (r *X25519Recipient) id() string {
+	sum := sha256.Sum256(r.key.Bytes())
+	return hex.EncodeToString(sum[:8])
+}
+
+func (r *X25519Recipient) wrap(dataKey []byte) (stanza, error) {
+	ephemeral, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return stanza{}, err
+	}
+	shared, err := ephemeral.ECDH(r.key)
+	if err != nil {
+		return stanza{}, err
+	}
+
+	ephemeralBytes := ephemeral.PublicKey().Bytes()
+	salt := append(append([]byte{}, ephemeralBytes...), r.key.Bytes()...)
+	nonce, wrapped, err := seal(deriveKey(shared, salt, "brace x25519 wrap"), dataKey, nil)
+	if err != nil {
+		return stanza{}, err
+	}
+
+	return stanza{
+		Type:      "x25519",
+		ID:        r.id(),
+		Ephemeral: encoding.EncodeToString(ephemeralBytes),
+		Wrapped:   encoding.EncodeToString(append(nonce, wrapped...)),
+	}, nil
+}
+
+// String implements Recipient
+func (r *X25519Recipient) String() string {
+	return X25519PublicPrefix + encoding.EncodeToString(r.key.Bytes())
+}
+
+// ParseIdentity parses the textual form of a symmetric key or X25519 secret key
+func ParseIdentity(s string) (Identity, error) {
+	s = strings.TrimSpace(s)
+	switch {
+	case strings.HasPrefix(s, SymmetricKeyPrefix):
+		key, err := encoding.DecodeString(strings.TrimPrefix(s, SymmetricKeyPrefix))
+		if err != nil || len(key) != keySize {
+			return nil, fmt.Errorf("malformed symmetric key")
+		}
+		return &SymmetricKey{key: key}, nil
+	case strings.HasPrefix(s, X25519SecretPrefix):
+		raw, err := encoding.DecodeString(strings.TrimPrefix(s, X25519SecretPrefix))
+		if err != nil {
+			return nil, fmt.Errorf("malformed X25519 secret key")
+		}
+		key, err := ecdh.X25519().NewPrivateKey(raw)
+		if err != nil {
+			return nil, fmt.Errorf("malformed X25519 secret key")
+		}
+		return &X25519Identity{key: key}, nil
+	default:
+		return nil, fmt.Errorf("unrecognized key format")
+	}
+}
+
+// ParseRecipient parses an X25519 public key or a symmetric key
+func ParseRecipient(s string) (Recipient, error) {
+	s = strings.TrimSpace(s)
+	switch {
+	case strings.HasPrefix(s, X25519PublicPrefix):
+		raw, err := encoding.DecodeString(strings.TrimPrefix(s, X25519PublicPrefix))
+		if err != nil {
+			return nil, fmt.Errorf("malformed X25519 recipient")
+		}
+		key, err := ecdh.X25519().NewPublicKey(raw)
+		if err != nil {
+			return nil, fmt.Errorf("malformed X25519 recipient")
+		}
+		return &X25519Recipient{key: key}, nil
+	case strings.HasPrefix(s, SymmetricKeyPrefix):
+		identity, err := ParseIdentity(s)
+		if err != nil {
+			return nil, err
+		}
+		return identity.Recipient(), nil
+	default:
+		return nil, fmt.Errorf("unrecognized recipient format")
+	}
+}
+
+// ParseIdentities parses a key file: one identity per line, blank lines and # comments ignored
+func ParseIdentities(data string) ([]Identity, error) {
+	var identities []Identity
+	for i, line := range strings.Split(data, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		identity, err := ParseIdentity(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", i+1, err)
+		}
+		identities = append(identities, identity)
+	}
+	return identities, nil
+}
+
+// seal encrypts with AES-256-GCM and returns the random nonce and ciphertext
+func seal(key, plaintext, aad []byte) ([]byte, []byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, nil, err
+	}
+	return nonce, gcm.Seal(nil, nonce, plaintext, aad), nil
+}
+
+// open decrypts AES-256-GCM ciphertext
+func open(key, nonce, ciphertext, aad []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(nonce) != gcm.NonceSize() {
+		return nil, fmt.Errorf("invalid nonce")
+	}
+	return gcm.Open(nil, nonce, ciphertext, aad)
+}
+
+// unwrapKey decrypts a wrapped data key stored as nonce || ciphertext
+func unwrapKey(key []byte, wrapped string) ([]byte, error) {
+	raw, err := encoding.DecodeString(wrapped)
+	if err != nil {
+		return nil, fmt.Errorf("malformed encrypted value: %v", err)
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(raw) < gcm.NonceSize() {
+		return nil, fmt.Errorf("malformed encrypted value")
+	}
+	dataKey, err := gcm.Open(nil, raw[:gcm.NonceSize()], raw[gcm.NonceSize():], nil)
+	if err != nil {
+		return nil, fmt.Errorf("unwrapping data key: %v", err)
+	}
+	return dataKey, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// deriveKey derives a 32-byte key with HKDF-SHA256 (RFC 5869, single output block)
+func deriveKey(secret, salt []byte, info string) []byte {
+	if salt == nil {
+		salt = make([]byte, sha256.Size)
+	}
+	extract := hmac.New(sha256.New, salt)
+	extract.Write(secret)
+	prk := extract.Sum(nil)
+
+	expand := hmac.New(sha256.New, prk)
+	expand.Write([]byte(info))
+	expand.Write([]byte{1})
+	return expand.Sum(nil)
+}
+
+// DefaultKeyFile returns the key file used when no identity is given explicitly:
+// $BRACE_KEY_FILE, or keys.txt in the user's brace configuration directory
+func DefaultKeyFile() (string, error) {
+	if path := os.Getenv("BRACE_KEY_FILE"); path != "" {
+		return path, nil
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "brace", "keys.txt"), nil
+}
+
+// LoadIdentities reads a key file
+func LoadIdentities(path string) ([]Identity, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading key file: %v", err)
+	}
+	identities, err := ParseIdentities(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return identities, nil
+}
@@ -0,0 +1,117 @@
+package encrypt
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestEncryptDecrypt(t *testing.T) {
+	symmetric, err := GenerateSymmetricKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	alice, err := GenerateX25519Identity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	bob, err := GenerateX25519Identity()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ciphertext, err := Encrypt("hunter2", []Recipient{symmetric, alice.Recipient()})
+	if err != nil {
+		t.Fatalf("encrypt failed: %v", err)
+	}
+
+	for name, identity := range map[string]Identity{"symmetric": symmetric, "x25519": alice} {
+		plaintext, err := Decrypt(ciphertext, []Identity{identity})
+		if err != nil || plaintext != "hunter2" {
+			t.Errorf("%s: expected hunter2, got %q (%v)", name, plaintext, err)
+		}
+	}
+
+	if _, err := Decrypt(ciphertext, []Identity{bob}); !errors.Is(err, ErrNoIdentity) {
+		t.Errorf("expected ErrNoIdentity for unrelated key, got %v", err)
+	}
+
+	// Rotate from the symmetric key to bob only
+	rotated, err := Rekey(ciphertext, []Identity{symmetric}, []Recipient{bob.Recipient()})
+	if err != nil {
+		t.Fatalf("rekey failed: %v", err)
+	}
+	if _, err := Decrypt(rotated, []Identity{symmetric, alice}); !errors.Is(err, ErrNoIdentity) {
+		t.Errorf("expected old keys to be rotated out, got %v", err)
+	}
+	if plaintext, err := Decrypt(rotated, []Identity{bob}); err != nil || plaintext != "hunter2" {
+		t.Errorf("expected bob to decrypt rotated value, got %q (%v)", plaintext, err)
+	}
+}
+
+func TestDecryptTriesEveryIdentity(t *testing.T) {
+	symmetric, err := GenerateSymmetricKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	alice, err := GenerateX25519Identity()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ciphertext, err := Encrypt("hunter2", []Recipient{symmetric, alice.Recipient()})
+	if err != nil {
+		t.Fatalf("encrypt failed: %v", err)
+	}
+
+	// Corrupt the symmetric key's stanza so unwrapping it fails
+	env, err := decodeEnvelope(ciphertext)
+	if err != nil {
+		t.Fatal(err)
+	}
+	env.Stanzas[0].Wrapped = encoding.EncodeToString([]byte("corrupt"))
+	raw, err := json.Marshal(env)
+	if err != nil {
+		t.Fatal(err)
+	}
+	corrupted := encoding.EncodeToString(raw)
+
+	if plaintext, err := Decrypt(corrupted, []Identity{symmetric, alice}); err != nil || plaintext != "hunter2" {
+		t.Errorf("expected alice to decrypt after the corrupt stanza, got %q (%v)", plaintext, err)
+	}
+	if _, err := Decrypt(corrupted, []Identity{symmetric}); err == nil || errors.Is(err, ErrNoIdentity) {
+		t.Errorf("expected the unwrap error when no identity succeeds, got %v", err)
+	}
+}
+
+func TestParseKeys(t *testing.T) {
+	identity, err := GenerateX25519Identity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	symmetric, err := GenerateSymmetricKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	identities, err := ParseIdentities("# keys\n" + identity.String() + "\n\n" + symmetric.String() + "\n")
+	if err != nil || len(identities) != 2 {
+		t.Fatalf("expected 2 identities, got %d (%v)", len(identities), err)
+	}
+
+	recipient, err := ParseRecipient(identity.Recipient().String())
+	if err != nil {
+		t.Fatalf("parse recipient failed: %v", err)
+	}
+	ciphertext, err := Encrypt("value", []Recipient{recipient})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plaintext, err := Decrypt(ciphertext, identities); err != nil || plaintext != "value" {
+		t.Errorf("expected parsed keys to round trip, got %q (%v)", plaintext, err)
+	}
+
+	if _, err := ParseIdentities("not a key"); err == nil {
+		t.Errorf("expected error for malformed key file")
+	}
+}
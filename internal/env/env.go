@@ -0,0 +1,162 @@
+package env
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// Provider looks up environment variables for @env directives
+type Provider interface {
+	// Lookup returns the value of the variable and whether it is set
+	Lookup(name string) (string, bool)
+}
+
+// OS reads variables from the process environment
+type OS struct{}
+
+// Lookup implements Provider using os.LookupEnv
+func (OS) Lookup(name string) (string, bool) {
+	return os.LookupEnv(name)
+}
+
+// Map is a fixed set of variables, useful for tests and hermetic builds
+type Map map[string]string
+
+// Lookup implements Provider
+func (m Map) Lookup(name string) (string, bool) {
+	value, ok := m[name]
+	return value, ok
+}
+
+// Layered combines providers; the first provider that has a variable wins
+type Layered []Provider
+
+// Lookup implements Provider
+func (l Layered) Lookup(name string) (string, bool) {
+	for _, provider := range l {
+		if provider == nil {
+			continue
+		}
+		if value, ok := provider.Lookup(name); ok {
+			return value, true
+		}
+	}
+	return "", false
+}
+
+// LoadFile reads a .env file into a Map
+func LoadFile(path string) (Map, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("opening env file: %v", err)
+	}
+	defer file.Close()
+
+	vars, err := ParseDotEnv(file)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return vars, nil
+}
+
+// ParseDotEnv parses .env syntax: KEY=VALUE lines, optional "export " prefixes,
+// # comments, and single or double quoted values
+func ParseDotEnv(r io.Reader) (Map, error) {
+	vars := Map{}
+	scanner := bufio.NewScanner(r)
+	lineNum := 0
+
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+
+		eq := strings.Index(line, "=")
+		if eq <= 0 {
+			return nil, fmt.Errorf("line %d: expected KEY=VALUE", lineNum)
+		}
+
+		key := strings.TrimSpace(line[:eq])
+		if !isValidName(key) {
+			return nil, fmt.Errorf("line %d: invalid variable name %q", lineNum, key)
+		}
+
+		value, err := parseDotEnvValue(strings.TrimSpace(line[eq+1:]))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", lineNum, err)
+		}
+		vars[key] = value
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return vars, nil
+}
+
+// parseDotEnvValue unquotes a .env value and strips trailing comments
+func parseDotEnvValue(raw string) (string, error) {
+	if raw == "" {
+		return "", nil
+	}
+
+	switch raw[0] {
+	case '\'':
+		end := strings.Index(raw[1:], "'")
+		if end == -1 {
+			return "", fmt.Errorf("unterminated single-quoted value")
+		}
+		return raw[1 : end+1], nil
+	case '"':
+		var out strings.Builder
+		for i := 1; i < len(raw); i++ {
+			ch := raw[i]
+			if ch == '"' {
+				return out.String(), nil
+			}
+			if ch == '\\' && i+1 < len(raw) {
+				i++
+				switch raw[i] {
+				case 'n':
+					out.WriteByte('\n')
+				case 't':
+					out.WriteByte('\t')
+				case 'r':
+					out.WriteByte('\r')
+				default:
+					out.WriteByte(raw[i])
+				}
+				continue
+			}
+			out.WriteByte(ch)
+		}
+		return "", fmt.Errorf("unterminated double-quoted value")
+	default:
+		// Unquoted values end at an inline comment
+		if idx := strings.Index(raw, " #"); idx != -1 {
+			raw = raw[:idx]
+		}
+		return strings.TrimSpace(raw), nil
+	}
+}
+
+// isValidName reports whether name is a valid environment variable name
+func isValidName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i, ch := range name {
+		isLetter := ch >= 'a' && ch <= 'z' || ch >= 'A' && ch <= 'Z' || ch == '_'
+		isDigit := ch >= '0' && ch <= '9'
+		if !isLetter && !(isDigit && i > 0) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,57 @@
+package env
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseDotEnv(t *testing.T) {
+	source := `
+# database settings
+DB_HOST=localhost
+export DB_PORT=5432
+GREETING="hello\nworld"
+RAW='no $expansion # here'
+EMPTY=
+TRAILING=value # comment
+`
+
+	vars, err := ParseDotEnv(strings.NewReader(source))
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+
+	expected := map[string]string{
+		"DB_HOST":  "localhost",
+		"DB_PORT":  "5432",
+		"GREETING": "hello\nworld",
+		"RAW":      "no $expansion # here",
+		"EMPTY":    "",
+		"TRAILING": "value",
+	}
+	for key, want := range expected {
+		got, ok := vars.Lookup(key)
+		if !ok || got != want {
+			t.Errorf("%s: expected %q, got %q (set=%v)", key, want, got, ok)
+		}
+	}
+
+	_, err = ParseDotEnv(strings.NewReader("OK=1\nnot a variable\n"))
+	if err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("expected line 2 error, got %v", err)
+	}
+}
+
+func TestLayered(t *testing.T) {
+	provider := Layered{Map{"A": "override"}, Map{"A": "base", "B": "base"}}
+
+	if value, _ := provider.Lookup("A"); value != "override" {
+		t.Errorf("expected first layer to win, got %q", value)
+	}
+	if value, _ := provider.Lookup("B"); value != "base" {
+		t.Errorf("expected fallback to second layer, got %q", value)
+	}
+	if _, ok := provider.Lookup("C"); ok {
+		t.Errorf("expected C to be unset")
+	}
+}
@@ -0,0 +1,66 @@
+package env
+
+import (
+	"fmt"
+	"path"
+)
+
+// Policy controls which environment variables a BRACE file may read
+// Patterns use glob syntax (APP_*, DB_?_HOST); deny patterns take precedence,
+// and when Allow is non-empty a variable must match one of its patterns
+type Policy struct {
+	Allow []string
+	Deny  []string
+}
+
+// Validate checks that all patterns are well formed
+func (p Policy) Validate() error {
+	for _, pattern := range append(append([]string{}, p.Allow...), p.Deny...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid environment policy pattern %q", pattern)
+		}
+	}
+	return nil
+}
+
+// IsZero reports whether the policy permits every variable
+func (p Policy) IsZero() bool {
+	return len(p.Allow) == 0 && len(p.Deny) == 0
+}
+
+// Merge returns a policy with the patterns of both policies
+func (p Policy) Merge(other Policy) Policy {
+	return Policy{
+		Allow: append(append([]string{}, p.Allow...), other.Allow...),
+		Deny:  append(append([]string{}, p.Deny...), other.Deny...),
+	}
+}
+
+// Check returns an error describing why name is not permitted, or nil
+func (p Policy) Check(name string) error {
+	for _, pattern := range p.Deny {
+		if matched, _ := path.Match(pattern, name); matched {
+			return fmt.Errorf("access to environment variable %s denied by policy (matches deny pattern %q)", name, pattern)
+		}
+	}
+
+	if len(p.Allow) == 0 {
+		return nil
+	}
+	for _, pattern := range p.Allow {
+		if matched, _ := path.Match(pattern, name); matched {
+			return nil
+		}
+	}
+	return fmt.Errorf("access to environment variable %s denied by policy (not in allowlist)", name)
+}
+
+// Access records a single @env lookup found in a BRACE file
+type Access struct {
+	Name       string // variable name
+	Type       string // typed lookup (int, list, ...), empty for plain @env
+	HasDefault bool
+	Line       int
+	Column     int
+	Violation  string // policy violation, empty when access is permitted
+}
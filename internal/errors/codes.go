@@ -0,0 +1,133 @@
+package errors
+
+import (
+	"embed"
+	stderrors "errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Stable diagnostic codes, grouped by area:
+// E00xx syntax, E01xx names, E02xx the @brace header, E03xx the environment,
+// E04xx directives, E05xx output generation and E06xx types
+// Codes are never reused; retired codes stay reserved
+const (
+	CodeIllegalCharacter    = "E0001"
+	CodeUnexpectedToken     = "E0002"
+	CodeInvalidNumber       = "E0003"
+	CodeUnclosedTemplate    = "E0004"
+	CodeExpectedObject      = "E0005"
+	CodeUndefinedReference  = "E0101"
+	CodeUnknownDirective    = "E0102"
+	CodeInvalidKey          = "E0103"
+	CodeReferenceCycle      = "E0104"
+	CodeMissingBrace        = "E0201"
+	CodeMalformedBrace      = "E0202"
+	CodeUnsupportedVersion  = "E0203"
+	CodeUnavailableFeature  = "E0204"
+	CodeEnvNotSet           = "E0301"
+	CodeInvalidEnvValue     = "E0302"
+	CodeInvalidEnvDirective = "E0303"
+	CodeEnvDenied           = "E0304"
+	CodeInvalidEnvPolicy    = "E0305"
+	CodeDirectiveFailed     = "E0401"
+	CodeUnsupportedFormat   = "E0501"
+	CodeEncodingFailed      = "E0502"
+	CodeUnresolvedValue     = "E0503"
+	CodeMixedArrayTypes     = "E0601"
+)
+
+// titles holds the short description of every code
+var titles = map[string]string{
+	CodeIllegalCharacter:    "illegal character",
+	CodeUnexpectedToken:     "unexpected token",
+	CodeInvalidNumber:       "invalid number literal",
+	CodeUnclosedTemplate:    "unclosed template interpolation",
+	CodeExpectedObject:      "expected an object",
+	CodeUndefinedReference:  "undefined reference",
+	CodeUnknownDirective:    "unknown directive",
+	CodeInvalidKey:          "invalid object key",
+	CodeReferenceCycle:      "reference cycle",
+	CodeMissingBrace:        "missing @brace directive",
+	CodeMalformedBrace:      "malformed @brace directive",
+	CodeUnsupportedVersion:  "unsupported version",
+	CodeUnavailableFeature:  "feature not available in the declared version",
+	CodeEnvNotSet:           "environment variable not set",
+	CodeInvalidEnvValue:     "invalid environment variable value",
+	CodeInvalidEnvDirective: "invalid @env directive",
+	CodeEnvDenied:           "environment variable denied by policy",
+	CodeInvalidEnvPolicy:    "invalid @env_policy",
+	CodeDirectiveFailed:     "directive failed",
+	CodeUnsupportedFormat:   "unsupported output format",
+	CodeEncodingFailed:      "output encoding failed",
+	CodeUnresolvedValue:     "unresolved value",
+	CodeMixedArrayTypes:     "mixed array element types",
+}
+
+//go:embed explain/*.md
+var explanations embed.FS
+
+// Codes returns every diagnostic code in order
+func Codes() []string {
+	codes := make([]string, 0, len(titles))
+	for code := range titles {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	return codes
+}
+
+// Title returns the short description of a code
+func Title(code string) (string, bool) {
+	title, ok := titles[strings.ToUpper(code)]
+	return title, ok
+}
+
+// Explain returns the long-form explanation of a code, with examples
+func Explain(code string) (string, bool) {
+	code = strings.ToUpper(code)
+	if _, ok := titles[code]; !ok {
+		return "", false
+	}
+	data, err := explanations.ReadFile("explain/" + code + ".md")
+	if err != nil {
+		return "", false
+	}
+	return string(data), true
+}
+
+// CodedError is an error with a stable diagnostic code
+type CodedError struct {
+	Code string
+	Err  error
+}
+
+func (e *CodedError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *CodedError) Unwrap() error {
+	return e.Err
+}
+
+// ErrorCode returns the error's diagnostic code
+func (e *CodedError) ErrorCode() string {
+	return e.Code
+}
+
+// Errorf formats an error with a diagnostic code
+func Errorf(code, format string, args ...interface{}) error {
+	return &CodedError{Code: code, Err: fmt.Errorf(format, args...)}
+}
+
+// CodeOf returns the code of the innermost coded error err wraps, or "" if none
+func CodeOf(err error) string {
+	code := ""
+	for ; err != nil; err = stderrors.Unwrap(err) {
+		if coded, ok := err.(interface{ ErrorCode() string }); ok && coded.ErrorCode() != "" {
+			code = coded.ErrorCode()
+		}
+	}
+	return code
+}
@@ -0,0 +1,42 @@
+package errors
+
+import (
+	"fmt"
+	"io/fs"
+	"strings"
+	"testing"
+)
+
+func TestEveryCodeIsExplained(t *testing.T) {
+	for _, code := range Codes() {
+		explanation, ok := Explain(code)
+		if !ok || !strings.Contains(explanation, "    ") {
+			t.Errorf("%s has no explanation with an example", code)
+		}
+	}
+
+	files, err := fs.Glob(explanations, "explain/*.md")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range files {
+		code := strings.TrimSuffix(strings.TrimPrefix(file, "explain/"), ".md")
+		if _, ok := Title(code); !ok {
+			t.Errorf("%s explains unknown code %s", file, code)
+		}
+	}
+}
+
+func TestCodeOf(t *testing.T) {
+	inner := Errorf(CodeUndefinedReference, "undefined reference: global.B")
+	outer := Errorf(CodeDirectiveFailed, "@file: %w", inner)
+	if code := CodeOf(fmt.Errorf("analysis error: %w", outer)); code != CodeUndefinedReference {
+		t.Errorf("expected the innermost code, got %q", code)
+	}
+	if code := CodeOf(fmt.Errorf("plain")); code != "" {
+		t.Errorf("expected no code, got %q", code)
+	}
+	if _, ok := Explain("e0101"); !ok {
+		t.Errorf("expected codes to be case-insensitive")
+	}
+}
@@ -0,0 +1,86 @@
+package errors
+
+import (
+	"fmt"
+
+	"github.com/tomdoesdev/brace/internal/token"
+)
+
+// Severity is how serious a diagnostic is
+type Severity string
+
+const (
+	SeverityError   Severity = "error"
+	SeverityWarning Severity = "warning"
+	SeverityNote    Severity = "note"
+)
+
+// Position is a point in a source file; lines and columns start at 1
+type Position struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+// Range is a span of source text; End is exclusive
+// A zero Range means the diagnostic applies to the whole file
+type Range struct {
+	Start Position `json:"start"`
+	End   Position `json:"end"`
+}
+
+// IsZero reports whether r has no position
+func (r Range) IsZero() bool {
+	return r.Start.Line == 0
+}
+
+// TokenRange returns the range from the start of start to the end of end
+func TokenRange(start, end token.Token) Range {
+	r := Range{
+		Start: Position{Line: start.Line, Column: start.Column},
+		End:   Position{Line: end.Line, Column: end.Column + end.Length},
+	}
+	if end.EndLine > 0 {
+		r.End = Position{Line: end.EndLine, Column: end.EndColumn}
+	}
+	return r
+}
+
+// RelatedLocation is another place in the source relevant to a diagnostic
+type RelatedLocation struct {
+	Message string `json:"message"`
+	File    string `json:"file"`
+	Range   Range  `json:"range"`
+}
+
+// TextEdit replaces the text in Range with NewText
+type TextEdit struct {
+	Range   Range  `json:"range"`
+	NewText string `json:"new_text"`
+}
+
+// Fix is a suggested change that resolves a diagnostic
+type Fix struct {
+	Message string     `json:"message"`
+	Edits   []TextEdit `json:"edits"`
+}
+
+// Diagnostic is a structured compiler message for tools
+type Diagnostic struct {
+	Severity Severity          `json:"severity"`
+	Code     string            `json:"code,omitempty"`
+	Message  string            `json:"message"`
+	File     string            `json:"file"`
+	Range    Range             `json:"range"`
+	Related  []RelatedLocation `json:"related,omitempty"`
+	Fixes    []Fix             `json:"fixes,omitempty"`
+	Help     []string          `json:"help,omitempty"`
+	Notes    []string          `json:"notes,omitempty"`
+}
+
+// String formats the diagnostic as file:line:column: severity: message
+func (d Diagnostic) String() string {
+	if d.Range.IsZero() {
+		return fmt.Sprintf("%s: %s: %s", d.File, d.Severity, d.Message)
+	}
+	return fmt.Sprintf("%s:%d:%d: %s: %s", d.File, d.Range.Start.Line, d.Range.Start.Column, d.Severity, d.Message)
+}
@@ -3,15 +3,44 @@ package errors
 import (
 	"fmt"
 	"strings"
+
+	"github.com/tomdoesdev/brace/internal/version"
 )
 
 // CompilerError represents a compilation error with detailed location info
 type CompilerError struct {
+	Code     string // stable diagnostic code, such as E0002
 	Message  string
 	Line     int
 	Column   int
+	Length   int      // length of the offending token, 0 if unknown
+	End      Position // just past the offending token, which may end on a later line; zero to use Length
 	Source   string
 	Filename string
+	Help     []string // help: lines shown after the source snippet
+	Notes    []string // note: lines shown after the help
+}
+
+// Diagnostic converts the error to a structured diagnostic
+func (ce CompilerError) Diagnostic(filename string) Diagnostic {
+	d := Diagnostic{
+		Severity: SeverityError,
+		Code:     ce.Code,
+		Message:  ce.Message,
+		File:     filename,
+		Help:     ce.Help,
+		Notes:    ce.Notes,
+	}
+	if ce.Line > 0 {
+		d.Range = Range{
+			Start: Position{Line: ce.Line, Column: ce.Column},
+			End:   Position{Line: ce.Line, Column: ce.Column + ce.Length},
+		}
+		if ce.End.Line > 0 {
+			d.Range.End = ce.End
+		}
+	}
+	return d
 }
 
 // ErrorReporter handles error formatting and display
@@ -19,6 +48,7 @@ type ErrorReporter struct {
 	source   string
 	filename string
 	lines    []string
+	color    bool
 }
 
 // NewErrorReporter creates a new error reporter
@@ -30,85 +60,164 @@ func NewErrorReporter(source, filename string) *ErrorReporter {
 	}
 }
 
-// ReportError formats and returns a Rust-style error message
-func (er *ErrorReporter) ReportError(message string, line, column int) string {
+// SetColor enables ANSI colors in reports
+func (er *ErrorReporter) SetColor(enabled bool) {
+	er.color = enabled
+}
+
+// ReportError formats and returns a Rust-style error message pointing at a
+// single column; ReportDiagnostic underlines whole ranges
+// The header includes the code, if given, as in error[E0101]
+func (er *ErrorReporter) ReportError(code, message string, line, column int) string {
 	if line < 1 || line > len(er.lines) {
 		return fmt.Sprintf("Error: %s (invalid line number)", message)
 	}
+	return er.ReportDiagnostic(Diagnostic{
+		Severity: SeverityError,
+		Code:     code,
+		Message:  message,
+		File:     er.filename,
+		Range:    Range{Start: Position{Line: line, Column: column}, End: Position{Line: line, Column: column + 1}},
+	})
+}
 
-	// Get the problematic line (convert to 0-based indexing)
-	problemLine := er.lines[line-1]
-
-	var result strings.Builder
+// ReportDiagnostic formats a diagnostic as a Rust-style report
+// The diagnostic's range is underlined with ^ and related locations in the
+// same file with -, labelled with their messages, in one source snippet;
+// other related locations, fixes, help and notes follow as = lines
+func (er *ErrorReporter) ReportDiagnostic(d Diagnostic) string {
+	var labels []label
+	if primary, ok := er.label(d.Range, "", true); ok {
+		labels = append(labels, primary)
+	}
 
-	// Error header
-	result.WriteString(fmt.Sprintf("error: %s\n", message))
-	result.WriteString(fmt.Sprintf("  --> %s:%d:%d\n", er.filename, line, column))
-	result.WriteString("   |\n")
-
-	// Line number with padding
-	lineNumStr := fmt.Sprintf("%d", line)
-	padding := strings.Repeat(" ", len(lineNumStr))
-
-	// Show the line
-	result.WriteString(fmt.Sprintf("%s | %s\n", lineNumStr, problemLine))
-
-	// Show the pointer
-	pointer := strings.Repeat(" ", column-1) + "^"
-	if column <= len(problemLine) {
-		// Try to underline the problematic token
-		endCol := column
-		if endCol < len(problemLine) {
-			// Find the end of the current token
-			for endCol < len(problemLine) && !isWhitespace(problemLine[endCol]) {
-				endCol++
+	var trailers []string
+	for _, related := range d.Related {
+		if related.File == er.filename {
+			if secondary, ok := er.label(related.Range, related.Message, false); ok && len(labels) > 0 {
+				labels = append(labels, secondary)
+				continue
 			}
 		}
-		if endCol > column {
-			pointer = strings.Repeat(" ", column-1) + strings.Repeat("^", endCol-column+1)
+		if related.Range.IsZero() {
+			trailers = append(trailers, er.trailer("note", fmt.Sprintf("%s (%s)", related.Message, related.File)))
+		} else {
+			trailers = append(trailers, er.trailer("note", fmt.Sprintf("%s (%s:%d:%d)", related.Message, related.File, related.Range.Start.Line, related.Range.Start.Column)))
 		}
 	}
-	result.WriteString(fmt.Sprintf("%s | %s\n", padding, pointer))
-	result.WriteString("   |\n")
+	for _, fix := range d.Fixes {
+		trailers = append(trailers, er.trailer("help", fix.Message))
+	}
+	for _, help := range d.Help {
+		trailers = append(trailers, er.trailer("help", help))
+	}
+	for _, note := range d.Notes {
+		trailers = append(trailers, er.trailer("note", note))
+	}
+
+	var result strings.Builder
+	result.WriteString(er.header(string(d.Severity), d.Code, d.Message))
+
+	width := 1
+	if len(labels) == 0 {
+		result.WriteString(fmt.Sprintf(" %s %s\n", er.paint(styleGutter, "-->"), d.File))
+	} else {
+		width = gutterWidth(labels)
+		pad := strings.Repeat(" ", width)
+		start := labels[0].start
+		result.WriteString(fmt.Sprintf("%s%s %s:%d:%d\n", pad, er.paint(styleGutter, "-->"), d.File, start.Line, start.Column))
+		result.WriteString(er.emptyGutter(width))
+		er.writeSnippet(&result, labels, width, levelStyle(string(d.Severity)))
+		result.WriteString(er.emptyGutter(width))
+	}
+
+	for _, trailer := range trailers {
+		result.WriteString(strings.Repeat(" ", width+1) + trailer)
+	}
 
 	return result.String()
 }
 
-// ReportMultipleErrors formats multiple errors
-func (er *ErrorReporter) ReportMultipleErrors(errors []CompilerError) string {
+// ReportDiagnostics formats multiple diagnostics
+func (er *ErrorReporter) ReportDiagnostics(diagnostics []Diagnostic) string {
 	var result strings.Builder
 
-	for i, err := range errors {
+	for i, d := range diagnostics {
 		if i > 0 {
 			result.WriteString("\n")
 		}
-		result.WriteString(er.ReportError(err.Message, err.Line, err.Column))
+		result.WriteString(er.ReportDiagnostic(d))
 	}
 
-	if len(errors) > 1 {
-		result.WriteString(fmt.Sprintf("\nFound %d errors\n", len(errors)))
+	if len(diagnostics) > 1 {
+		result.WriteString(fmt.Sprintf("\nFound %d errors\n", len(diagnostics)))
 	}
 
 	return result.String()
 }
 
+// ReportMultipleErrors formats multiple errors
+func (er *ErrorReporter) ReportMultipleErrors(errors []CompilerError) string {
+	diagnostics := make([]Diagnostic, 0, len(errors))
+	for _, err := range errors {
+		diagnostics = append(diagnostics, err.Diagnostic(er.filename))
+	}
+	return er.ReportDiagnostics(diagnostics)
+}
+
+// BraceFileHelp is the guidance shown for a missing or malformed @brace directive
+var BraceFileHelp = []string{
+	"BRACE files must start with a @brace directive specifying the file format version",
+	"example: @brace \"1.0.0\"",
+}
+
+// BraceFileNotes lists the supported versions for @brace directive errors
+var BraceFileNotes = []string{supportedVersionsNote()}
+
+// supportedVersionsNote lists the supported versions, marking the deprecated
+// and current ones
+func supportedVersionsNote() string {
+	var versions []string
+	for _, v := range version.Supported() {
+		described := fmt.Sprintf("%q", v.String())
+		switch {
+		case v == version.Latest():
+			described += " (current)"
+		case version.IsDeprecated(v):
+			described += " (legacy)"
+		}
+		versions = append(versions, described)
+	}
+	return "supported versions: " + strings.Join(versions, ", ")
+}
+
 // ReportBraceFileError provides specific guidance for @brace directive errors
-func (er *ErrorReporter) ReportBraceFileError(message string) string {
-	var result strings.Builder
+func (er *ErrorReporter) ReportBraceFileError(code, message string, line, column int) string {
+	return er.ReportDiagnostic(CompilerError{
+		Code:    code,
+		Message: message,
+		Line:    line,
+		Column:  column,
+		Help:    BraceFileHelp,
+		Notes:   BraceFileNotes,
+	}.Diagnostic(er.filename))
+}
 
-	result.WriteString(fmt.Sprintf("error: %s\n", message))
-	result.WriteString("   |\n")
-	result.WriteString("   = help: BRACE files must start with a @brace directive specifying the file format version\n")
-	result.WriteString("   = example: @brace \"1.0.0\"\n")
-	result.WriteString("   |\n")
-	result.WriteString("   Supported versions:\n")
-	result.WriteString("   - \"0.0.1\" (legacy)\n")
-	result.WriteString("   - \"1.0.0\" (current)\n")
-	result.WriteString("   |\n")
+// header formats the first line of a report
+func (er *ErrorReporter) header(level, code, message string) string {
+	style := levelStyle(level)
+	if code != "" {
+		level = fmt.Sprintf("%s[%s]", level, code)
+	}
+	return fmt.Sprintf("%s%s\n", er.paint(style, level), er.paint(styleBold, ": "+message))
+}
 
-	return result.String()
+// trailer formats a "= help: ..." or "= note: ..." line
+func (er *ErrorReporter) trailer(kind, message string) string {
+	return fmt.Sprintf("%s %s %s\n", er.paint(styleGutter, "="), er.paint(styleBold, kind+":"), message)
 }
 
-func isWhitespace(ch byte) bool {
-	return ch == ' ' || ch == '\t' || ch == '\n' || ch == '\r'
+// emptyGutter formats a snippet line with no source
+func (er *ErrorReporter) emptyGutter(width int) string {
+	return strings.Repeat(" ", width) + er.paint(styleGutter, " |") + "\n"
 }
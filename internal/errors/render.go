@@ -0,0 +1,423 @@
+package errors
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// ANSI styles used when color is enabled
+const (
+	styleReset   = "\x1b[0m"
+	styleBold    = "\x1b[1m"
+	styleError   = "\x1b[1;31m"
+	styleWarning = "\x1b[1;33m"
+	styleNote    = "\x1b[1;32m"
+	styleGutter  = "\x1b[1;34m" // line numbers, borders and secondary labels
+)
+
+// tabWidth is the number of columns a tab is shown as
+const tabWidth = 4
+
+// levelStyle returns the style of a severity
+func levelStyle(level string) string {
+	switch Severity(level) {
+	case SeverityError:
+		return styleError
+	case SeverityWarning:
+		return styleWarning
+	default:
+		return styleNote
+	}
+}
+
+// paint wraps text in an ANSI style when color is enabled
+func (er *ErrorReporter) paint(style, text string) string {
+	if !er.color || style == "" || text == "" {
+		return text
+	}
+	return style + text + styleReset
+}
+
+// label marks a span of source in a snippet
+type label struct {
+	start   Position
+	end     Position // exclusive
+	message string
+	primary bool
+}
+
+func (l label) multiline() bool {
+	return l.end.Line > l.start.Line
+}
+
+// label returns a label for r clamped to the source, or false if r is not in it
+func (er *ErrorReporter) label(r Range, message string, primary bool) (label, bool) {
+	if r.IsZero() || r.Start.Line < 1 || r.Start.Line > len(er.lines) {
+		return label{}, false
+	}
+
+	l := label{start: r.Start, end: r.End, message: message, primary: primary}
+	if l.start.Column < 1 {
+		l.start.Column = 1
+	}
+	if l.end.Line > len(er.lines) {
+		l.end = Position{Line: len(er.lines), Column: len(er.lines[len(er.lines)-1]) + 1}
+	}
+	// A span ending at the start of a line ends with the line before it
+	if l.end.Line > l.start.Line && l.end.Column <= 1 {
+		l.end = Position{Line: l.end.Line - 1, Column: len(er.lines[l.end.Line-2]) + 1}
+	}
+	if l.end.Line < l.start.Line || (l.end.Line == l.start.Line && l.end.Column <= l.start.Column) {
+		l.end = Position{Line: l.start.Line, Column: l.start.Column + 1}
+	}
+	return l, true
+}
+
+// gutterWidth returns the width of the widest line number shown for labels
+func gutterWidth(labels []label) int {
+	last := 0
+	for _, l := range labels {
+		last = max(last, l.end.Line)
+	}
+	return len(strconv.Itoa(last))
+}
+
+// shownLines returns the lines a snippet shows, in order
+// Long multi-line spans show their first and last two lines; a single
+// line between two shown lines is shown rather than elided
+func shownLines(labels []label) []int {
+	set := make(map[int]bool)
+	for _, l := range labels {
+		if l.end.Line-l.start.Line <= 4 {
+			for line := l.start.Line; line <= l.end.Line; line++ {
+				set[line] = true
+			}
+			continue
+		}
+		for _, line := range []int{l.start.Line, l.start.Line + 1, l.end.Line - 1, l.end.Line} {
+			set[line] = true
+		}
+	}
+
+	sorted := make([]int, 0, len(set))
+	for line := range set {
+		sorted = append(sorted, line)
+	}
+	sort.Ints(sorted)
+
+	var lines []int
+	for _, line := range sorted {
+		if len(lines) > 0 && lines[len(lines)-1] == line-2 {
+			lines = append(lines, line-1)
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// snippet renders labelled source lines
+// Multi-line labels each get a border column left of the source, drawn from
+// the line after the span starts to the line it ends on
+type snippet struct {
+	er           *ErrorReporter
+	out          *strings.Builder
+	width        int
+	primaryStyle string
+	multi        []label
+	open         []bool // whether each multi-line label's border is being drawn
+	indent       int    // columns taken by borders before the source
+}
+
+// writeSnippet writes the source lines labels point at, with their underlines
+func (er *ErrorReporter) writeSnippet(out *strings.Builder, labels []label, width int, primaryStyle string) {
+	s := &snippet{er: er, out: out, width: width, primaryStyle: primaryStyle}
+	for _, l := range labels {
+		if l.multiline() {
+			s.multi = append(s.multi, l)
+		}
+	}
+	s.open = make([]bool, len(s.multi))
+	s.indent = 2 * len(s.multi)
+
+	lines := shownLines(labels)
+	for i, line := range lines {
+		if i > 0 && line > lines[i-1]+1 {
+			out.WriteString(er.paint(styleGutter, "...") + "\n")
+		}
+		text := er.lines[line-1]
+
+		c := s.borders()
+		c.write(s.indent, text, "")
+		s.row(strconv.Itoa(line), c)
+
+		var single []label
+		for _, l := range labels {
+			if !l.multiline() && l.start.Line == line {
+				single = append(single, l)
+			}
+		}
+		s.underline(text, single)
+
+		for j, l := range s.multi {
+			if l.start.Line == line {
+				s.connect(j, displayColumn(text, l.start.Column), "")
+				s.open[j] = true
+			}
+		}
+		for j, l := range s.multi {
+			if l.end.Line == line {
+				s.connect(j, displayColumn(text, l.end.Column)-1, l.message)
+				s.open[j] = false
+			}
+		}
+	}
+}
+
+// style returns the style of a label
+func (s *snippet) style(l label) string {
+	if l.primary {
+		return s.primaryStyle
+	}
+	return styleGutter
+}
+
+// borders returns a row with the borders of the open multi-line labels
+func (s *snippet) borders() canvas {
+	var c canvas
+	for j, l := range s.multi {
+		if s.open[j] {
+			c.set(2*j, "|", s.style(l))
+		}
+	}
+	return c
+}
+
+// row writes a row of the snippet with a line number, or a blank gutter
+func (s *snippet) row(lineNumber string, c canvas) {
+	text := c.render(s.er)
+	if text != "" {
+		text = " " + text
+	}
+	s.out.WriteString(s.er.paint(styleGutter, fmt.Sprintf("%*s |", s.width, lineNumber)) + text + "\n")
+}
+
+// underline writes the underlines of the single-line labels on a line and their messages
+// The rightmost message goes beside its underline; the others hang below,
+// right to left, each joined to its underline by a | line
+func (s *snippet) underline(text string, labels []label) {
+	if len(labels) == 0 {
+		return
+	}
+	sort.SliceStable(labels, func(i, j int) bool { return labels[i].start.Column < labels[j].start.Column })
+
+	span := func(l label) (int, int) {
+		start, end := displayColumn(text, l.start.Column), displayColumn(text, l.end.Column)
+		return s.indent + start, s.indent + max(end, start+1)
+	}
+
+	c := s.borders()
+	last := 0
+	// Secondary underlines go first so the primary span wins where they overlap
+	for _, primary := range []bool{false, true} {
+		for _, l := range labels {
+			if l.primary != primary {
+				continue
+			}
+			start, end := span(l)
+			for col := start; col < end; col++ {
+				c.set(col, marker(l), s.style(l))
+			}
+			last = max(last, end)
+		}
+	}
+
+	var hanging []label
+	for i, l := range labels {
+		if l.message == "" {
+			continue
+		}
+		if _, end := span(l); i == len(labels)-1 && end == last {
+			c.write(end+1, l.message, s.style(l))
+			continue
+		}
+		hanging = append(hanging, l)
+	}
+	s.row("", c)
+
+	for i := len(hanging) - 1; i >= 0; i-- {
+		c := s.borders()
+		for _, l := range hanging[:i+1] {
+			start, _ := span(l)
+			c.set(start, "|", s.style(l))
+		}
+		s.row("", c)
+
+		c = s.borders()
+		for _, l := range hanging[:i] {
+			start, _ := span(l)
+			c.set(start, "|", s.style(l))
+		}
+		start, _ := span(hanging[i])
+		c.write(start, hanging[i].message, s.style(hanging[i]))
+		s.row("", c)
+	}
+}
+
+// connect writes the line joining multi-line label j's border to its start
+// or end, at a display column of the source
+func (s *snippet) connect(j, column int, message string) {
+	l := s.multi[j]
+	style := s.style(l)
+	column += s.indent
+
+	c := s.borders()
+	for col := 2*j + 1; col < column; col++ {
+		c.set(col, "_", style)
+	}
+	c.set(column, marker(l), style)
+	if message != "" {
+		c.write(column+2, message, style)
+	}
+	s.row("", c)
+}
+
+// marker returns the character underlining a label
+func marker(l label) string {
+	if l.primary {
+		return "^"
+	}
+	return "-"
+}
+
+// cell is one display column of a row; wide characters take a cell and an
+// empty continuation cell
+type cell struct {
+	text  string
+	style string
+	set   bool
+}
+
+// canvas is a row of text placed by display column
+type canvas []cell
+
+// set places text in a display column
+func (c *canvas) set(column int, text, style string) {
+	for len(*c) <= column {
+		*c = append(*c, cell{})
+	}
+	(*c)[column] = cell{text: text, style: style, set: true}
+}
+
+// write places text starting at a display column, expanding tabs and
+// giving wide characters two columns
+func (c *canvas) write(column int, text, style string) {
+	for _, r := range text {
+		switch width := runeWidth(r); {
+		case r == '\t':
+			for i := 0; i < tabWidth; i++ {
+				c.set(column, " ", style)
+				column++
+			}
+		case width == 0:
+			// Combining marks join the character before them
+			if column > 0 && column <= len(*c) {
+				(*c)[column-1].text += string(r)
+			}
+		default:
+			c.set(column, string(r), style)
+			for i := 1; i < width; i++ {
+				c.set(column+i, "", style)
+			}
+			column += width
+		}
+	}
+}
+
+// render returns the row's text without trailing spaces
+func (c canvas) render(er *ErrorReporter) string {
+	var b strings.Builder
+	var run strings.Builder
+	style := ""
+	flush := func() {
+		b.WriteString(er.paint(style, run.String()))
+		run.Reset()
+	}
+	for _, cl := range c {
+		text, cellStyle := cl.text, cl.style
+		if !cl.set {
+			text, cellStyle = " ", ""
+		}
+		if cellStyle != style {
+			flush()
+			style = cellStyle
+		}
+		run.WriteString(text)
+	}
+	flush()
+	return strings.TrimRight(b.String(), " ")
+}
+
+// displayColumn returns the display offset of a byte column in a line
+// Columns past the end of the line count one each
+func displayColumn(text string, column int) int {
+	offset := column - 1
+	extra := 0
+	if offset > len(text) {
+		extra = offset - len(text)
+		offset = len(text)
+	}
+	return displayWidth(text[:max(offset, 0)]) + extra
+}
+
+// displayWidth returns the number of columns text takes in a terminal
+func displayWidth(text string) int {
+	width := 0
+	for _, r := range text {
+		width += runeWidth(r)
+	}
+	return width
+}
+
+// runeWidth returns the number of columns a character takes in a terminal
+func runeWidth(r rune) int {
+	switch {
+	case r == '\t':
+		return tabWidth
+	case r == '\r', unicode.In(r, unicode.Mn, unicode.Me, unicode.Cf):
+		return 0
+	case isWide(r):
+		return 2
+	default:
+		return 1
+	}
+}
+
+// wideRanges are the East Asian wide and fullwidth characters and emoji
+var wideRanges = []struct{ lo, hi rune }{
+	{0x1100, 0x115F},
+	{0x2E80, 0x303E},
+	{0x3041, 0x33FF},
+	{0x3400, 0x4DBF},
+	{0x4E00, 0x9FFF},
+	{0xA000, 0xA4CF},
+	{0xAC00, 0xD7A3},
+	{0xF900, 0xFAFF},
+	{0xFE30, 0xFE4F},
+	{0xFF00, 0xFF60},
+	{0xFFE0, 0xFFE6},
+	{0x1F300, 0x1F64F},
+	{0x1F900, 0x1F9FF},
+	{0x20000, 0x3FFFD},
+}
+
+func isWide(r rune) bool {
+	for _, wide := range wideRanges {
+		if r >= wide.lo && r <= wide.hi {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,150 @@
+package errors
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestReportDiagnostic(t *testing.T) {
+	tests := []struct {
+		name     string
+		source   string
+		d        Diagnostic
+		expected string
+	}{
+		{
+			name:   "exact token length",
+			source: "x = [1, \"a b\"]\n",
+			d: Diagnostic{
+				Severity: SeverityError,
+				Code:     "E0601",
+				Message:  "mixed array element types",
+				File:     "f.brace",
+				Range:    Range{Start: Position{Line: 1, Column: 9}, End: Position{Line: 1, Column: 14}},
+			},
+			expected: `error[E0601]: mixed array element types
+ --> f.brace:1:9
+  |
+1 | x = [1, "a b"]
+  |         ^^^^^
+  |
+`,
+		},
+		{
+			name:   "tabs and wide characters",
+			source: "\tname = \"日本\" x = :B\n",
+			d: Diagnostic{
+				Severity: SeverityError,
+				Message:  "undefined reference",
+				File:     "f.brace",
+				Range:    Range{Start: Position{Line: 1, Column: 22}, End: Position{Line: 1, Column: 24}},
+			},
+			expected: `error: undefined reference
+ --> f.brace:1:22
+  |
+1 |     name = "日本" x = :B
+  |                       ^^
+  |
+`,
+		},
+		{
+			name:   "labels on one line",
+			source: "y = [1, \"a\"]\n",
+			d: Diagnostic{
+				Severity: SeverityWarning,
+				Code:     "heterogeneous-array",
+				Message:  "array mixes number and string elements",
+				File:     "f.brace",
+				Range:    Range{Start: Position{Line: 1, Column: 9}, End: Position{Line: 1, Column: 12}},
+				Related: []RelatedLocation{
+					{Message: "first number element here", File: "f.brace", Range: Range{Start: Position{Line: 1, Column: 6}, End: Position{Line: 1, Column: 7}}},
+				},
+				Help: []string{"keep arrays homogeneous"},
+			},
+			expected: `warning[heterogeneous-array]: array mixes number and string elements
+ --> f.brace:1:9
+  |
+1 | y = [1, "a"]
+  |      -  ^^^
+  |      |
+  |      first number element here
+  |
+  = help: keep arrays homogeneous
+`,
+		},
+		{
+			name:   "multi-line span",
+			source: "x = [1, \"\"\"abc\ndef\nghi\"\"\"]\n",
+			d: Diagnostic{
+				Severity: SeverityError,
+				Message:  "mixed array element types",
+				File:     "f.brace",
+				Range:    Range{Start: Position{Line: 1, Column: 9}, End: Position{Line: 3, Column: 7}},
+				Related: []RelatedLocation{
+					{Message: "number here", File: "f.brace", Range: Range{Start: Position{Line: 1, Column: 6}, End: Position{Line: 1, Column: 7}}},
+					{Message: "elsewhere", File: "other.brace", Range: Range{Start: Position{Line: 2, Column: 1}, End: Position{Line: 2, Column: 2}}},
+				},
+			},
+			expected: `error: mixed array element types
+ --> f.brace:1:9
+  |
+1 |   x = [1, """abc
+  |        - number here
+  |  _________^
+2 | | def
+3 | | ghi"""]
+  | |______^
+  |
+  = note: elsewhere (other.brace:2:1)
+`,
+		},
+		{
+			name:   "distant lines",
+			source: "a = 1\nb = 2\nc = 3\nd = 4\ne = 5\nf = 6\nlong = 7\nh = 8\ni = 9\nj = 10\n",
+			d: Diagnostic{
+				Severity: SeverityError,
+				Message:  "duplicate",
+				File:     "f.brace",
+				Range:    Range{Start: Position{Line: 10, Column: 1}, End: Position{Line: 10, Column: 2}},
+				Related: []RelatedLocation{
+					{Message: "first here", File: "f.brace", Range: Range{Start: Position{Line: 1, Column: 1}, End: Position{Line: 1, Column: 2}}},
+				},
+			},
+			expected: `error: duplicate
+  --> f.brace:10:1
+   |
+ 1 | a = 1
+   | - first here
+...
+10 | j = 10
+   | ^
+   |
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		report := NewErrorReporter(tt.source, "f.brace").ReportDiagnostic(tt.d)
+		if report != tt.expected {
+			t.Errorf("%s: expected\n%s\ngot\n%s", tt.name, tt.expected, report)
+		}
+	}
+}
+
+func TestReportColor(t *testing.T) {
+	reporter := NewErrorReporter("x = :A\n", "f.brace")
+	d := Diagnostic{Severity: SeverityError, Code: "E0101", Message: "undefined reference", File: "f.brace",
+		Range: Range{Start: Position{Line: 1, Column: 5}, End: Position{Line: 1, Column: 7}}}
+
+	if plain := reporter.ReportDiagnostic(d); strings.Contains(plain, "\x1b[") {
+		t.Errorf("expected no escape codes without color, got %q", plain)
+	}
+
+	reporter.SetColor(true)
+	colored := reporter.ReportDiagnostic(d)
+	for _, expected := range []string{styleError + "error[E0101]" + styleReset, styleError + "^^" + styleReset} {
+		if !strings.Contains(colored, expected) {
+			t.Errorf("expected colored report to contain %q, got %q", expected, colored)
+		}
+	}
+}
@@ -0,0 +1,173 @@
+package errors
+
+import "encoding/json"
+
+// SARIF types cover the subset of SARIF 2.1.0 needed to report diagnostics
+// to code scanning tools
+
+type sarifLog struct {
+	Version string     `json:"version"`
+	Schema  string     `json:"$schema"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name    string      `json:"name"`
+	Version string      `json:"version,omitempty"`
+	Rules   []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID string `json:"id"`
+}
+
+type sarifResult struct {
+	RuleID           string          `json:"ruleId"`
+	Level            string          `json:"level"`
+	Message          sarifMessage    `json:"message"`
+	Locations        []sarifLocation `json:"locations"`
+	RelatedLocations []sarifLocation `json:"relatedLocations,omitempty"`
+	Fixes            []sarifFix      `json:"fixes,omitempty"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+	Message          *sarifMessage         `json:"message,omitempty"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           *sarifRegion          `json:"region,omitempty"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+type sarifRegion struct {
+	StartLine   int `json:"startLine"`
+	StartColumn int `json:"startColumn"`
+	EndLine     int `json:"endLine"`
+	EndColumn   int `json:"endColumn"`
+}
+
+type sarifFix struct {
+	Description     sarifMessage          `json:"description"`
+	ArtifactChanges []sarifArtifactChange `json:"artifactChanges"`
+}
+
+type sarifArtifactChange struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Replacements     []sarifReplacement    `json:"replacements"`
+}
+
+type sarifReplacement struct {
+	DeletedRegion   sarifRegion   `json:"deletedRegion"`
+	InsertedContent *sarifMessage `json:"insertedContent,omitempty"`
+}
+
+// sarifDefaultRule is the rule ID of diagnostics without a code
+const sarifDefaultRule = "brace"
+
+// SARIF encodes diagnostics as a SARIF 2.1.0 log for code scanning tools
+func SARIF(diagnostics []Diagnostic, toolVersion string) ([]byte, error) {
+	run := sarifRun{
+		Tool:    sarifTool{Driver: sarifDriver{Name: "brace", Version: toolVersion, Rules: []sarifRule{}}},
+		Results: []sarifResult{},
+	}
+
+	seenRules := make(map[string]bool)
+	for _, d := range diagnostics {
+		ruleID := d.Code
+		if ruleID == "" {
+			ruleID = sarifDefaultRule
+		}
+		if !seenRules[ruleID] {
+			seenRules[ruleID] = true
+			run.Tool.Driver.Rules = append(run.Tool.Driver.Rules, sarifRule{ID: ruleID})
+		}
+
+		result := sarifResult{
+			RuleID:    ruleID,
+			Level:     sarifLevel(d.Severity),
+			Message:   sarifMessage{Text: d.Message},
+			Locations: []sarifLocation{sarifLocationOf(d.File, d.Range, "")},
+		}
+		for _, related := range d.Related {
+			result.RelatedLocations = append(result.RelatedLocations, sarifLocationOf(related.File, related.Range, related.Message))
+		}
+		for _, fix := range d.Fixes {
+			change := sarifArtifactChange{ArtifactLocation: sarifArtifactLocation{URI: d.File}}
+			for _, edit := range fix.Edits {
+				region := sarifRegionOf(edit.Range)
+				if region == nil {
+					continue
+				}
+				change.Replacements = append(change.Replacements, sarifReplacement{
+					DeletedRegion:   *region,
+					InsertedContent: &sarifMessage{Text: edit.NewText},
+				})
+			}
+			result.Fixes = append(result.Fixes, sarifFix{
+				Description:     sarifMessage{Text: fix.Message},
+				ArtifactChanges: []sarifArtifactChange{change},
+			})
+		}
+		run.Results = append(run.Results, result)
+	}
+
+	return json.MarshalIndent(sarifLog{
+		Version: "2.1.0",
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Runs:    []sarifRun{run},
+	}, "", "  ")
+}
+
+// sarifLevel maps a severity to a SARIF result level
+func sarifLevel(severity Severity) string {
+	switch severity {
+	case SeverityWarning:
+		return "warning"
+	case SeverityNote:
+		return "note"
+	default:
+		return "error"
+	}
+}
+
+func sarifLocationOf(file string, r Range, message string) sarifLocation {
+	location := sarifLocation{PhysicalLocation: sarifPhysicalLocation{
+		ArtifactLocation: sarifArtifactLocation{URI: file},
+		Region:           sarifRegionOf(r),
+	}}
+	if message != "" {
+		location.Message = &sarifMessage{Text: message}
+	}
+	return location
+}
+
+// sarifRegionOf converts a range, or returns nil for a whole-file range
+func sarifRegionOf(r Range) *sarifRegion {
+	if r.IsZero() {
+		return nil
+	}
+	return &sarifRegion{
+		StartLine:   r.Start.Line,
+		StartColumn: r.Start.Column,
+		EndLine:     r.End.Line,
+		EndColumn:   r.End.Column,
+	}
+}
@@ -0,0 +1,45 @@
+package errors
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestSARIF(t *testing.T) {
+	diagnostics := []Diagnostic{
+		{
+			Severity: SeverityError,
+			Message:  "undefined reference: global.B",
+			File:     "config.brace",
+			Range:    Range{Start: Position{Line: 3, Column: 5}, End: Position{Line: 3, Column: 7}},
+		},
+		{Severity: SeverityWarning, Code: "W1", Message: "whole file", File: "config.brace"},
+	}
+
+	data, err := SARIF(diagnostics, "1.0.0")
+	if err != nil {
+		t.Fatalf("SARIF failed: %v", err)
+	}
+
+	var log sarifLog
+	if err := json.Unmarshal(data, &log); err != nil {
+		t.Fatalf("invalid SARIF JSON: %v", err)
+	}
+	if log.Version != "2.1.0" || len(log.Runs) != 1 {
+		t.Fatalf("unexpected log: %+v", log)
+	}
+	run := log.Runs[0]
+	if len(run.Tool.Driver.Rules) != 2 || len(run.Results) != 2 {
+		t.Fatalf("expected 2 rules and 2 results, got %+v", run)
+	}
+
+	first := run.Results[0]
+	region := first.Locations[0].PhysicalLocation.Region
+	if first.RuleID != sarifDefaultRule || region == nil || region.StartLine != 3 || region.EndColumn != 7 {
+		t.Errorf("unexpected first result: %+v", first)
+	}
+	second := run.Results[1]
+	if second.Level != "warning" || second.Locations[0].PhysicalLocation.Region != nil {
+		t.Errorf("expected a whole-file warning, got %+v", second)
+	}
+}
@@ -0,0 +1,64 @@
+package errors
+
+import (
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// Suggest returns the candidate closest to name by edit distance, if one is
+// close enough to be a likely typo
+// Differences in case alone always match, and a candidate that shares no rune
+// with name is never close; ties go to the alphabetically first candidate
+func Suggest(name string, candidates []string) (string, bool) {
+	sorted := append([]string(nil), candidates...)
+	sort.Strings(sorted)
+
+	length := utf8.RuneCountInString(name)
+	maxDistance := length / 3
+	if maxDistance < 1 {
+		maxDistance = 1
+	}
+	if maxDistance >= length {
+		maxDistance = length - 1
+	}
+
+	best, bestDistance := "", maxDistance+1
+	for _, candidate := range sorted {
+		if candidate == name {
+			continue
+		}
+		distance := editDistance(strings.ToLower(name), strings.ToLower(candidate))
+		if distance < bestDistance {
+			best, bestDistance = candidate, distance
+		}
+	}
+	return best, best != ""
+}
+
+// editDistance returns the edit distance between a and b in runes, counting
+// insertions, deletions, substitutions and swaps of adjacent runes
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	rows := make([][]int, len(ra)+1)
+	for i := range rows {
+		rows[i] = make([]int, len(rb)+1)
+		rows[i][0] = i
+	}
+	for j := range rows[0] {
+		rows[0][j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			rows[i][j] = min(rows[i-1][j]+1, rows[i][j-1]+1, rows[i-1][j-1]+cost)
+			if i > 1 && j > 1 && ra[i-1] == rb[j-2] && ra[i-2] == rb[j-1] {
+				rows[i][j] = min(rows[i][j], rows[i-2][j-2]+1)
+			}
+		}
+	}
+	return rows[len(ra)][len(rb)]
+}
@@ -0,0 +1,30 @@
+package errors
+
+import "testing"
+
+func TestSuggest(t *testing.T) {
+	candidates := []string{"VERSION", "HOST", "file", "secret", "env_policy", "A", "go"}
+	tests := []struct {
+		name     string
+		expected string
+	}{
+		{"VERSON", "VERSION"},
+		{"fiel", "file"},
+		{"host", "HOST"},
+		{"env_polcy", "env_policy"},
+		{"sercet", "secret"},
+		{"PORT", ""},
+		{"x", ""},
+		{"X", ""},
+		{"a", "A"},
+		{"og", "go"},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		got, ok := Suggest(tt.name, candidates)
+		if got != tt.expected || ok != (tt.expected != "") {
+			t.Errorf("Suggest(%q): expected %q, got %q", tt.name, tt.expected, got)
+		}
+	}
+}
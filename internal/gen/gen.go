@@ -0,0 +1,29 @@
+// Package gen generates Go and TypeScript type declarations for compiled
+// BRACE files
+package gen
+
+import "fmt"
+
+// Options configures code generation
+type Options struct {
+	Lang      string // go or ts
+	Package   string // Go package name
+	TypeName  string // name of the root type
+	Constants bool   // whether to declare @const values
+}
+
+// Generate returns source declaring the types and constants of m
+func Generate(m *Model, opts Options) (string, error) {
+	switch opts.Lang {
+	case "go":
+		pkg := opts.Package
+		if pkg == "" {
+			pkg = "config"
+		}
+		return Go(m, pkg, opts.Constants)
+	case "ts", "typescript":
+		return TypeScript(m, opts.Constants), nil
+	default:
+		return "", fmt.Errorf("unsupported language %q (use go or ts)", opts.Lang)
+	}
+}
@@ -0,0 +1,211 @@
+package gen
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"strings"
+	"testing"
+
+	"github.com/tomdoesdev/brace/internal/compiler"
+	"github.com/tomdoesdev/brace/internal/doc"
+)
+
+// generate compiles source and generates code for it with opts
+func generate(t *testing.T, source string, opts Options) string {
+	t.Helper()
+	c := compiler.New(compiler.WithRedaction())
+	output, err := c.CompileValue(source, "config.brace")
+	if err != nil {
+		t.Fatalf("compilation failed: %v", err)
+	}
+	ref := doc.Build("config.brace", c.Program(), c.SyntaxTree(), output, c.Analyzer().Constant)
+	code, err := Generate(Infer(output, c.Locations(), ref, "Config"), opts)
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+	return code
+}
+
+func TestGenerate(t *testing.T) {
+	source := `@brace "1.0.0"
+
+@const "net" {
+    // Default HTTP port
+    PORT = 8080
+}
+
+name = "api"
+
+// HTTP server settings
+#server {
+    // Port to listen on
+    port = :net.PORT
+    ratio = 0.5
+}
+
+upstreams = [
+    { host = "a", weight = 1 },
+    { host = "b", weight = 2.5, tls = true }
+]
+tags = ["x", "y"]
+`
+	code := generate(t, source, Options{Lang: "go", Package: "settings", Constants: true})
+	typeCheck(t, code)
+	for _, want := range []string{
+		"package settings",
+		"NetPORT = 8080",
+		"Name string `json:\"name\"`",
+		"// HTTP server settings\n\tServer    Server     `json:\"server\"`",
+		"Upstreams []Upstream `json:\"upstreams\"`",
+		"Tags      []string   `json:\"tags\"`",
+		"// Port to listen on\n\tPort  int64   `json:\"port\"`",
+		"Ratio float64 `json:\"ratio\"`",
+		"Weight float64 `json:\"weight\"`",
+		"TLS    bool    `json:\"tls,omitempty\"`",
+	} {
+		if !strings.Contains(code, want) {
+			t.Errorf("expected the Go source to contain\n%s\ngot\n%s", want, code)
+		}
+	}
+
+	code = generate(t, source, Options{Lang: "ts"})
+	for _, want := range []string{
+		"export interface Config {",
+		"  upstreams: Upstream[];",
+		"  /** Port to listen on */\n  port: number;",
+		"  tls?: boolean;",
+	} {
+		if !strings.Contains(code, want) {
+			t.Errorf("expected the TypeScript source to contain\n%s\ngot\n%s", want, code)
+		}
+	}
+	if strings.Contains(code, "NetPORT") {
+		t.Errorf("expected constants only when asked for, got\n%s", code)
+	}
+
+	// Constants share the types' namespace, and some keys cannot be tag names
+	source = "@brace \"1.0.0\"\n@const { Config = 2 }\nkeys = { \"-\" = 1, \"\" = 2, ok = 3 }\n"
+	code = generate(t, source, Options{Lang: "go", Constants: true})
+	typeCheck(t, code)
+	for _, want := range []string{
+		"Config2 = 2",
+		"type Config struct",
+		"`json:\"-,\"`",
+		"// Key \"\" cannot be named in a struct tag",
+	} {
+		if !strings.Contains(code, want) {
+			t.Errorf("expected the Go source to contain\n%s\ngot\n%s", want, code)
+		}
+	}
+}
+
+// typeCheck fails the test unless code is a well-typed Go file
+func typeCheck(t *testing.T, code string) {
+	t.Helper()
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "config.go", code, 0)
+	if err != nil {
+		t.Fatalf("generated Go does not parse: %v\n%s", err, code)
+	}
+	if _, err := new(types.Config).Check("config", fset, []*ast.File{file}, nil); err != nil {
+		t.Fatalf("generated Go does not type-check: %v\n%s", err, code)
+	}
+}
+
+func TestExportedName(t *testing.T) {
+	tests := map[string]string{
+		"name":       "Name",
+		"apiVersion": "APIVersion",
+		"max_conns":  "MaxConns",
+		"net.PORT":   "NetPORT",
+		"tls":        "TLS",
+		"a b-c":      "ABC",
+		"2fa":        "X2fa",
+		"-":          "X",
+		"":           "X",
+		"größe":      "Größe",
+	}
+	for key, want := range tests {
+		if got := ExportedName(key); got != want {
+			t.Errorf("ExportedName(%q): expected %s, got %s", key, want, got)
+		}
+	}
+}
+
+func TestSingular(t *testing.T) {
+	tests := map[string]string{
+		"Upstreams": "Upstream",
+		"Entries":   "Entry",
+		"Address":   "AddressItem",
+		"Ids":       "IdsItem",
+		"Data":      "DataItem",
+	}
+	for name, want := range tests {
+		if got := singular(name); got != want {
+			t.Errorf("singular(%q): expected %s, got %s", name, want, got)
+		}
+	}
+}
+
+func TestGoType(t *testing.T) {
+	server := &Type{Kind: Object, Name: "Server"}
+	tests := []struct {
+		t        *Type
+		optional bool
+		want     string
+	}{
+		{&Type{Kind: String}, false, "string"},
+		{&Type{Kind: Int, Nullable: true}, false, "*int64"},
+		{&Type{Kind: Float}, true, "float64"},
+		{&Type{Kind: Array, Elem: &Type{Kind: Bool}}, false, "[]bool"},
+		{server, false, "Server"},
+		{server, true, "*Server"},
+		{&Type{Kind: Array, Elem: server}, true, "[]Server"},
+		{&Type{Kind: Any}, false, "any"},
+		{&Type{Kind: Null}, false, "any"},
+	}
+	for _, tt := range tests {
+		if got := goType(tt.t, tt.optional); got != tt.want {
+			t.Errorf("goType(%+v, %v): expected %s, got %s", tt.t, tt.optional, tt.want, got)
+		}
+	}
+}
+
+func TestTSKey(t *testing.T) {
+	tests := map[string]string{
+		"host":     "host",
+		"$ref":     "$ref",
+		"_id":      "_id",
+		"a b":      `"a b"`,
+		"2fa":      `"2fa"`,
+		"app.name": `"app.name"`,
+		"":         `""`,
+	}
+	for key, want := range tests {
+		if got := tsKey(key); got != want {
+			t.Errorf("tsKey(%q): expected %s, got %s", key, want, got)
+		}
+	}
+}
+
+func TestValidTag(t *testing.T) {
+	tests := map[string]bool{
+		"host":      true,
+		"max_conns": true,
+		"app.name":  true,
+		"a b":       true,
+		"-":         true,
+		"größe":     true,
+		"":          false,
+		"a,b":       false,
+		"say\"hi\"": false,
+		"back\\":    false,
+	}
+	for key, want := range tests {
+		if got := validTag(key); got != want {
+			t.Errorf("validTag(%q): expected %v, got %v", key, want, got)
+		}
+	}
+}
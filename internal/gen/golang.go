@@ -0,0 +1,139 @@
+package gen
+
+import (
+	"fmt"
+	"go/format"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Go generates Go source declaring the model's types as structs, and its
+// constants as a const block if constants is set
+// Fields have json struct tags naming their keys, so the compiled
+// JSON output decodes into the root type with encoding/json
+func Go(m *Model, pkg string, constants bool) (string, error) {
+	var out strings.Builder
+	fmt.Fprintf(&out, "// Code generated by brace gen from %s; DO NOT EDIT.\n\npackage %s\n", m.File, pkg)
+
+	if constants && len(m.Constants) > 0 {
+		fmt.Fprintf(&out, "\n// Constants declared with @const in %s\nconst (\n", m.File)
+		for _, c := range m.Constants {
+			literal, ok := goLiteral(c.Value)
+			if !ok {
+				continue
+			}
+			writeGoComment(&out, "\t", c.Description)
+			fmt.Fprintf(&out, "\t%s = %s\n", c.Name, literal)
+		}
+		out.WriteString(")\n")
+	}
+
+	for i, t := range m.Types {
+		out.WriteString("\n")
+		if i == 0 {
+			fmt.Fprintf(&out, "// %s is the configuration compiled from %s\n", t.Name, m.File)
+		}
+		fmt.Fprintf(&out, "type %s struct {\n", t.Name)
+		used := make(map[string]bool)
+		for _, f := range t.Fields {
+			if !validTag(f.Key) {
+				fmt.Fprintf(&out, "\t// Key %s cannot be named in a struct tag\n", strconv.Quote(f.Key))
+				continue
+			}
+			name := ExportedName(f.Key)
+			for base, n := name, 2; used[name]; n++ {
+				name = base + strconv.Itoa(n)
+			}
+			used[name] = true
+
+			// A tag of "-" skips the field; "-," names the key "-"
+			tag := f.Key
+			if tag == "-" {
+				tag += ","
+			}
+			if f.Optional {
+				tag = strings.TrimSuffix(tag, ",") + ",omitempty"
+			}
+			writeGoComment(&out, "\t", f.Description)
+			fmt.Fprintf(&out, "\t%s %s `json:%q`\n", name, goType(f.Type, f.Optional), tag)
+		}
+		out.WriteString("}\n")
+	}
+
+	source, err := format.Source([]byte(out.String()))
+	if err != nil {
+		return "", fmt.Errorf("formatting generated Go: %w", err)
+	}
+	return string(source), nil
+}
+
+// goType returns the Go type of a field; nullable and optional objects and
+// nullable scalars are pointers
+func goType(t *Type, optional bool) string {
+	var name string
+	switch t.Kind {
+	case String:
+		name = "string"
+	case Int:
+		name = "int64"
+	case Float:
+		name = "float64"
+	case Bool:
+		name = "bool"
+	case Array:
+		return "[]" + goType(t.Elem, false)
+	case Object:
+		name = t.Name
+		if optional {
+			return "*" + name
+		}
+	default:
+		return "any"
+	}
+	if t.Nullable {
+		return "*" + name
+	}
+	return name
+}
+
+// goLiteral returns a Go constant literal for a scalar value
+func goLiteral(value interface{}) (string, bool) {
+	switch v := value.(type) {
+	case string:
+		return strconv.Quote(v), true
+	case bool:
+		return strconv.FormatBool(v), true
+	case int64:
+		return strconv.FormatInt(v, 10), true
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64), true
+	default:
+		return "", false
+	}
+}
+
+// validTag reports whether key can be the name in a json struct tag; for
+// other keys encoding/json would fall back to the Go field name
+func validTag(key string) bool {
+	if key == "" {
+		return false
+	}
+	for _, c := range key {
+		switch {
+		case strings.ContainsRune("!#$%&()*+-./:;<=>?@[]^_{|}~ ", c):
+		case !unicode.IsLetter(c) && !unicode.IsDigit(c):
+			return false
+		}
+	}
+	return true
+}
+
+func writeGoComment(out *strings.Builder, indent, text string) {
+	if text == "" {
+		return
+	}
+	for _, line := range strings.Split(text, "\n") {
+		fmt.Fprintf(out, "%s// %s\n", indent, line)
+	}
+}
@@ -0,0 +1,258 @@
+package gen
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/tomdoesdev/brace/internal/doc"
+	"github.com/tomdoesdev/brace/internal/query"
+	"github.com/tomdoesdev/brace/internal/transform"
+	"github.com/tomdoesdev/brace/internal/value"
+)
+
+// Kind is the kind of an inferred type
+type Kind int
+
+const (
+	Null Kind = iota // only null was seen
+	Any              // values of different types
+	String
+	Int   // numbers without a fraction
+	Float // numbers, some with a fraction
+	Bool
+	Object
+	Array
+)
+
+// Type is a type inferred from compiled values
+type Type struct {
+	Kind     Kind
+	Nullable bool     // null was seen alongside values of the type
+	Elem     *Type    // element type of arrays
+	Fields   []*Field // fields of objects, in source order
+	Name     string   // name of object types
+}
+
+// Field is a key of an object type
+type Field struct {
+	Key         string
+	Type        *Type
+	Optional    bool // missing from some of the objects the type was inferred from
+	Description string
+	location    transform.Location
+}
+
+// Constant is an @const value
+type Constant struct {
+	Name        string // exported name: namespace and name joined, such as NetPORT for :net.PORT
+	Value       interface{}
+	Description string
+}
+
+// Model is the types and constants of a compiled file
+type Model struct {
+	File      string
+	Root      *Type
+	Types     []*Type // named object types, root first
+	Constants []Constant
+}
+
+// Infer builds the type model of a compiled file
+// Fields are ordered as in the source using locations (see transform.Locations),
+// described by the doc comments in ref, and ref's constants become constants
+// unless they hold secrets
+func Infer(output map[string]interface{}, locations map[string]transform.Location, ref *doc.Reference, rootName string) *Model {
+	i := &inferrer{locations: locations}
+	root := value.TypeOf(output, func(pointer string) interface{} { return pointer })
+	m := &Model{File: ref.File, Root: i.convert(root)}
+
+	descriptions := make(map[string]string)
+	for _, e := range ref.Entries {
+		descriptions[e.Path] = e.Description
+	}
+	describe(m.Root, nil, descriptions)
+
+	names := &namer{used: make(map[string]bool)}
+	names.name(m.Root, rootName, "")
+	m.Types = names.types
+
+	// Constants share the Go namespace of the types, so names taken by types
+	// are numbered like any other collision
+	for _, c := range ref.Constants {
+		if c.Secret {
+			continue
+		}
+		name := ExportedName(strings.TrimPrefix(c.Path, ":"))
+		for base, i := name, 2; names.used[name]; i++ {
+			name = base + strconv.Itoa(i)
+		}
+		names.used[name] = true
+		m.Constants = append(m.Constants, Constant{
+			Name:        name,
+			Value:       c.Value,
+			Description: c.Description,
+		})
+	}
+	return m
+}
+
+type inferrer struct {
+	locations map[string]transform.Location
+}
+
+// convert returns the generated type of an inferred type, splitting numbers
+// into integers and floats and ordering fields as in the source
+// The Source of inferred types is their JSON pointer in the output
+func (i *inferrer) convert(t *value.Type) *Type {
+	switch t.Kind {
+	case value.Null:
+		return &Type{Kind: Null}
+	case value.String:
+		return &Type{Kind: String, Nullable: t.Nullable}
+	case value.Number:
+		if t.Integer {
+			return &Type{Kind: Int, Nullable: t.Nullable}
+		}
+		return &Type{Kind: Float, Nullable: t.Nullable}
+	case value.Boolean:
+		return &Type{Kind: Bool, Nullable: t.Nullable}
+	case value.Array:
+		elem := &Type{Kind: Any}
+		if t.Elem != nil {
+			elem = i.convert(t.Elem)
+		}
+		return &Type{Kind: Array, Nullable: t.Nullable, Elem: elem}
+	case value.Object:
+		c := &Type{Kind: Object, Nullable: t.Nullable}
+		for key, f := range t.Fields {
+			c.Fields = append(c.Fields, &Field{
+				Key:      key,
+				Type:     i.convert(f.Type),
+				Optional: f.Optional,
+				location: transform.Locate(i.locations, f.Type.Source.(string)),
+			})
+		}
+		sortFields(c.Fields)
+		return c
+	default:
+		return &Type{Kind: Any}
+	}
+}
+
+// sortFields orders fields as in the source; fields from outside the file,
+// such as @file contents, share a location and are ordered by key
+func sortFields(fields []*Field) {
+	sort.SliceStable(fields, func(a, b int) bool {
+		la, lb := fields[a].location, fields[b].location
+		if la.Line != lb.Line {
+			return la.Line < lb.Line
+		}
+		if la.Column != lb.Column {
+			return la.Column < lb.Column
+		}
+		return fields[a].Key < fields[b].Key
+	})
+}
+
+// describe sets the descriptions of the fields of t and the objects in it
+// from doc comments by key path; keys inside arrays are not documented
+func describe(t *Type, path query.Path, descriptions map[string]string) {
+	if t.Kind != Object {
+		return
+	}
+	for _, f := range t.Fields {
+		child := append(append(query.Path{}, path...), query.Segment{Kind: query.KeySegment, Key: f.Key})
+		f.Description = descriptions[child.String()]
+		describe(f.Type, child, descriptions)
+	}
+}
+
+// namer names object types after the keys that hold them
+type namer struct {
+	used  map[string]bool
+	types []*Type
+}
+
+// name names t and the object types in it, depth first in source order
+// Names that are taken are prefixed with the parent's name, then numbered
+func (n *namer) name(t *Type, name, parent string) {
+	switch t.Kind {
+	case Array:
+		n.name(t.Elem, singular(name), parent)
+	case Object:
+		if n.used[name] {
+			name = parent + name
+		}
+		for base, i := name, 2; n.used[name]; i++ {
+			name = base + strconv.Itoa(i)
+		}
+		n.used[name] = true
+		t.Name = name
+		n.types = append(n.types, t)
+		for _, f := range t.Fields {
+			n.name(f.Type, ExportedName(f.Key), name)
+		}
+	}
+}
+
+// singular returns the name of an element of a list called name
+func singular(name string) string {
+	switch {
+	case strings.HasSuffix(name, "ies") && len(name) > 4:
+		return name[:len(name)-3] + "y"
+	case strings.HasSuffix(name, "s") && !strings.HasSuffix(name, "ss") && len(name) > 3:
+		return name[:len(name)-1]
+	default:
+		return name + "Item"
+	}
+}
+
+// initialisms are written in capitals in exported names, as in Go
+var initialisms = map[string]bool{
+	"api": true, "dns": true, "http": true, "https": true, "id": true, "ip": true,
+	"json": true, "sql": true, "ssh": true, "tcp": true, "tls": true, "ttl": true,
+	"udp": true, "ui": true, "uri": true, "url": true, "uuid": true, "yaml": true,
+}
+
+// ExportedName converts a key to an exported identifier: words split at
+// punctuation and lower-to-upper case changes are capitalized and joined
+// apiVersion becomes APIVersion, max_conns MaxConns and net.PORT NetPORT
+func ExportedName(key string) string {
+	var words []string
+	var word []rune
+	flush := func() {
+		if len(word) > 0 {
+			words = append(words, string(word))
+			word = nil
+		}
+	}
+	runes := []rune(key)
+	for i, r := range runes {
+		switch {
+		case !unicode.IsLetter(r) && !unicode.IsDigit(r):
+			flush()
+			continue
+		case unicode.IsUpper(r) && i > 0 && unicode.IsLower(runes[i-1]):
+			flush()
+		}
+		word = append(word, r)
+	}
+	flush()
+
+	var b strings.Builder
+	for _, w := range words {
+		if initialisms[strings.ToLower(w)] {
+			b.WriteString(strings.ToUpper(w))
+			continue
+		}
+		r := []rune(w)
+		b.WriteString(string(unicode.ToUpper(r[0])) + string(r[1:]))
+	}
+	name := b.String()
+	if name == "" || unicode.IsDigit([]rune(name)[0]) {
+		name = "X" + name
+	}
+	return name
+}
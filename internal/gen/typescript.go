@@ -0,0 +1,106 @@
+package gen
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// TypeScript generates TypeScript source declaring the model's types as
+// exported interfaces, and its constants as exported consts if constants is set
+// Properties are named by their keys, matching the compiled JSON output
+func TypeScript(m *Model, constants bool) string {
+	var out strings.Builder
+	fmt.Fprintf(&out, "// Code generated by brace gen from %s; DO NOT EDIT.\n", m.File)
+
+	if constants && len(m.Constants) > 0 {
+		out.WriteString("\n")
+		for _, c := range m.Constants {
+			literal, ok := goLiteral(c.Value)
+			if !ok {
+				continue
+			}
+			if s, isString := c.Value.(string); isString {
+				data, _ := json.Marshal(s)
+				literal = string(data)
+			}
+			writeJSDoc(&out, "", c.Description)
+			fmt.Fprintf(&out, "export const %s = %s;\n", c.Name, literal)
+		}
+	}
+
+	for i, t := range m.Types {
+		out.WriteString("\n")
+		if i == 0 {
+			writeJSDoc(&out, "", fmt.Sprintf("%s is the configuration compiled from %s", t.Name, m.File))
+		}
+		fmt.Fprintf(&out, "export interface %s {\n", t.Name)
+		for _, f := range t.Fields {
+			writeJSDoc(&out, "  ", f.Description)
+			optional := ""
+			if f.Optional {
+				optional = "?"
+			}
+			fmt.Fprintf(&out, "  %s%s: %s;\n", tsKey(f.Key), optional, tsType(f.Type))
+		}
+		out.WriteString("}\n")
+	}
+	return out.String()
+}
+
+// tsType returns the TypeScript type of t
+func tsType(t *Type) string {
+	var name string
+	switch t.Kind {
+	case Null:
+		return "null"
+	case String:
+		name = "string"
+	case Int, Float:
+		name = "number"
+	case Bool:
+		name = "boolean"
+	case Array:
+		elem := tsType(t.Elem)
+		if strings.Contains(elem, " ") {
+			elem = "(" + elem + ")"
+		}
+		name = elem + "[]"
+	case Object:
+		name = t.Name
+	default:
+		return "unknown"
+	}
+	if t.Nullable {
+		return name + " | null"
+	}
+	return name
+}
+
+var tsIdentifier = regexp.MustCompile(`^[A-Za-z_$][A-Za-z0-9_$]*$`)
+
+// tsKey returns a property name, quoted unless it is an identifier
+func tsKey(key string) string {
+	if tsIdentifier.MatchString(key) {
+		return key
+	}
+	data, _ := json.Marshal(key)
+	return string(data)
+}
+
+func writeJSDoc(out *strings.Builder, indent, text string) {
+	if text == "" {
+		return
+	}
+	text = strings.ReplaceAll(text, "*/", "* /")
+	if !strings.Contains(text, "\n") {
+		fmt.Fprintf(out, "%s/** %s */\n", indent, text)
+		return
+	}
+	fmt.Fprintf(out, "%s/**\n", indent)
+	for _, line := range strings.Split(text, "\n") {
+		fmt.Fprintf(out, "%s * %s\n", indent, line)
+	}
+	fmt.Fprintf(out, "%s */\n", indent)
+}
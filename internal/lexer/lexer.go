@@ -2,6 +2,7 @@ package lexer
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/tomdoesdev/brace/internal/token"
 )
@@ -56,7 +57,10 @@ func (l *Lexer) NextToken() token.Token {
 
 	tok := l.scanToken(startLine, startColumn, startPosition)
 
-	if tok.Type != token.IDENT && tok.Type != token.NUMBER {
+	// Identifiers, keywords, numbers and comments are read up to the character after them
+	switch tok.Type {
+	case token.IDENT, token.NUMBER, token.TRUE, token.FALSE, token.NULL, token.COMMENT:
+	default:
 		l.readChar()
 	}
 	return tok
@@ -91,6 +95,8 @@ func (l *Lexer) scanToken(startLine, startColumn, startPosition int) token.Token
 		return l.createToken(token.HASH, string(l.ch), startLine, startColumn, startPosition, 1)
 	case '.':
 		return l.createToken(token.DOT, string(l.ch), startLine, startColumn, startPosition, 1)
+	case '$':
+		return l.createToken(token.DOLLAR, string(l.ch), startLine, startColumn, startPosition, 1)
 	case '"':
 		return l.handleStringToken(startLine, startColumn, startPosition)
 	case '\'':
@@ -110,7 +116,7 @@ func (l *Lexer) scanToken(startLine, startColumn, startPosition int) token.Token
 func (l *Lexer) handleStringToken(startLine, startColumn, startPosition int) token.Token {
 	if l.peekChar() == '"' && l.peekCharAt(2) == '"' {
 		literal := l.readTripleQuotedString()
-		length := l.position - startPosition
+		length := l.position - startPosition + 1
 		return l.createToken(token.STRING, literal, startLine, startColumn, startPosition, length)
 	}
 	literal := l.readString()
@@ -168,14 +174,29 @@ func (l *Lexer) handleDefaultToken(startLine, startColumn, startPosition int) to
 }
 
 // createToken is a helper to create tokens with position info
+// Tokens left unterminated at the end of the input are cut short there
 func (l *Lexer) createToken(tokenType token.TokenType, literal string, line, column, position, length int) token.Token {
+	if position+length > len(l.input) {
+		length = max(len(l.input)-position, 0)
+	}
+
+	endLine, endColumn := line, column+length
+	if length > 0 {
+		if newlines := strings.Count(l.input[position:position+length], "\n"); newlines > 0 {
+			endLine += newlines
+			endColumn = position + length - strings.LastIndexByte(l.input[:position+length], '\n')
+		}
+	}
+
 	return token.Token{
-		Type:     tokenType,
-		Literal:  literal,
-		Line:     line,
-		Column:   column,
-		Position: position,
-		Length:   length,
+		Type:      tokenType,
+		Literal:   literal,
+		Line:      line,
+		Column:    column,
+		Position:  position,
+		Length:    length,
+		EndLine:   endLine,
+		EndColumn: endColumn,
 	}
 }
 
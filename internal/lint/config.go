@@ -0,0 +1,97 @@
+package lint
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/tomdoesdev/brace/internal/errors"
+)
+
+// ConfigFile is the name of the lint configuration file
+const ConfigFile = ".bracelint"
+
+// Off disables a rule
+const Off errors.Severity = "off"
+
+// Config sets the severity of lint rules
+type Config struct {
+	Severities map[string]errors.Severity // rule name -> severity; rules not listed use their default
+}
+
+// Severity returns the configured severity of a rule
+func (c Config) Severity(rule Rule) errors.Severity {
+	if severity, ok := c.Severities[rule.Name]; ok {
+		return severity
+	}
+	return rule.Severity
+}
+
+// ParseConfig parses a .bracelint file
+// Each line sets a rule's severity as `rule = error|warning|note|off`;
+// blank lines and lines starting with # are ignored
+func ParseConfig(data string) (Config, error) {
+	config := Config{Severities: make(map[string]errors.Severity)}
+
+	scanner := bufio.NewScanner(strings.NewReader(data))
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		name, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return Config{}, fmt.Errorf("line %d: expected RULE = SEVERITY", lineNum)
+		}
+		name, value = strings.TrimSpace(name), strings.TrimSpace(value)
+		if _, ok := Lookup(name); !ok {
+			return Config{}, fmt.Errorf("line %d: unknown rule %s", lineNum, name)
+		}
+
+		severity := errors.Severity(value)
+		switch severity {
+		case errors.SeverityError, errors.SeverityWarning, errors.SeverityNote, Off:
+			config.Severities[name] = severity
+		default:
+			return Config{}, fmt.Errorf("line %d: unknown severity %q (expected error, warning, note or off)", lineNum, value)
+		}
+	}
+	return config, scanner.Err()
+}
+
+// LoadConfig reads a .bracelint file
+func LoadConfig(path string) (Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Config{}, fmt.Errorf("opening lint config: %v", err)
+	}
+	config, err := ParseConfig(string(data))
+	if err != nil {
+		return Config{}, fmt.Errorf("%s: %v", path, err)
+	}
+	return config, nil
+}
+
+// FindConfig returns the nearest .bracelint in dir or its parents
+func FindConfig(dir string) (string, bool) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", false
+	}
+	for {
+		path := filepath.Join(dir, ConfigFile)
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			return path, true
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", false
+		}
+		dir = parent
+	}
+}
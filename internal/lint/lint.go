@@ -0,0 +1,193 @@
+package lint
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/tomdoesdev/brace/internal/ast"
+	"github.com/tomdoesdev/brace/internal/errors"
+	"github.com/tomdoesdev/brace/internal/lexer"
+	"github.com/tomdoesdev/brace/internal/token"
+)
+
+// File is a parsed source file being linted
+type File struct {
+	Name    string
+	Source  string
+	Program *ast.Program
+	Tokens  []token.Token // every token in the source, including comments
+	Library bool          // the file is marked with a // brace:library comment
+}
+
+// Finding is a problem a rule found in a file
+type Finding struct {
+	Message string
+	Start   token.Token
+	End     token.Token
+	Related []errors.RelatedLocation
+	Help    []string
+}
+
+// Rule checks a file for one kind of problem
+type Rule struct {
+	Name        string
+	Description string
+	Severity    errors.Severity // default severity, changed in .bracelint
+	Check       func(file *File) []Finding
+}
+
+// Rules returns every lint rule in order
+func Rules() []Rule {
+	return append([]Rule(nil), rules...)
+}
+
+// Lookup returns the rule with the given name
+func Lookup(name string) (Rule, bool) {
+	for _, rule := range rules {
+		if rule.Name == name {
+			return rule, true
+		}
+	}
+	return Rule{}, false
+}
+
+// Run checks a parsed file with every enabled rule and returns the findings
+// as diagnostics in source order, leaving out those suppressed by a
+// // brace:ignore comment
+func Run(filename, source string, program *ast.Program, config Config) []errors.Diagnostic {
+	file := &File{Name: filename, Source: source, Program: program, Tokens: tokenize(source)}
+	ignored := file.scanComments()
+
+	var diagnostics []errors.Diagnostic
+	for _, rule := range rules {
+		severity := config.Severity(rule)
+		if severity == Off {
+			continue
+		}
+		for _, finding := range rule.Check(file) {
+			if ignored.suppresses(finding.Start.Line, rule.Name) {
+				continue
+			}
+			diagnostics = append(diagnostics, errors.Diagnostic{
+				Severity: severity,
+				Code:     rule.Name,
+				Message:  finding.Message,
+				File:     filename,
+				Range:    errors.TokenRange(finding.Start, finding.End),
+				Related:  finding.Related,
+				Help:     finding.Help,
+			})
+		}
+	}
+
+	sort.SliceStable(diagnostics, func(i, j int) bool {
+		a, b := diagnostics[i].Range.Start, diagnostics[j].Range.Start
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		return a.Column < b.Column
+	})
+	return diagnostics
+}
+
+// tokenize returns every token in source, including comments
+func tokenize(source string) []token.Token {
+	l := lexer.New(source)
+	var tokens []token.Token
+	for {
+		tok := l.NextToken()
+		if tok.Type == token.EOF {
+			return tokens
+		}
+		tokens = append(tokens, tok)
+	}
+}
+
+// ignores maps a line to the rules suppressed on it; an empty list suppresses every rule
+type ignores map[int][]string
+
+func (ig ignores) suppresses(line int, rule string) bool {
+	names, ok := ig[line]
+	if !ok {
+		return false
+	}
+	if len(names) == 0 {
+		return true
+	}
+	for _, name := range names {
+		if name == rule {
+			return true
+		}
+	}
+	return false
+}
+
+// scanComments reads brace:library and brace:ignore comments
+// An ignore comment after code applies to its own line; on a line of its own
+// it applies to the next line
+func (f *File) scanComments() ignores {
+	ignored := make(ignores)
+	lastCodeLine := 0
+	for _, tok := range f.Tokens {
+		if tok.Type != token.COMMENT {
+			lastCodeLine = tok.Line
+			continue
+		}
+		if !strings.HasPrefix(tok.Literal, "//") {
+			continue
+		}
+		text := strings.TrimSpace(strings.TrimPrefix(tok.Literal, "//"))
+		switch {
+		case text == "brace:library":
+			f.Library = true
+		case text == "brace:ignore" || strings.HasPrefix(text, "brace:ignore "):
+			line := tok.Line
+			if lastCodeLine != tok.Line {
+				line++
+			}
+			names := strings.FieldsFunc(strings.TrimPrefix(text, "brace:ignore"), func(r rune) bool {
+				return r == ',' || r == ' ' || r == '\t'
+			})
+			ignored[line] = append(ignored[line], names...)
+		}
+	}
+	return ignored
+}
+
+// index returns the index of the token at a byte offset, or -1
+func (f *File) index(position int) int {
+	i := sort.Search(len(f.Tokens), func(i int) bool { return f.Tokens[i].Position >= position })
+	if i < len(f.Tokens) && f.Tokens[i].Position == position {
+		return i
+	}
+	return -1
+}
+
+// before returns the last non-comment token before tok
+func (f *File) before(tok token.Token) (token.Token, bool) {
+	for i := f.index(tok.Position) - 1; i >= 0; i-- {
+		if f.Tokens[i].Type != token.COMMENT {
+			return f.Tokens[i], true
+		}
+	}
+	return token.Token{}, false
+}
+
+// after returns the first non-comment token after tok
+func (f *File) after(tok token.Token) (token.Token, bool) {
+	i := f.index(tok.Position)
+	if i < 0 {
+		return token.Token{}, false
+	}
+	for i++; i < len(f.Tokens); i++ {
+		if f.Tokens[i].Type != token.COMMENT {
+			return f.Tokens[i], true
+		}
+	}
+	return token.Token{}, false
+}
+
+// related returns a related location at a token in the file
+func (f *File) related(tok token.Token, message string) errors.RelatedLocation {
+	return errors.RelatedLocation{Message: message, File: f.Name, Range: errors.TokenRange(tok, tok)}
+}
@@ -0,0 +1,108 @@
+package lint
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/tomdoesdev/brace/internal/errors"
+	"github.com/tomdoesdev/brace/internal/lexer"
+	"github.com/tomdoesdev/brace/internal/parser"
+)
+
+func lint(t *testing.T, source string, config Config) []errors.Diagnostic {
+	t.Helper()
+	p := parser.New(lexer.New(source), source, "test.brace")
+	program := p.ParseProgram()
+	if errs := p.Errors(); len(errs) > 0 {
+		t.Fatalf("parse failed: %s", errs[0])
+	}
+	return Run("test.brace", source, program, config)
+}
+
+func TestRules(t *testing.T) {
+	tests := []struct {
+		rule   string
+		source string
+		line   int // line of the expected finding, 0 for none
+	}{
+		{"unused-constant", "@const { A = 1, B = 2 }\nx = :A\n", 1},
+		{"unused-constant", "@const { A = 1 }\nx = `${:A}`\n", 0},
+		{"unused-namespace", "@const \"db\" { HOST = \"h\" }\n", 1},
+		{"unused-namespace", "@const \"db\" { HOST = \"h\" }\nx = :db.HOST\n", 0},
+		{"shadowed-key", "#database { host = \"a\" }\n#database { port = 1 }\n", 2},
+		{"shadowed-key", "database = { host = \"a\" }\n#database.host { name = \"b\" }\n", 2},
+		{"shadowed-key", "#a.b { x = 1 }\n#a.c { x = 1 }\n", 0},
+		{"shadowed-key", "x = { a = 1, a = 2 }\n", 1},
+		{"shadowed-key", "@const { A = 1 }\n@const { A = 2 }\nx = :A\n", 2},
+		{"env-without-default", "// brace:library\nx = @env(\"HOME\")\n", 2},
+		{"env-without-default", "x = @env(\"HOME\")\n", 0},
+		{"mixed-separators", "x = { a = 1, b = 2\n c = 3 }\n", 2},
+		{"mixed-separators", "x = { a = 1, b = 2, }\ny = { a = 1 b = 2 }\n", 0},
+		{"mixed-separators", "x = 1;\ny = 2\n", 2},
+		{"heterogeneous-array", "x = [1, \"a\"]\n", 1},
+		{"heterogeneous-array", "x = [1, 2.5, :A]\n@const { A = \"a\" }\n", 0},
+		{"heterogeneous-array", "x = [true, false]\n", 0},
+		{"heterogeneous-array", "x = [null, 1, null]\n", 0},
+	}
+
+	for _, tt := range tests {
+		source := "@brace \"1.0.0\"\n" + tt.source
+		var found []errors.Diagnostic
+		for _, d := range lint(t, source, Config{}) {
+			if d.Code == tt.rule {
+				found = append(found, d)
+			}
+		}
+
+		if tt.line == 0 {
+			if len(found) > 0 {
+				t.Errorf("%s: expected no findings for %q, got %+v", tt.rule, tt.source, found)
+			}
+			continue
+		}
+		// The source is shifted down by the @brace line
+		if len(found) != 1 || found[0].Range.Start.Line != tt.line+1 {
+			t.Errorf("%s: expected one finding on line %d for %q, got %+v", tt.rule, tt.line, tt.source, found)
+		}
+	}
+
+	deprecated := lint(t, "@brace \"0.0.1\"\nx = 1\n", Config{})
+	if len(deprecated) != 1 || deprecated[0].Code != "deprecated-version" || len(deprecated[0].Help) != 1 ||
+		!strings.Contains(deprecated[0].Help[0], "since BRACE 1.0.0 untyped @env values are always strings") {
+		t.Errorf("expected a deprecated-version warning, got %+v", deprecated)
+	}
+}
+
+func TestIgnoreComments(t *testing.T) {
+	source := `@brace "1.0.0"
+@const { A = 1, B = 2 } // brace:ignore unused-constant
+// brace:ignore heterogeneous-array, unused-constant
+x = [1, "a"]
+// brace:ignore
+y = ["b", 2]
+z = [3, "c"] // brace:ignore unused-constant
+`
+
+	diagnostics := lint(t, source, Config{})
+	if len(diagnostics) != 1 || diagnostics[0].Code != "heterogeneous-array" || diagnostics[0].Range.Start.Line != 7 {
+		t.Errorf("expected only the array on line 7 to be reported, got %+v", diagnostics)
+	}
+}
+
+func TestConfig(t *testing.T) {
+	config, err := ParseConfig("# severities\nheterogeneous-array = error\n\nunused-constant = off\n")
+	if err != nil {
+		t.Fatalf("ParseConfig failed: %v", err)
+	}
+
+	diagnostics := lint(t, "@brace \"1.0.0\"\n@const { A = 1 }\nx = [1, \"a\"]\n", config)
+	if len(diagnostics) != 1 || diagnostics[0].Severity != errors.SeverityError {
+		t.Errorf("expected one error-severity finding, got %+v", diagnostics)
+	}
+
+	for _, bad := range []string{"unknown-rule = error", "unused-constant = loud", "unused-constant"} {
+		if _, err := ParseConfig(bad); err == nil {
+			t.Errorf("expected %q to be rejected", bad)
+		}
+	}
+}
@@ -0,0 +1,431 @@
+package lint
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/tomdoesdev/brace/internal/ast"
+	"github.com/tomdoesdev/brace/internal/errors"
+	"github.com/tomdoesdev/brace/internal/token"
+	"github.com/tomdoesdev/brace/internal/version"
+)
+
+// rules lists every lint rule
+var rules = []Rule{
+	{"unused-constant", "a constant is never referenced", errors.SeverityWarning, checkUnusedConstants},
+	{"unused-namespace", "no constant in a namespace is referenced", errors.SeverityWarning, checkUnusedNamespaces},
+	{"shadowed-key", "a key or constant is set again, discarding the earlier value", errors.SeverityWarning, checkShadowedKeys},
+	{"env-without-default", "a library file reads an environment variable without a default", errors.SeverityWarning, checkEnvWithoutDefault},
+	{"mixed-separators", "an object or the file mixes separator styles", errors.SeverityWarning, checkMixedSeparators},
+	{"deprecated-version", "the file uses a deprecated @brace version", errors.SeverityWarning, checkDeprecatedVersion},
+	{"heterogeneous-array", "an array mixes element types", errors.SeverityWarning, checkHeterogeneousArrays},
+}
+
+// constant is a constant defined by @const
+type constant struct {
+	namespace string
+	name      string
+	key       token.Token
+}
+
+// reference returns how the constant is written in a reference
+func (c constant) reference() string {
+	ref := &ast.Reference{Name: c.name}
+	if c.namespace != "global" {
+		ref.Namespace = c.namespace
+	}
+	return ref.String()
+}
+
+// constDirectives returns the @const statements of a program with their namespaces
+func constDirectives(program *ast.Program) ([]*ast.DirectiveStatement, []string) {
+	var directives []*ast.DirectiveStatement
+	var namespaces []string
+	for _, stmt := range program.Statements {
+		directive, ok := stmt.(*ast.DirectiveStatement)
+		if !ok || directive.Name != "const" || directive.Body == nil {
+			continue
+		}
+		namespace := "global"
+		if len(directive.Parameters) > 0 {
+			if str, ok := directive.Parameters[0].(*ast.StringLiteral); ok {
+				namespace = str.Value
+			}
+		}
+		directives = append(directives, directive)
+		namespaces = append(namespaces, namespace)
+	}
+	return directives, namespaces
+}
+
+// constants returns the constants a program defines, in source order
+func constants(program *ast.Program) []constant {
+	var result []constant
+	directives, namespaces := constDirectives(program)
+	for i, directive := range directives {
+		for _, key := range ast.SortedKeys(directive.Body) {
+			if ident, ok := key.(*ast.Identifier); ok {
+				result = append(result, constant{namespace: namespaces[i], name: ident.Value, key: ident.Token})
+			}
+		}
+	}
+	return result
+}
+
+// references returns the namespace.name of every constant a program references
+func references(program *ast.Program) map[string]bool {
+	used := make(map[string]bool)
+	ast.Inspect(program, func(node ast.Node) bool {
+		if ref, ok := node.(*ast.Reference); ok && ref.Path == nil {
+			namespace := ref.Namespace
+			if namespace == "" {
+				namespace = "global"
+			}
+			used[namespace+"."+ref.Name] = true
+			used[namespace+"."] = true
+		}
+		return true
+	})
+	return used
+}
+
+func checkUnusedConstants(file *File) []Finding {
+	used := references(file.Program)
+
+	var findings []Finding
+	for _, c := range constants(file.Program) {
+		// Constants of an unused namespace are reported by unused-namespace
+		if used[c.namespace+"."+c.name] || (c.namespace != "global" && !used[c.namespace+"."]) {
+			continue
+		}
+		findings = append(findings, Finding{
+			Message: fmt.Sprintf("constant `%s` is never used", c.reference()),
+			Start:   c.key,
+			End:     c.key,
+		})
+	}
+	return findings
+}
+
+func checkUnusedNamespaces(file *File) []Finding {
+	used := references(file.Program)
+	reported := make(map[string]bool)
+
+	var findings []Finding
+	directives, namespaces := constDirectives(file.Program)
+	for i, directive := range directives {
+		namespace := namespaces[i]
+		if namespace == "global" || used[namespace+"."] || reported[namespace] {
+			continue
+		}
+		reported[namespace] = true
+		tok := ast.ExpressionToken(directive.Parameters[0])
+		findings = append(findings, Finding{
+			Message: fmt.Sprintf("namespace `%s` is never used", namespace),
+			Start:   tok,
+			End:     tok,
+		})
+	}
+	return findings
+}
+
+// write is a key path set by a statement
+type write struct {
+	path []string
+	key  token.Token
+}
+
+// hasPrefix reports whether path starts with prefix
+func hasPrefix(path, prefix []string) bool {
+	if len(prefix) > len(path) {
+		return false
+	}
+	for i := range prefix {
+		if path[i] != prefix[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// objectWrites appends the key paths an object literal sets below path
+func objectWrites(writes []write, path []string, value ast.Expression) []write {
+	obj, ok := value.(*ast.ObjectLiteral)
+	if !ok {
+		return writes
+	}
+	for _, key := range ast.SortedKeys(obj) {
+		ident, ok := key.(*ast.Identifier)
+		if !ok {
+			continue
+		}
+		child := append(append([]string(nil), path...), ident.Value)
+		writes = append(writes, write{path: child, key: ident.Token})
+		writes = objectWrites(writes, child, obj.Pairs[key])
+	}
+	return writes
+}
+
+func checkShadowedKeys(file *File) []Finding {
+	var findings []Finding
+
+	// A later statement replaces everything an earlier one set at or below its path
+	var written []write
+	for _, stmt := range file.Program.Statements {
+		var path []string
+		var key token.Token
+		var value ast.Expression
+		switch s := stmt.(type) {
+		case *ast.AssignmentStatement:
+			path, key, value = []string{s.Name.Value}, s.Name.Token, s.Value
+		case *ast.TableStatement:
+			path, key, value = s.Path, s.Token, s.Body
+		default:
+			continue
+		}
+
+		for _, earlier := range written {
+			if hasPrefix(earlier.path, path) {
+				findings = append(findings, Finding{
+					Message: fmt.Sprintf("`%s` is set again here, discarding the value set on line %d", strings.Join(path, "."), earlier.key.Line),
+					Start:   key,
+					End:     key,
+					Related: []errors.RelatedLocation{file.related(earlier.key, "earlier value set here")},
+				})
+				break
+			}
+		}
+		written = append(written, write{path: path, key: key})
+		written = objectWrites(written, path, value)
+	}
+
+	// Duplicate keys in one object literal
+	ast.Inspect(file.Program, func(node ast.Node) bool {
+		obj, ok := node.(*ast.ObjectLiteral)
+		if !ok {
+			return true
+		}
+		seen := make(map[string]token.Token)
+		for _, key := range ast.SortedKeys(obj) {
+			ident, ok := key.(*ast.Identifier)
+			if !ok {
+				continue
+			}
+			if first, ok := seen[ident.Value]; ok {
+				findings = append(findings, Finding{
+					Message: fmt.Sprintf("key `%s` is set twice in the same object", ident.Value),
+					Start:   ident.Token,
+					End:     ident.Token,
+					Related: []errors.RelatedLocation{file.related(first, "first set here")},
+				})
+				continue
+			}
+			seen[ident.Value] = ident.Token
+		}
+		return true
+	})
+
+	// Constants redefined by a later @const; duplicates within one body are
+	// already reported as duplicate keys
+	defined := make(map[string]constant)
+	for _, c := range constants(file.Program) {
+		id := c.namespace + "." + c.name
+		if first, ok := defined[id]; ok {
+			if file.sameObject(first.key, c.key) {
+				continue
+			}
+			findings = append(findings, Finding{
+				Message: fmt.Sprintf("constant `%s` is redefined, discarding the value set on line %d", c.reference(), first.key.Line),
+				Start:   c.key,
+				End:     c.key,
+				Related: []errors.RelatedLocation{file.related(first.key, "first defined here")},
+			})
+			continue
+		}
+		defined[id] = c
+	}
+
+	return findings
+}
+
+// sameObject reports whether two keys belong to the same @const body
+func (f *File) sameObject(a, b token.Token) bool {
+	directives, _ := constDirectives(f.Program)
+	for _, directive := range directives {
+		start, end := directive.Body.Token.Position, directive.Body.End.Position
+		if a.Position > start && a.Position < end {
+			return b.Position > start && b.Position < end
+		}
+	}
+	return false
+}
+
+func checkEnvWithoutDefault(file *File) []Finding {
+	if !file.Library {
+		return nil
+	}
+
+	var findings []Finding
+	ast.Inspect(file.Program, func(node ast.Node) bool {
+		env, ok := node.(*ast.EnvDirective)
+		if !ok || env.DefaultValue != nil {
+			return true
+		}
+		findings = append(findings, Finding{
+			Message: fmt.Sprintf("%s has no default in a library file", env),
+			Start:   env.Token,
+			End:     env.End,
+			Help:    []string{"give the lookup a default so the file compiles wherever it is used"},
+		})
+		return true
+	})
+	return findings
+}
+
+func checkMixedSeparators(file *File) []Finding {
+	var findings []Finding
+
+	// Top-level assignments either all end with a semicolon or none do
+	var first *bool
+	for _, stmt := range file.Program.Statements {
+		assignment, ok := stmt.(*ast.AssignmentStatement)
+		if !ok || assignment.Value == nil {
+			continue
+		}
+		next, ok := file.after(ast.ExpressionEnd(assignment.Value))
+		semicolon := ok && next.Type == token.SEMICOLON
+		if first == nil {
+			first = &semicolon
+			continue
+		}
+		if semicolon != *first {
+			findings = append(findings, Finding{
+				Message: "some statements end with a semicolon and others do not",
+				Start:   assignment.Name.Token,
+				End:     assignment.Name.Token,
+			})
+			break
+		}
+	}
+
+	// Object entries are either all separated by commas or none are
+	ast.Inspect(file.Program, func(node ast.Node) bool {
+		obj, ok := node.(*ast.ObjectLiteral)
+		if !ok {
+			return true
+		}
+		var first *bool
+		for i, key := range ast.SortedKeys(obj) {
+			if i == 0 {
+				continue
+			}
+			keyToken := ast.ExpressionToken(key)
+			previous, ok := file.before(keyToken)
+			comma := ok && previous.Type == token.COMMA
+			if first == nil {
+				first = &comma
+				continue
+			}
+			if comma != *first {
+				findings = append(findings, Finding{
+					Message: "object separates some entries with commas and others without",
+					Start:   keyToken,
+					End:     keyToken,
+				})
+				break
+			}
+		}
+		return true
+	})
+
+	return findings
+}
+
+func checkDeprecatedVersion(file *File) []Finding {
+	if len(file.Program.Statements) == 0 {
+		return nil
+	}
+	directive, ok := file.Program.Statements[0].(*ast.DirectiveStatement)
+	if !ok || directive.Name != "brace" || len(directive.Parameters) != 1 {
+		return nil
+	}
+	literal, ok := directive.Parameters[0].(*ast.StringLiteral)
+	if !ok {
+		return nil
+	}
+	v, err := version.Parse(literal.Value)
+	if err != nil || !version.IsDeprecated(v) {
+		return nil
+	}
+	return []Finding{{
+		Message: fmt.Sprintf("BRACE version %s is deprecated", literal.Value),
+		Start:   literal.Token,
+		End:     literal.Token,
+		Help:    []string{upgradeAdvice(v)},
+	}}
+}
+
+// upgradeAdvice explains how to upgrade from v, naming the features removed since
+func upgradeAdvice(v version.Version) string {
+	advice := "run `brace migrate -w` to upgrade"
+	for _, f := range version.Features() {
+		if f.Available(v) && !f.Available(version.Latest()) && f.Migration != "" {
+			advice += fmt.Sprintf("; since BRACE %s %s", f.Until, f.Migration)
+		}
+	}
+	return advice
+}
+
+// literalKind names the type of a literal, or "" for null, which fits any
+// array, and for values only known after analysis
+func literalKind(expr ast.Expression) string {
+	switch expr.(type) {
+	case *ast.StringLiteral, *ast.TemplateStringLiteral:
+		return "string"
+	case *ast.NumberLiteral:
+		return "number"
+	case *ast.BooleanLiteral:
+		return "boolean"
+	case *ast.ObjectLiteral:
+		return "object"
+	case *ast.ArrayLiteral:
+		return "array"
+	default:
+		return ""
+	}
+}
+
+func checkHeterogeneousArrays(file *File) []Finding {
+	var findings []Finding
+	ast.Inspect(file.Program, func(node ast.Node) bool {
+		arr, ok := node.(*ast.ArrayLiteral)
+		if !ok {
+			return true
+		}
+		var firstKind string
+		var firstElement ast.Expression
+		for _, element := range arr.Elements {
+			kind := literalKind(element)
+			if kind == "" {
+				continue
+			}
+			if firstKind == "" {
+				firstKind, firstElement = kind, element
+				continue
+			}
+			if kind != firstKind {
+				elementToken := ast.ExpressionToken(element)
+				findings = append(findings, Finding{
+					Message: fmt.Sprintf("array mixes %s and %s elements", firstKind, kind),
+					Start:   elementToken,
+					End:     ast.ExpressionEnd(element),
+					Related: []errors.RelatedLocation{file.related(ast.ExpressionToken(firstElement), "first "+firstKind+" element here")},
+					Help:    []string{"the specification requires arrays to be homogeneous"},
+				})
+				break
+			}
+		}
+		return true
+	})
+	return findings
+}
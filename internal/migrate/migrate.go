@@ -0,0 +1,208 @@
+package migrate
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/tomdoesdev/brace/internal/lexer"
+	"github.com/tomdoesdev/brace/internal/token"
+	"github.com/tomdoesdev/brace/internal/version"
+)
+
+// Note is a change made while migrating, or code to review by hand
+type Note struct {
+	Line    int
+	Column  int
+	Message string
+}
+
+// Result is a file migrated to the newest version
+type Result struct {
+	Source   string          // the migrated source
+	From     version.Version // version the file declared
+	To       version.Version // version the file declares now
+	Changes  []Note          // rewrites that were made
+	Warnings []Note          // code whose meaning changed but could not be rewritten safely
+}
+
+// Changed reports whether the file was upgraded
+func (r *Result) Changed() bool {
+	return r.From != r.To
+}
+
+// step upgrades a file from the version before it to a newer version
+// Rewrites only change the syntax that changed meaning in the newer version,
+// leaving comments and formatting alone
+type step struct {
+	to      version.Version
+	rewrite func(m *migration)
+}
+
+// steps are applied in order to files declaring a version older than their target
+// Versions that only add features need no rewrite
+var steps = []step{
+	{to: version.V1_0_0, rewrite: typeUntypedEnv},
+	{to: version.V1_1_0},
+}
+
+// migration collects the edits and notes of one step
+type migration struct {
+	source string
+	tokens []token.Token // tokens without comments
+	edits  []edit
+	result *Result
+}
+
+// edit replaces source[start:end] with text
+type edit struct {
+	start, end int
+	text       string
+}
+
+// Migrate upgrades a BRACE file to the newest version
+func Migrate(source string) (*Result, error) {
+	versionToken, err := findVersion(tokenize(source))
+	if err != nil {
+		return nil, err
+	}
+	from, err := version.Parse(versionToken.Literal)
+	if err != nil || !version.IsSupported(from) {
+		return nil, fmt.Errorf("%d:%d: unsupported BRACE version: %s (supported versions: %v)",
+			versionToken.Line, versionToken.Column, versionToken.Literal, version.Supported())
+	}
+
+	result := &Result{Source: source, From: from, To: from}
+	for _, s := range steps {
+		if result.To.Compare(s.to) >= 0 {
+			continue
+		}
+		if s.rewrite != nil {
+			m := &migration{source: result.Source, tokens: tokenize(result.Source), result: result}
+			s.rewrite(m)
+			result.Source = m.apply()
+		}
+		result.To = s.to
+	}
+	if !result.Changed() {
+		return result, nil
+	}
+
+	// Rewrites never touch the header, so its token is where it was
+	quoted := fmt.Sprintf("%q", result.To.String())
+	result.Source = result.Source[:versionToken.Position] + quoted + result.Source[versionToken.Position+versionToken.Length:]
+	result.Changes = append([]Note{{
+		Line:    versionToken.Line,
+		Column:  versionToken.Column,
+		Message: fmt.Sprintf("upgraded @brace %q to %s", from.String(), quoted),
+	}}, result.Changes...)
+	return result, nil
+}
+
+// findVersion returns the version string of the @brace directive that starts the file
+func findVersion(tokens []token.Token) (token.Token, error) {
+	if len(tokens) < 3 || tokens[0].Type != token.AT || tokens[1].Type != token.IDENT || tokens[1].Literal != "brace" {
+		return token.Token{}, fmt.Errorf("file does not start with a @brace directive")
+	}
+	if tokens[2].Type != token.STRING {
+		return token.Token{}, fmt.Errorf("%d:%d: @brace version must be a string literal", tokens[2].Line, tokens[2].Column)
+	}
+	return tokens[2], nil
+}
+
+// tokenize returns the tokens of source without comments
+func tokenize(source string) []token.Token {
+	l := lexer.New(source)
+	var tokens []token.Token
+	for {
+		tok := l.NextToken()
+		switch tok.Type {
+		case token.EOF:
+			return tokens
+		case token.COMMENT:
+			continue
+		}
+		tokens = append(tokens, tok)
+	}
+}
+
+// token returns the token at index i, or an EOF token past the end
+func (m *migration) token(i int) token.Token {
+	if i < len(m.tokens) {
+		return m.tokens[i]
+	}
+	return token.Token{Type: token.EOF}
+}
+
+// insert adds text after tok
+func (m *migration) insert(tok token.Token, text string) {
+	end := tok.Position + tok.Length
+	m.edits = append(m.edits, edit{start: end, end: end, text: text})
+}
+
+func (m *migration) change(tok token.Token, format string, args ...interface{}) {
+	m.result.Changes = append(m.result.Changes, Note{Line: tok.Line, Column: tok.Column, Message: fmt.Sprintf(format, args...)})
+}
+
+func (m *migration) warn(tok token.Token, format string, args ...interface{}) {
+	m.result.Warnings = append(m.result.Warnings, Note{Line: tok.Line, Column: tok.Column, Message: fmt.Sprintf(format, args...)})
+}
+
+// apply returns the source with the migration's edits made
+func (m *migration) apply() string {
+	sort.SliceStable(m.edits, func(i, j int) bool { return m.edits[i].start < m.edits[j].start })
+
+	var out strings.Builder
+	last := 0
+	for _, e := range m.edits {
+		out.WriteString(m.source[last:e.start])
+		out.WriteString(e.text)
+		last = e.end
+	}
+	out.WriteString(m.source[last:])
+	return out.String()
+}
+
+// typeUntypedEnv upgrades to 1.0.0, where untyped @env values are always
+// strings instead of being converted when they look like booleans or numbers
+// A lookup whose default is a number or boolean literal becomes the typed
+// lookup of that type; others are reported for review, unless their default
+// is a string and so already expects one
+func typeUntypedEnv(m *migration) {
+	for i := range m.tokens {
+		at, name, lparen := m.token(i), m.token(i+1), m.token(i+2)
+		if at.Type != token.AT || name.Type != token.IDENT || name.Literal != "env" || lparen.Type != token.LPAREN {
+			continue
+		}
+		variable := m.token(i + 3)
+		if variable.Type != token.STRING {
+			continue
+		}
+
+		var defaultValue token.Token
+		if m.token(i+4).Type == token.COMMA && m.token(i+6).Type == token.RPAREN {
+			defaultValue = m.token(i + 5)
+		}
+
+		var typ string
+		switch defaultValue.Type {
+		case token.STRING, token.TEMPLATE_STRING:
+			continue
+		case token.NUMBER:
+			typ = "int"
+			if strings.Contains(defaultValue.Literal, ".") {
+				typ = "float"
+			}
+		case token.TRUE, token.FALSE:
+			typ = "bool"
+		}
+
+		if typ == "" {
+			m.warn(at, "@env(%q) always produces a string from BRACE 1.0.0; use @env.int, @env.float or @env.bool if %s holds a number or boolean",
+				variable.Literal, variable.Literal)
+			continue
+		}
+		m.insert(name, "."+typ)
+		m.change(at, "@env(%q) became @env.%s(%q, ...) to keep converting its value", variable.Literal, typ, variable.Literal)
+	}
+}
@@ -0,0 +1,54 @@
+package migrate
+
+import (
+	"testing"
+
+	"github.com/tomdoesdev/brace/internal/version"
+)
+
+func TestMigrate(t *testing.T) {
+	source := `@brace "0.0.1" // legacy
+port = @env("PORT", 8080)
+ratio = @env( "RATIO" , 0.5 )
+debug = @env("DEBUG", false)
+name = @env("NAME", "app")
+user = @env("USER")
+typed = @env.int("TYPED", 1)
+`
+	expected := `@brace "1.1.0" // legacy
+port = @env.int("PORT", 8080)
+ratio = @env.float( "RATIO" , 0.5 )
+debug = @env.bool("DEBUG", false)
+name = @env("NAME", "app")
+user = @env("USER")
+typed = @env.int("TYPED", 1)
+`
+
+	result, err := Migrate(source)
+	if err != nil {
+		t.Fatalf("Migrate failed: %v", err)
+	}
+	if result.Source != expected {
+		t.Errorf("expected\n%s\ngot\n%s", expected, result.Source)
+	}
+	if result.From != version.V0_0_1 || result.To != version.V1_1_0 || !result.Changed() {
+		t.Errorf("expected a migration from 0.0.1 to 1.1.0, got %s to %s", result.From, result.To)
+	}
+	if len(result.Changes) != 4 || result.Changes[0].Line != 1 || result.Changes[1].Line != 2 {
+		t.Errorf("expected the header and three lookups to change, got %+v", result.Changes)
+	}
+	if len(result.Warnings) != 1 || result.Warnings[0].Line != 6 {
+		t.Errorf("expected a warning for the lookup without a default, got %+v", result.Warnings)
+	}
+
+	again, err := Migrate(result.Source)
+	if err != nil || again.Changed() || again.Source != result.Source {
+		t.Errorf("expected a current file to be left alone, got %+v, %v", again, err)
+	}
+
+	for _, bad := range []string{"x = 1\n", "@brace \"9.0.0\"\n", "@brace 1\n"} {
+		if _, err := Migrate(bad); err == nil {
+			t.Errorf("expected %q to be rejected", bad)
+		}
+	}
+}
@@ -6,9 +6,12 @@ import (
 	"strings"
 
 	"github.com/tomdoesdev/brace/internal/ast"
+	"github.com/tomdoesdev/brace/internal/cst"
+	"github.com/tomdoesdev/brace/internal/directive"
 	"github.com/tomdoesdev/brace/internal/errors"
 	"github.com/tomdoesdev/brace/internal/lexer"
 	"github.com/tomdoesdev/brace/internal/token"
+	"github.com/tomdoesdev/brace/internal/version"
 )
 
 // Parser implements a recursive descent parser with enhanced error reporting
@@ -20,6 +23,14 @@ type Parser struct {
 
 	errorReporter *errors.ErrorReporter
 	errors        []errors.CompilerError
+	filename      string
+
+	directives *directive.Registry // custom directives accepted by the parser
+
+	version      version.Version // version declared with @brace; zero until it is parsed or if it is unsupported
+	versionToken token.Token
+
+	syntax *cst.Builder // lossless tree of the tokens read, including comments
 }
 
 // New creates a new parser instance
@@ -28,6 +39,8 @@ func New(l *lexer.Lexer, source, filename string) *Parser {
 		l:             l,
 		errorReporter: errors.NewErrorReporter(source, filename),
 		errors:        []errors.CompilerError{},
+		filename:      filename,
+		syntax:        cst.NewBuilder(source),
 	}
 
 	// Read two tokens, so curToken and peekToken are both set
@@ -37,10 +50,38 @@ func New(l *lexer.Lexer, source, filename string) *Parser {
 	return p
 }
 
+// SetDirectives sets the registry of custom directives the parser accepts
+func (p *Parser) SetDirectives(directives *directive.Registry) {
+	p.directives = directives
+}
+
 // nextToken advances both curToken and peekToken
 func (p *Parser) nextToken() {
 	p.curToken = p.peekToken
 	p.peekToken = p.l.NextToken()
+	p.syntax.Add(p.peekToken)
+}
+
+// SyntaxTree returns the concrete syntax tree of the source, which keeps
+// every token, comment and space so the source can be reproduced exactly
+// Call it after ParseProgram; tokens left unparsed after an error are placed
+// in the file node
+func (p *Parser) SyntaxTree() *cst.Node {
+	for p.peekToken.Type != token.EOF {
+		p.nextToken()
+	}
+	return p.syntax.Tree()
+}
+
+// startNode opens a syntax tree node at the current token
+func (p *Parser) startNode(kind cst.Kind) *cst.Node {
+	return p.syntax.Start(kind, p.curToken)
+}
+
+// finishNode closes a syntax tree node at the current token
+// Parse functions defer it, as they return on the last token they parsed
+func (p *Parser) finishNode(node *cst.Node) {
+	p.syntax.Finish(node, p.curToken)
 }
 
 // ParseProgram parses the entire BRACE file and returns the AST
@@ -55,34 +96,42 @@ func (p *Parser) ParseProgram() *ast.Program {
 
 	// Check if file is empty
 	if p.curToken.Type == token.EOF {
-		p.addBraceDirectiveError("empty BRACE file - must start with @brace directive")
+		p.addBraceDirectiveError(errors.CodeMissingBrace, "empty BRACE file - must start with @brace directive")
 		return program
 	}
 
 	// First non-comment statement MUST be @brace directive
 	if p.curToken.Type != token.AT {
-		p.addBraceDirectiveError("BRACE file must start with @brace directive")
+		p.addBraceDirectiveError(errors.CodeMissingBrace, "BRACE file must start with @brace directive")
 		return program
 	}
 
 	// Parse the first statement and verify it's @brace
 	firstStmt := p.parseStatement()
+	if firstStmt == nil {
+		return program
+	}
 	if directive, ok := firstStmt.(*ast.DirectiveStatement); ok {
 		if directive.Name != "brace" {
-			p.addError(fmt.Sprintf("first directive must be @brace, got @%s", directive.Name))
+			p.addError(errors.CodeMissingBrace, fmt.Sprintf("first directive must be @brace, got @%s", directive.Name))
 			return program
 		}
 		// Validate @brace directive has exactly one string parameter (version)
 		if len(directive.Parameters) != 1 {
-			p.addError("@brace directive requires exactly one version parameter")
+			p.addError(errors.CodeMalformedBrace, "@brace directive requires exactly one version parameter")
 			return program
 		}
-		if _, ok := directive.Parameters[0].(*ast.StringLiteral); !ok {
-			p.addError("@brace version must be a string literal")
+		literal, ok := directive.Parameters[0].(*ast.StringLiteral)
+		if !ok {
+			p.addError(errors.CodeMalformedBrace, "@brace version must be a string literal")
 			return program
 		}
+		// The analyzer reports unsupported versions; features are not checked for them
+		if v, err := version.Parse(literal.Value); err == nil && version.IsSupported(v) {
+			p.version, p.versionToken = v, literal.Token
+		}
 	} else {
-		p.addError("first statement must be @brace directive")
+		p.addError(errors.CodeMissingBrace, "first statement must be @brace directive")
 		return program
 	}
 
@@ -109,21 +158,37 @@ func (p *Parser) ParseProgram() *ast.Program {
 
 // parseStatement determines what type of statement we're parsing
 func (p *Parser) parseStatement() ast.Statement {
+	// Each branch checks for nil explicitly so a failed parse never yields
+	// a non-nil Statement interface holding a nil pointer
 	switch p.curToken.Type {
 	case token.AT:
-		return p.parseDirectiveStatement()
+		if stmt := p.parseDirectiveStatement(); stmt != nil {
+			stmt.End = p.curToken
+			return stmt
+		}
+		return nil
 	case token.HASH:
-		return p.parseTableStatement()
+		if stmt := p.parseTableStatement(); stmt != nil {
+			return stmt
+		}
+		return nil
 	case token.IDENT:
-		return p.parseAssignmentStatement()
+		if stmt := p.parseAssignmentStatement(); stmt != nil {
+			return stmt
+		}
+		return nil
+	case token.ILLEGAL:
+		p.addError(errors.CodeIllegalCharacter, fmt.Sprintf("illegal token: %s", p.curToken.Literal))
+		return nil
 	default:
-		p.addError(fmt.Sprintf("unexpected token: %s", p.curToken.Type))
+		p.addError(errors.CodeUnexpectedToken, fmt.Sprintf("unexpected token: %s", p.curToken.Type))
 		return nil
 	}
 }
 
 // parseDirectiveStatement parses @directive statements (like @const, @brace)
 func (p *Parser) parseDirectiveStatement() *ast.DirectiveStatement {
+	defer p.finishNode(p.startNode(cst.Directive))
 	stmt := &ast.DirectiveStatement{Token: p.curToken}
 
 	if !p.expectPeek(token.IDENT) {
@@ -137,16 +202,66 @@ func (p *Parser) parseDirectiveStatement() *ast.DirectiveStatement {
 	case "const":
 		return p.parseConstDirective(stmt)
 	case "env":
-		p.addError("@env directive cannot be used as a statement, only as an expression")
+		p.addError(errors.CodeInvalidEnvDirective, "@env directive cannot be used as a statement, only as an expression")
 		return nil
 	case "brace":
 		return p.parseBraceDirective(stmt)
+	case "env_policy":
+		p.requireFeature(version.EnvPolicy, p.curToken)
+		return p.parseEnvPolicyDirective(stmt)
 	default:
-		p.addError(fmt.Sprintf("unknown directive: %s", stmt.Name))
+		if p.directives.Has(stmt.Name) {
+			return p.parseCustomDirective(stmt)
+		}
+		p.addError(errors.CodeUnknownDirective, fmt.Sprintf("unknown directive: %s", stmt.Name))
+		p.suggestDirective(stmt.Name, true)
 		return nil
 	}
 }
 
+// parseCustomDirective parses registered directive statements: @name(args) { body }
+func (p *Parser) parseCustomDirective(stmt *ast.DirectiveStatement) *ast.DirectiveStatement {
+	args, body, ok := p.parseDirectiveArguments()
+	if !ok {
+		return nil
+	}
+	stmt.Parameters = args
+	stmt.Body = body
+	return stmt
+}
+
+// parseDirectiveArguments parses the optional (args) and { body } of a custom directive
+func (p *Parser) parseDirectiveArguments() ([]ast.Expression, *ast.ObjectLiteral, bool) {
+	var args []ast.Expression
+	var body *ast.ObjectLiteral
+
+	if p.peekToken.Type == token.LPAREN {
+		p.nextToken()
+		args = p.parseExpressionList(token.RPAREN)
+		if args == nil {
+			return nil, nil, false
+		}
+		for _, arg := range args {
+			if arg == nil {
+				return nil, nil, false
+			}
+		}
+	}
+
+	if p.peekToken.Type == token.LBRACE {
+		p.nextToken()
+		objLiteral := p.parseObjectLiteral()
+		obj, ok := objLiteral.(*ast.ObjectLiteral)
+		if !ok || obj == nil {
+			p.addError(errors.CodeUnexpectedToken, "failed to parse directive body")
+			return nil, nil, false
+		}
+		body = obj
+	}
+
+	return args, body, true
+}
+
 // parseConstDirective parses @const directive statements
 func (p *Parser) parseConstDirective(stmt *ast.DirectiveStatement) *ast.DirectiveStatement {
 	// @const can have optional namespace: @const "namespace" { ... }
@@ -154,41 +269,74 @@ func (p *Parser) parseConstDirective(stmt *ast.DirectiveStatement) *ast.Directiv
 		p.nextToken()
 		param := p.parseExpression()
 		if param == nil {
-			p.addError("failed to parse @const namespace")
+			p.addError(errors.CodeUnexpectedToken, "failed to parse @const namespace")
 			return nil
 		}
 		stmt.Parameters = append(stmt.Parameters, param)
 	}
 
-	if !p.expectPeek(token.LBRACE) {
+	if p.peekToken.Type != token.LBRACE {
+		p.addErrorAtToken(errors.CodeExpectedObject, "@const body must be an object", p.peekToken)
 		return nil
 	}
+	p.nextToken()
 
 	objLiteral := p.parseObjectLiteral()
 	if objLiteral == nil {
-		p.addError("failed to parse @const body")
+		p.addError(errors.CodeUnexpectedToken, "failed to parse @const body")
 		return nil
 	}
 
 	if obj, ok := objLiteral.(*ast.ObjectLiteral); ok {
 		stmt.Body = obj
 	} else {
-		p.addError("@const body must be an object")
+		p.addError(errors.CodeExpectedObject, "@const body must be an object")
 		return nil
 	}
 
 	return stmt
 }
 
+// parseEnvPolicyDirective parses @env_policy { allow = [...], deny = [...] } statements
+func (p *Parser) parseEnvPolicyDirective(stmt *ast.DirectiveStatement) *ast.DirectiveStatement {
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	objLiteral := p.parseObjectLiteral()
+	obj, ok := objLiteral.(*ast.ObjectLiteral)
+	if !ok || obj == nil {
+		p.addError(errors.CodeUnexpectedToken, "failed to parse @env_policy body")
+		return nil
+	}
+	stmt.Body = obj
+
+	return stmt
+}
+
 // parseBraceDirective parses @brace directive statements
 func (p *Parser) parseBraceDirective(stmt *ast.DirectiveStatement) *ast.DirectiveStatement {
 	// @brace "version"
-	if !p.expectPeek(token.STRING) {
+	switch p.peekToken.Type {
+	case token.STRING:
+		p.nextToken()
+	case token.NUMBER, token.TRUE, token.FALSE, token.NULL, token.TEMPLATE_STRING, token.IDENT:
+		// A value on the same line is a version of the wrong type; anything
+		// further down is the next statement
+		if p.peekToken.Line == p.curToken.Line {
+			p.nextToken()
+			p.addError(errors.CodeMalformedBrace, "@brace version must be a string literal")
+			return nil
+		}
+		p.addError(errors.CodeMalformedBrace, "@brace directive requires exactly one version parameter")
+		return nil
+	default:
+		p.addError(errors.CodeMalformedBrace, "@brace directive requires exactly one version parameter")
 		return nil
 	}
 	param := p.parseExpression()
 	if param == nil {
-		p.addError("failed to parse @brace version")
+		p.addError(errors.CodeMalformedBrace, "failed to parse @brace version")
 		return nil
 	}
 	stmt.Parameters = append(stmt.Parameters, param)
@@ -198,6 +346,7 @@ func (p *Parser) parseBraceDirective(stmt *ast.DirectiveStatement) *ast.Directiv
 
 // parseAssignmentStatement parses key = value assignments
 func (p *Parser) parseAssignmentStatement() *ast.AssignmentStatement {
+	defer p.finishNode(p.startNode(cst.Assignment))
 	stmt := &ast.AssignmentStatement{Token: p.curToken}
 
 	stmt.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
@@ -217,8 +366,23 @@ func (p *Parser) parseAssignmentStatement() *ast.AssignmentStatement {
 	return stmt
 }
 
+// literalTokens are the tokens that make up a literal expression on their own
+var literalTokens = map[token.TokenType]bool{
+	token.IDENT:           true,
+	token.STRING:          true,
+	token.NUMBER:          true,
+	token.TRUE:            true,
+	token.FALSE:           true,
+	token.NULL:            true,
+	token.TEMPLATE_STRING: true,
+}
+
 // parseExpression parses expressions (values)
 func (p *Parser) parseExpression() ast.Expression {
+	if literalTokens[p.curToken.Type] {
+		defer p.finishNode(p.startNode(cst.Literal))
+	}
+
 	switch p.curToken.Type {
 	case token.IDENT:
 		return p.parseIdentifier()
@@ -240,15 +404,15 @@ func (p *Parser) parseExpression() ast.Expression {
 		return p.parseDirectiveExpression()
 	case token.COMMENT:
 		// Comments should be skipped at a higher level, but if we encounter one here, skip it
-		p.addError("unexpected comment in expression context")
+		p.addError(errors.CodeUnexpectedToken, "unexpected comment in expression context")
 		return nil
 	case token.ILLEGAL:
-		p.addError(fmt.Sprintf("illegal token: %s", p.curToken.Literal))
+		p.addError(errors.CodeIllegalCharacter, fmt.Sprintf("illegal token: %s", p.curToken.Literal))
 		return nil
 	case token.TEMPLATE_STRING:
 		return p.parseTemplateStringLiteral()
 	default:
-		p.addError(fmt.Sprintf("no parse function for %s found", p.curToken.Type))
+		p.addError(errors.CodeUnexpectedToken, fmt.Sprintf("no parse function for %s found", p.curToken.Type))
 		return nil
 	}
 }
@@ -256,44 +420,110 @@ func (p *Parser) parseExpression() ast.Expression {
 // parseDirectiveExpression parses @directive expressions (like @env used in values)
 func (p *Parser) parseDirectiveExpression() ast.Expression {
 	atToken := p.curToken // save the @ token
+	node := p.startNode(cst.DirectiveCall)
+	defer p.finishNode(node)
 
 	if !p.expectPeek(token.IDENT) {
 		return nil
 	}
 
 	if p.curToken.Literal == "env" {
-		env := &ast.EnvDirective{Token: atToken}
+		node.Kind = cst.Env
+		return p.parseEnvDirective(atToken)
+	}
 
-		if !p.expectPeek(token.LPAREN) {
+	if p.directives.Has(p.curToken.Literal) {
+		expr := &ast.DirectiveExpression{Token: atToken, Name: p.curToken.Literal}
+		args, body, ok := p.parseDirectiveArguments()
+		if !ok {
 			return nil
 		}
+		expr.Arguments = args
+		expr.Body = body
+		expr.End = p.curToken
+		return expr
+	}
 
-		if !p.expectPeek(token.STRING) {
+	p.addError(errors.CodeUnknownDirective, fmt.Sprintf("unknown directive in expression context: %s", p.curToken.Literal))
+	p.suggestDirective(p.curToken.Literal, false)
+	return nil
+}
+
+// envTypes are the conversions available as @env.<type>(...)
+var envTypes = map[string]bool{
+	"string": true,
+	"int":    true,
+	"float":  true,
+	"bool":   true,
+	"json":   true,
+	"list":   true,
+}
+
+// parseEnvDirective parses @env("VAR", default) and typed forms like @env.int("PORT", 8080)
+// and @env.list("HOSTS", ",", default)
+func (p *Parser) parseEnvDirective(atToken token.Token) ast.Expression {
+	env := &ast.EnvDirective{Token: atToken}
+
+	if p.peekToken.Type == token.DOT {
+		p.nextToken() // consume dot
+		if !p.expectPeek(token.IDENT) {
+			return nil
+		}
+		if !envTypes[p.curToken.Literal] {
+			p.addError(errors.CodeInvalidEnvDirective, fmt.Sprintf("unknown @env type: %s (supported types: bool, float, int, json, list, string)", p.curToken.Literal))
 			return nil
 		}
+		env.Type = p.curToken.Literal
+		p.requireFeature(version.TypedEnv, p.curToken)
+	}
 
-		env.VarName = p.curToken.Literal
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+
+	if !p.expectPeek(token.STRING) {
+		return nil
+	}
 
-		// Check for optional default value
+	env.VarName = p.curToken.Literal
+
+	// @env.list takes an optional separator before the default value
+	if env.Type == "list" {
+		env.Separator = ","
 		if p.peekToken.Type == token.COMMA {
 			p.nextToken() // consume comma
-			p.nextToken() // move to default value
-			env.DefaultValue = p.parseExpression()
+			if !p.expectPeek(token.STRING) {
+				return nil
+			}
+			env.Separator = p.curToken.Literal
+			if env.Separator == "" {
+				p.addError(errors.CodeInvalidEnvDirective, "@env.list separator must not be empty")
+				return nil
+			}
 		}
+	}
 
-		if !p.expectPeek(token.RPAREN) {
+	// Check for optional default value
+	if p.peekToken.Type == token.COMMA {
+		p.nextToken() // consume comma
+		p.nextToken() // move to default value
+		env.DefaultValue = p.parseExpression()
+		if env.DefaultValue == nil {
 			return nil
 		}
+	}
 
-		return env
+	if !p.expectPeek(token.RPAREN) {
+		return nil
 	}
+	env.End = p.curToken
 
-	p.addError(fmt.Sprintf("unknown directive in expression context: %s", p.curToken.Literal))
-	return nil
+	return env
 }
 
 // parseTableStatement parses #table statements
 func (p *Parser) parseTableStatement() *ast.TableStatement {
+	defer p.finishNode(p.startNode(cst.Table))
 	stmt := &ast.TableStatement{Token: p.curToken}
 
 	if !p.expectPeek(token.IDENT) {
@@ -311,14 +541,16 @@ func (p *Parser) parseTableStatement() *ast.TableStatement {
 		stmt.Path = append(stmt.Path, p.curToken.Literal)
 	}
 
-	if !p.expectPeek(token.LBRACE) {
+	if p.peekToken.Type != token.LBRACE {
+		p.addErrorAtToken(errors.CodeExpectedObject, "table body must be an object", p.peekToken)
 		return nil
 	}
+	p.nextToken()
 
 	// Parse the object body and check if it's valid
 	objLiteral := p.parseObjectLiteral()
 	if objLiteral == nil {
-		p.addError("failed to parse table body")
+		p.addError(errors.CodeUnexpectedToken, "failed to parse table body")
 		return nil
 	}
 
@@ -326,7 +558,7 @@ func (p *Parser) parseTableStatement() *ast.TableStatement {
 	if obj, ok := objLiteral.(*ast.ObjectLiteral); ok {
 		stmt.Body = obj
 	} else {
-		p.addError("table body must be an object")
+		p.addError(errors.CodeExpectedObject, "table body must be an object")
 		return nil
 	}
 
@@ -351,7 +583,7 @@ func (p *Parser) parseNumberLiteral() ast.Expression {
 	if !strings.Contains(p.curToken.Literal, ".") {
 		value, err := strconv.ParseInt(p.curToken.Literal, 0, 64)
 		if err != nil {
-			p.addError(fmt.Sprintf("could not parse %q as integer", p.curToken.Literal))
+			p.addError(errors.CodeInvalidNumber, fmt.Sprintf("could not parse %q as integer", p.curToken.Literal))
 			return nil
 		}
 		lit.Value = value
@@ -359,7 +591,7 @@ func (p *Parser) parseNumberLiteral() ast.Expression {
 		// Parse as float
 		value, err := strconv.ParseFloat(p.curToken.Literal, 64)
 		if err != nil {
-			p.addError(fmt.Sprintf("could not parse %q as float", p.curToken.Literal))
+			p.addError(errors.CodeInvalidNumber, fmt.Sprintf("could not parse %q as float", p.curToken.Literal))
 			return nil
 		}
 		lit.Value = value
@@ -380,18 +612,22 @@ func (p *Parser) parseNullLiteral() ast.Expression {
 
 // parseArrayLiteral parses array literals
 func (p *Parser) parseArrayLiteral() ast.Expression {
+	defer p.finishNode(p.startNode(cst.Array))
 	arr := &ast.ArrayLiteral{Token: p.curToken}
 	arr.Elements = p.parseExpressionList(token.RBRACKET)
+	arr.End = p.curToken
 	return arr
 }
 
 // parseObjectLiteral parses object literals with optional commas and comment support
 func (p *Parser) parseObjectLiteral() ast.Expression {
+	defer p.finishNode(p.startNode(cst.Object))
 	obj := &ast.ObjectLiteral{Token: p.curToken}
 	obj.Pairs = make(map[ast.Expression]ast.Expression)
 
 	if p.peekToken.Type == token.RBRACE {
 		p.nextToken()
+		obj.End = p.curToken
 		return obj
 	}
 
@@ -404,6 +640,7 @@ func (p *Parser) parseObjectLiteral() ast.Expression {
 	if !p.expectPeek(token.RBRACE) {
 		return nil
 	}
+	obj.End = p.curToken
 
 	return obj
 }
@@ -432,9 +669,10 @@ func (p *Parser) parseObjectPairs(obj *ast.ObjectLiteral) bool {
 
 // parseObjectPair parses a single key-value pair in an object literal
 func (p *Parser) parseObjectPair(obj *ast.ObjectLiteral) bool {
+	defer p.finishNode(p.startNode(cst.Pair))
 	key := p.parseExpression()
 	if key == nil {
-		p.addError("failed to parse object key")
+		p.addError(errors.CodeUnexpectedToken, "failed to parse object key")
 		return false
 	}
 
@@ -445,7 +683,7 @@ func (p *Parser) parseObjectPair(obj *ast.ObjectLiteral) bool {
 	p.nextToken()
 	value := p.parseExpression()
 	if value == nil {
-		p.addError("failed to parse object value")
+		p.addError(errors.CodeUnexpectedToken, "failed to parse object value")
 		return false
 	}
 
@@ -492,10 +730,17 @@ func (p *Parser) handleObjectSeparators() bool {
 	}
 }
 
-// parseReference parses constant references like :namespace.CONSTANT
+// parseReference parses constant references like :namespace.CONSTANT and
+// output path references like :$.server.host
 func (p *Parser) parseReference() ast.Expression {
+	defer p.finishNode(p.startNode(cst.Reference))
 	ref := &ast.Reference{Token: p.curToken}
 
+	if p.peekToken.Type == token.DOLLAR {
+		p.nextToken()
+		return p.parseOutputPath(ref)
+	}
+
 	if !p.expectPeek(token.IDENT) {
 		return nil
 	}
@@ -511,6 +756,31 @@ func (p *Parser) parseReference() ast.Expression {
 	} else {
 		ref.Name = p.curToken.Literal
 	}
+	ref.End = p.curToken
+
+	return ref
+}
+
+// parseOutputPath parses the keys after :$, each written as an identifier or
+// a quoted string
+func (p *Parser) parseOutputPath(ref *ast.Reference) ast.Expression {
+	p.requireFeature(version.OutputReferences, ref.Token)
+
+	ref.Path = []string{}
+	for len(ref.Path) == 0 || p.peekToken.Type == token.DOT {
+		if !p.expectPeek(token.DOT) {
+			return nil
+		}
+		p.nextToken()
+		switch p.curToken.Type {
+		case token.IDENT, token.STRING, token.TRUE, token.FALSE, token.NULL:
+			ref.Path = append(ref.Path, p.curToken.Literal)
+		default:
+			p.addError(errors.CodeUnexpectedToken, fmt.Sprintf("expected a key in output path reference, got %s", p.curToken.Type))
+			return nil
+		}
+	}
+	ref.End = p.curToken
 
 	return ref
 }
@@ -552,11 +822,14 @@ func (p *Parser) expectPeek(t token.TokenType) bool {
 }
 
 // addError adds an error with position information
-func (p *Parser) addError(msg string) {
+func (p *Parser) addError(code, msg string) {
 	err := errors.CompilerError{
+		Code:     code,
 		Message:  msg,
 		Line:     p.curToken.Line,
 		Column:   p.curToken.Column,
+		Length:   p.curToken.Length,
+		End:      errors.TokenRange(p.curToken, p.curToken).End,
 		Source:   "",
 		Filename: "",
 	}
@@ -564,11 +837,14 @@ func (p *Parser) addError(msg string) {
 }
 
 // addErrorAtToken adds an error at a specific token's position
-func (p *Parser) addErrorAtToken(msg string, tok token.Token) {
+func (p *Parser) addErrorAtToken(code, msg string, tok token.Token) {
 	err := errors.CompilerError{
+		Code:     code,
 		Message:  msg,
 		Line:     tok.Line,
 		Column:   tok.Column,
+		Length:   tok.Length,
+		End:      errors.TokenRange(tok, tok).End,
 		Source:   "",
 		Filename: "",
 	}
@@ -578,7 +854,7 @@ func (p *Parser) addErrorAtToken(msg string, tok token.Token) {
 // peekError creates an error for unexpected peek token
 func (p *Parser) peekError(expected token.TokenType) {
 	msg := fmt.Sprintf("expected next token to be %s, got %s instead", expected, p.peekToken.Type)
-	p.addErrorAtToken(msg, p.peekToken)
+	p.addErrorAtToken(errors.CodeUnexpectedToken, msg, p.peekToken)
 }
 
 // Errors returns formatted error messages
@@ -591,26 +867,57 @@ func (p *Parser) Errors() []string {
 	return []string{formatted}
 }
 
+// Diagnostics returns the parse errors as structured diagnostics
+func (p *Parser) Diagnostics() []errors.Diagnostic {
+	diagnostics := make([]errors.Diagnostic, 0, len(p.errors))
+	for _, err := range p.errors {
+		diagnostics = append(diagnostics, err.Diagnostic(p.filename))
+	}
+	return diagnostics
+}
+
 // GetDetailedErrors returns the raw error objects for more detailed handling
 func (p *Parser) GetDetailedErrors() []errors.CompilerError {
 	return p.errors
 }
 
 // addBraceDirectiveError adds a specific error for @brace directive issues
-func (p *Parser) addBraceDirectiveError(msg string) {
-	// Use the enhanced error reporter for @brace specific errors
-	if p.errorReporter != nil {
-		formattedError := p.errorReporter.ReportBraceFileError(msg)
-		err := errors.CompilerError{
-			Message:  formattedError,
-			Line:     p.curToken.Line,
-			Column:   p.curToken.Column,
-			Source:   "",
-			Filename: "",
-		}
-		p.errors = append(p.errors, err)
+func (p *Parser) addBraceDirectiveError(code, msg string) {
+	p.addError(code, msg)
+	err := &p.errors[len(p.errors)-1]
+	err.Help = errors.BraceFileHelp
+	err.Notes = errors.BraceFileNotes
+}
+
+// requireFeature reports an error at tok if the declared version lacks a feature
+func (p *Parser) requireFeature(feature version.Feature, tok token.Token) {
+	if p.version.IsZero() || feature.Available(p.version) {
+		return
+	}
+	p.addErrorAtToken(errors.CodeUnavailableFeature, feature.Unavailable(p.version), tok)
+	err := &p.errors[len(p.errors)-1]
+	err.Notes = append(err.Notes, fmt.Sprintf("the version is declared with @brace %q at line %d", p.versionToken.Literal, p.versionToken.Line))
+	if feature.Available(version.Latest()) {
+		err.Help = append(err.Help, fmt.Sprintf("run `brace migrate` to upgrade the file to BRACE %s", version.Latest()))
+	}
+}
+
+// addHelp adds a help line to the most recent error
+func (p *Parser) addHelp(help string) {
+	err := &p.errors[len(p.errors)-1]
+	err.Help = append(err.Help, help)
+}
+
+// suggestDirective adds a "did you mean" help line for an unknown directive
+func (p *Parser) suggestDirective(name string, statement bool) {
+	candidates := p.directives.Names()
+	if statement {
+		candidates = append(candidates, directive.Builtins()...)
 	} else {
-		p.addError(msg)
+		candidates = append(candidates, "env")
+	}
+	if suggestion, ok := errors.Suggest(name, candidates); ok {
+		p.addHelp(fmt.Sprintf("did you mean `@%s`?", suggestion))
 	}
 }
 
@@ -655,7 +962,7 @@ func (p *Parser) parseTemplateStringParts(template string) []ast.TemplateStringP
 		i += start + 2 // Skip ${
 		end := strings.Index(template[i:], "}")
 		if end == -1 {
-			p.addError("unclosed interpolation in template string")
+			p.addError(errors.CodeUnclosedTemplate, "unclosed interpolation in template string")
 			break
 		}
 
@@ -682,6 +989,22 @@ func (p *Parser) parseInterpolationExpression(content string) ast.Expression {
 
 	content = strings.TrimSpace(content)
 
+	// Output path references like ":$.server.host" take identifier keys
+	if strings.HasPrefix(content, ":$") {
+		p.requireFeature(version.OutputReferences, p.curToken)
+		keys := strings.Split(strings.TrimPrefix(content, ":$."), ".")
+		for _, key := range keys {
+			if !strings.HasPrefix(content, ":$.") || !isValidIdentifier(key) {
+				p.addError(errors.CodeUnexpectedToken, fmt.Sprintf("invalid output path reference in template string: %s", content))
+				return nil
+			}
+		}
+		return &ast.Reference{
+			Token: token.Token{Type: token.COLON, Literal: ":"},
+			Path:  keys,
+		}
+	}
+
 	// Handle reference expressions that start with ":"
 	if strings.HasPrefix(content, ":") {
 		// This is a reference - parse it manually
@@ -1,9 +1,12 @@
 package parser
 
 import (
+	"os"
 	"strings"
 	"testing"
 
+	"github.com/tomdoesdev/brace/internal/ast"
+	"github.com/tomdoesdev/brace/internal/cst"
 	"github.com/tomdoesdev/brace/internal/lexer"
 	"github.com/tomdoesdev/brace/internal/token"
 )
@@ -27,12 +30,13 @@ func TestLexerOutput(t *testing.T) {
 }
 
 func TestSimpleTable(t *testing.T) {
-	source := `#database {
+	source := `@brace "1.0.0"
+#database {
     host = "localhost"
 }`
 
 	l := lexer.New(source)
-	p := New(l, "", "")
+	p := New(l, source, "")
 	program := p.ParseProgram()
 
 	if len(p.Errors()) > 0 {
@@ -42,8 +46,8 @@ func TestSimpleTable(t *testing.T) {
 		t.FailNow()
 	}
 
-	if len(program.Statements) != 1 {
-		t.Fatalf("Expected 1 statement, got %d", len(program.Statements))
+	if len(program.Statements) != 2 {
+		t.Fatalf("Expected 2 statements, got %d", len(program.Statements))
 	}
 
 	t.Logf("Parsed successfully: %s", program.String())
@@ -58,7 +62,7 @@ func TestBraceDirectiveValidation(t *testing.T) {
 	}{
 		{
 			name:        "valid brace directive",
-			source:      `@brace "1.0.0"\nname = "test"`,
+			source:      "@brace \"1.0.0\"\nname = \"test\"",
 			expectError: false,
 		},
 		{
@@ -81,18 +85,18 @@ func TestBraceDirectiveValidation(t *testing.T) {
 		},
 		{
 			name:        "comments before brace directive allowed",
-			source:      `// This is a comment\n@brace "1.0.0"\nname = "test"`,
+			source:      "// This is a comment\n@brace \"1.0.0\"\nname = \"test\"",
 			expectError: false,
 		},
 		{
 			name:        "brace directive without version",
-			source:      `@brace\nname = "test"`,
+			source:      "@brace\nname = \"test\"",
 			expectError: true,
 			errorMsg:    "@brace directive requires exactly one version parameter",
 		},
 		{
 			name:        "brace directive with non-string version",
-			source:      `@brace 1.0\nname = "test"`,
+			source:      "@brace 1.0\nname = \"test\"",
 			expectError: true,
 			errorMsg:    "@brace version must be a string literal",
 		},
@@ -122,3 +126,151 @@ func TestBraceDirectiveValidation(t *testing.T) {
 		})
 	}
 }
+
+func TestKeywordsInLists(t *testing.T) {
+	source := `@brace "1.0.0"
+flags = [true, false, null, "x"]
+`
+
+	l := lexer.New(source)
+	p := New(l, source, "")
+	program := p.ParseProgram()
+
+	if len(p.Errors()) > 0 {
+		t.Fatalf("Parser error: %s", p.Errors()[0])
+	}
+	assignment := program.Statements[1].(*ast.AssignmentStatement)
+	if elements := assignment.Value.(*ast.ArrayLiteral).Elements; len(elements) != 4 {
+		t.Errorf("expected 4 elements, got %d", len(elements))
+	}
+}
+
+func TestTokenSpans(t *testing.T) {
+	source := "a = \"\"\"one\ntwo\"\"\" /* x\n y */b = 'c' d = `e`\nf = \"unterminated"
+
+	tests := []struct {
+		literal            string
+		length             int
+		endLine, endColumn int
+	}{
+		{"one\ntwo", 13, 2, 7},
+		{"/* x\n y */", 10, 3, 6},
+		{"c", 3, 3, 13},
+		{"e", 3, 3, 21},
+		{"unterminated", 13, 4, 18},
+	}
+
+	l := lexer.New(source)
+	i := 0
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		if tok.Type != token.STRING && tok.Type != token.TEMPLATE_STRING && tok.Type != token.COMMENT {
+			continue
+		}
+		tt := tests[i]
+		i++
+		if tok.Literal != tt.literal || tok.Length != tt.length || tok.EndLine != tt.endLine || tok.EndColumn != tt.endColumn {
+			t.Errorf("expected %q with length %d ending at %d:%d, got %q with length %d ending at %d:%d",
+				tt.literal, tt.length, tt.endLine, tt.endColumn, tok.Literal, tok.Length, tok.EndLine, tok.EndColumn)
+		}
+	}
+	if i != len(tests) {
+		t.Errorf("expected %d tokens, got %d", len(tests), i)
+	}
+}
+
+// syntaxTreeCorpus holds the sources used in these tests plus the edge
+// cases of the lexer: comments everywhere, CRLF line endings, unterminated
+// tokens and parse errors
+var syntaxTreeCorpus = []string{
+	"#database {\n    host = \"localhost\"\n    port = 5432\n}",
+	"@brace \"1.0.0\"\n#database {\n    host = \"localhost\"\n}",
+	"@brace \"1.0.0\"\nname = \"test\"",
+	`name = "test"`,
+	`@const { NAME = "test" }`,
+	"",
+	"// This is a comment\n@brace \"1.0.0\"\nname = \"test\"",
+	"@brace\nname = \"test\"",
+	"@brace 1.0\nname = \"test\"",
+	"@brace \"1.0.0\"\nflags = [true, false, null, \"x\"]\n",
+	"@brace \"1.1.0\"\n#a { b = :$.c.\"d e\", f = `${:$.a.b}` }\n",
+	"a = \"\"\"one\ntwo\"\"\" /* x\n y */ b = 'c' d = `e`\nf = \"unterminated",
+	"\n\n  // leading\n@brace \"1.0.0\" // version\r\n\r\n/* block */x = 1;\t// trailing\n#a.b { c = :N.M, d = [1, 2.5] /* in */ }\n\n// end\n",
+	"@brace \"1.0.0\"\n@const \"net\" { PORT = 80 }\nport = @env.int(\"PORT\", :net.PORT)\nurl = `http://${:net.PORT}`\n",
+	"@brace \"1.0.0\"\nx = [1, 2\ny = { a = }\n$ z = 1",
+	"@brace \"1.0.0\"\nx = 1 /* unterminated",
+}
+
+func TestSyntaxTreeRoundTrip(t *testing.T) {
+	example, err := os.ReadFile("../../example.brace")
+	if err != nil {
+		t.Fatalf("reading example.brace: %v", err)
+	}
+
+	for _, source := range append([]string{string(example)}, syntaxTreeCorpus...) {
+		p := New(lexer.New(source), source, "")
+		p.ParseProgram()
+		if got := p.SyntaxTree().String(); got != source {
+			t.Errorf("expected the syntax tree to reproduce\n%q\ngot\n%q", source, got)
+		}
+	}
+}
+
+func TestSyntaxTree(t *testing.T) {
+	source := "@brace \"1.0.0\"\n\n// The service name\nname = \"api\" // short\n#server { port = @env.int(\"PORT\", 80) }\n"
+	p := New(lexer.New(source), source, "")
+	p.ParseProgram()
+	tree := p.SyntaxTree()
+
+	var kinds []string
+	cst.Inspect(tree, func(n *cst.Node) bool {
+		kinds = append(kinds, n.Kind.String())
+		return true
+	})
+	expected := "File Directive Literal Assignment Literal Table Object Pair Literal Env Literal"
+	if got := strings.Join(kinds, " "); got != expected {
+		t.Errorf("expected nodes %s, got %s", expected, got)
+	}
+
+	assignment := tree.Nodes()[1]
+	var leading []string
+	for _, trivia := range assignment.Leading() {
+		if trivia.Kind == cst.Comment {
+			leading = append(leading, trivia.Text)
+		}
+	}
+	if len(leading) != 1 || leading[0] != "// The service name" {
+		t.Errorf("expected the comment above the assignment to lead it, got %q", leading)
+	}
+	trailing := assignment.Trailing()
+	if len(trailing) != 2 || trailing[1].Kind != cst.Comment || trailing[1].Text != "// short" {
+		t.Errorf("expected the comment after the assignment to trail it, got %+v", trailing)
+	}
+	if table := tree.Nodes()[2]; table.Leading()[0].Text != "\n" {
+		t.Errorf("expected the line break after the trailing comment to lead the table, got %+v", table.Leading())
+	}
+}
+
+func TestDocComment(t *testing.T) {
+	tests := []struct {
+		source  string
+		comment string
+	}{
+		{"// Name of the service,\n// shown in logs\nx = 1\n", "Name of the service,\nshown in logs"},
+		{"// Detached\n\nx = 1\n", ""},
+		{"// Detached\n\n// Attached\nx = 1\n", "Attached"},
+		{"x = 1 // Trailing\n", "Trailing"},
+		{"// Above\nx = 1 // Trailing\n", "Above"},
+		{"/**\n * Block\n * comment\n */\nx = 1\n", "Block\ncomment"},
+		{"// brace:ignore shadowed-key\nx = 1\n", ""},
+		{"// Kept\n// brace:ignore\nx = 1\n", "Kept"},
+	}
+	for _, tt := range tests {
+		source := "@brace \"1.0.0\"\n" + tt.source
+		p := New(lexer.New(source), source, "")
+		p.ParseProgram()
+		assignment := p.SyntaxTree().Nodes()[1]
+		if comment := assignment.DocComment(); comment != tt.comment {
+			t.Errorf("%q: expected doc comment %q, got %q", tt.source, tt.comment, comment)
+		}
+	}
+}
@@ -0,0 +1,285 @@
+package query
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	braceerrors "github.com/tomdoesdev/brace/internal/errors"
+)
+
+// ErrNotFound is returned when a path matches nothing in a value
+var ErrNotFound = errors.New("path not found")
+
+// SegmentKind identifies how a path segment selects values
+type SegmentKind int
+
+const (
+	KeySegment      SegmentKind = iota // object key: name or "quoted name"
+	IndexSegment                       // array index: [0], [-1] counts from the end
+	WildcardSegment                    // every element of an object or array: * or [*]
+)
+
+// Segment is one step of a path
+type Segment struct {
+	Kind  SegmentKind
+	Key   string
+	Index int
+}
+
+func (s Segment) String() string {
+	switch s.Kind {
+	case IndexSegment:
+		return fmt.Sprintf("[%d]", s.Index)
+	case WildcardSegment:
+		return "*"
+	default:
+		if isIdentifier(s.Key) {
+			return s.Key
+		}
+		return strconv.Quote(s.Key)
+	}
+}
+
+// Path is a parsed query path such as database.hosts[0] or servers.*."dns name"
+type Path []Segment
+
+// HasWildcard reports whether the path can match more than one value
+func (p Path) HasWildcard() bool {
+	for _, segment := range p {
+		if segment.Kind == WildcardSegment {
+			return true
+		}
+	}
+	return false
+}
+
+func (p Path) String() string {
+	var b strings.Builder
+	for i, segment := range p {
+		if i > 0 && segment.Kind != IndexSegment {
+			b.WriteByte('.')
+		}
+		b.WriteString(segment.String())
+	}
+	return b.String()
+}
+
+// Pointer returns the path as an RFC 6901 JSON pointer
+// Wildcards are written as "*"
+func (p Path) Pointer() string {
+	var b strings.Builder
+	for _, segment := range p {
+		b.WriteByte('/')
+		switch segment.Kind {
+		case IndexSegment:
+			b.WriteString(strconv.Itoa(segment.Index))
+		case WildcardSegment:
+			b.WriteByte('*')
+		default:
+			b.WriteString(EscapePointer(segment.Key))
+		}
+	}
+	return b.String()
+}
+
+// EscapePointer escapes a key for use as a JSON pointer reference token
+func EscapePointer(key string) string {
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(key)
+}
+
+// Parse parses a path: dot-separated keys (quoted with "..." when they are not
+// identifiers), [N] array indices and * or [*] wildcards
+// An empty path or "." selects the whole value
+func Parse(path string) (Path, error) {
+	var result Path
+	if path == "." {
+		return result, nil
+	}
+
+	i := 0
+	expectKey := true // a key may start here without a leading dot
+	for i < len(path) {
+		c := path[i]
+		switch {
+		case c == '.':
+			if expectKey {
+				return nil, fmt.Errorf("invalid path %q: empty key at offset %d", path, i)
+			}
+			i++
+			expectKey = true
+			if i == len(path) {
+				return nil, fmt.Errorf("invalid path %q: trailing '.'", path)
+			}
+			continue
+
+		case c == '[':
+			end := strings.IndexByte(path[i:], ']')
+			if end < 0 {
+				return nil, fmt.Errorf("invalid path %q: unclosed '[' at offset %d", path, i)
+			}
+			inner := path[i+1 : i+end]
+			segment, err := parseBracket(inner)
+			if err != nil {
+				return nil, fmt.Errorf("invalid path %q: %v", path, err)
+			}
+			result = append(result, segment)
+			i += end + 1
+
+		case !expectKey:
+			return nil, fmt.Errorf("invalid path %q: expected '.' or '[' at offset %d", path, i)
+
+		case c == '"':
+			key, n, err := parseQuoted(path[i:])
+			if err != nil {
+				return nil, fmt.Errorf("invalid path %q: %v", path, err)
+			}
+			result = append(result, Segment{Kind: KeySegment, Key: key})
+			i += n
+
+		case c == '*':
+			result = append(result, Segment{Kind: WildcardSegment})
+			i++
+
+		default:
+			start := i
+			for i < len(path) && !strings.ContainsRune(`.["`, rune(path[i])) {
+				i++
+			}
+			result = append(result, Segment{Kind: KeySegment, Key: path[start:i]})
+		}
+		expectKey = false
+	}
+
+	return result, nil
+}
+
+// parseBracket parses the contents of [...]
+func parseBracket(inner string) (Segment, error) {
+	inner = strings.TrimSpace(inner)
+	switch {
+	case inner == "*":
+		return Segment{Kind: WildcardSegment}, nil
+	case strings.HasPrefix(inner, `"`):
+		key, n, err := parseQuoted(inner)
+		if err != nil {
+			return Segment{}, err
+		}
+		if n != len(inner) {
+			return Segment{}, fmt.Errorf("unexpected %q after quoted key", inner[n:])
+		}
+		return Segment{Kind: KeySegment, Key: key}, nil
+	default:
+		index, err := strconv.Atoi(inner)
+		if err != nil {
+			return Segment{}, fmt.Errorf("invalid array index %q", inner)
+		}
+		return Segment{Kind: IndexSegment, Index: index}, nil
+	}
+}
+
+// parseQuoted parses a Go-style quoted string at the start of s and returns
+// its value and length
+func parseQuoted(s string) (string, int, error) {
+	for end := 1; end < len(s); end++ {
+		switch s[end] {
+		case '\\':
+			end++
+		case '"':
+			key, err := strconv.Unquote(s[:end+1])
+			if err != nil {
+				return "", 0, fmt.Errorf("invalid quoted key %s", s[:end+1])
+			}
+			return key, end + 1, nil
+		}
+	}
+	return "", 0, fmt.Errorf("unterminated quoted key")
+}
+
+// isIdentifier reports whether key can be written in a path without quotes
+func isIdentifier(key string) bool {
+	if key == "" || key == "*" {
+		return false
+	}
+	return !strings.ContainsAny(key, ".[]\" ")
+}
+
+// Eval returns the values path selects from root, in document order with
+// object keys sorted
+// It returns ErrNotFound (wrapped with the unmatched prefix) if nothing matches
+func Eval(root interface{}, path Path) ([]interface{}, error) {
+	current := []interface{}{root}
+	for i, segment := range path {
+		var next []interface{}
+		for _, value := range current {
+			next = append(next, step(value, segment)...)
+		}
+		if len(next) == 0 {
+			if suggestion, ok := suggestKey(current, segment); ok {
+				corrected := append(path[:i:i], Segment{Kind: KeySegment, Key: suggestion})
+				return nil, fmt.Errorf("%w: %s (did you mean %s?)", ErrNotFound, path[:i+1], corrected)
+			}
+			return nil, fmt.Errorf("%w: %s", ErrNotFound, path[:i+1])
+		}
+		current = next
+	}
+	return current, nil
+}
+
+// suggestKey returns the key of the objects in values closest to a key
+// segment that matched nothing
+func suggestKey(values []interface{}, segment Segment) (string, bool) {
+	if segment.Kind != KeySegment {
+		return "", false
+	}
+	var keys []string
+	for _, value := range values {
+		if obj, ok := value.(map[string]interface{}); ok {
+			for key := range obj {
+				keys = append(keys, key)
+			}
+		}
+	}
+	return braceerrors.Suggest(segment.Key, keys)
+}
+
+// step applies one segment to a value
+func step(value interface{}, segment Segment) []interface{} {
+	switch segment.Kind {
+	case KeySegment:
+		if obj, ok := value.(map[string]interface{}); ok {
+			if child, ok := obj[segment.Key]; ok {
+				return []interface{}{child}
+			}
+		}
+	case IndexSegment:
+		if arr, ok := value.([]interface{}); ok {
+			index := segment.Index
+			if index < 0 {
+				index += len(arr)
+			}
+			if index >= 0 && index < len(arr) {
+				return []interface{}{arr[index]}
+			}
+		}
+	case WildcardSegment:
+		switch v := value.(type) {
+		case []interface{}:
+			return append([]interface{}(nil), v...)
+		case map[string]interface{}:
+			keys := make([]string, 0, len(v))
+			for key := range v {
+				keys = append(keys, key)
+			}
+			sort.Strings(keys)
+			result := make([]interface{}, 0, len(keys))
+			for _, key := range keys {
+				result = append(result, v[key])
+			}
+			return result
+		}
+	}
+	return nil
+}
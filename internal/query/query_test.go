@@ -0,0 +1,75 @@
+package query
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestEval(t *testing.T) {
+	root := map[string]interface{}{
+		"database": map[string]interface{}{
+			"port":  int64(5432),
+			"hosts": []interface{}{"db1", "db2", "db3"},
+		},
+		"labels": map[string]interface{}{
+			"app.kubernetes.io/name": "api",
+		},
+		"servers": []interface{}{
+			map[string]interface{}{"host": "a", "port": int64(80)},
+			map[string]interface{}{"host": "b", "port": int64(81)},
+		},
+	}
+
+	tests := []struct {
+		path     string
+		expected []interface{}
+	}{
+		{"database.port", []interface{}{int64(5432)}},
+		{"database.hosts[1]", []interface{}{"db2"}},
+		{"database.hosts[-1]", []interface{}{"db3"}},
+		{"servers[*].host", []interface{}{"a", "b"}},
+		{"servers.*.port", []interface{}{int64(80), int64(81)}},
+		{`labels."app.kubernetes.io/name"`, []interface{}{"api"}},
+		{`labels["app.kubernetes.io/name"]`, []interface{}{"api"}},
+		{"database.*", []interface{}{[]interface{}{"db1", "db2", "db3"}, int64(5432)}},
+		{".", []interface{}{root}},
+	}
+
+	for _, tt := range tests {
+		path, err := Parse(tt.path)
+		if err != nil {
+			t.Errorf("%s: parse failed: %v", tt.path, err)
+			continue
+		}
+		got, err := Eval(root, path)
+		if err != nil {
+			t.Errorf("%s: eval failed: %v", tt.path, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.expected) {
+			t.Errorf("%s: expected %v, got %v", tt.path, tt.expected, got)
+		}
+	}
+
+	for _, missing := range []string{"database.user", "database.hosts[3]", "database.port.value", "servers[*].tls"} {
+		path, _ := Parse(missing)
+		if _, err := Eval(root, path); !errors.Is(err, ErrNotFound) {
+			t.Errorf("%s: expected ErrNotFound, got %v", missing, err)
+		}
+	}
+
+	path, _ := Parse("databse.port")
+	if _, err := Eval(root, path); err == nil || !strings.Contains(err.Error(), "did you mean database?") {
+		t.Errorf("expected a suggestion for a misspelled key, got %v", err)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, path := range []string{"a..b", "a.", ".a", "a[", "a[x]", `a."b`, `a"b"`} {
+		if _, err := Parse(path); err == nil {
+			t.Errorf("%s: expected parse error", path)
+		}
+	}
+}
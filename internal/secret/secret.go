@@ -0,0 +1,134 @@
+package secret
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/tomdoesdev/brace/internal/env"
+)
+
+// Redacted is shown in place of secret values in errors, debug output and redacted output
+const Redacted = "[REDACTED]"
+
+// DefaultEnvPrefix is the environment variable prefix used by the CLI's -secrets-env
+const DefaultEnvPrefix = "BRACE_SECRET_"
+
+// ErrNotFound is returned by providers that do not have a secret
+var ErrNotFound = errors.New("secret not found")
+
+// Value is a resolved secret
+// Formatting a Value with fmt (%v, %s, %q, %#v) never reveals the plaintext
+type Value struct {
+	name      string
+	plaintext string
+}
+
+// New creates a secret value
+func New(name, plaintext string) Value {
+	return Value{name: name, plaintext: plaintext}
+}
+
+// Name returns the name the secret was requested by
+func (v Value) Name() string { return v.name }
+
+// Reveal returns the plaintext; only output generation should call it
+func (v Value) Reveal() string { return v.plaintext }
+
+// String implements fmt.Stringer and always returns Redacted
+func (v Value) String() string { return Redacted }
+
+// GoString implements fmt.GoStringer and always hides the plaintext
+func (v Value) GoString() string { return fmt.Sprintf("secret.Value{name: %q}", v.name) }
+
+// Provider resolves secrets by name
+type Provider interface {
+	// Secret returns the plaintext for name, or ErrNotFound
+	Secret(name string) (string, error)
+}
+
+// Chain tries providers in order and returns the first secret found
+type Chain []Provider
+
+// Secret implements Provider
+func (c Chain) Secret(name string) (string, error) {
+	for _, provider := range c {
+		value, err := provider.Secret(name)
+		if errors.Is(err, ErrNotFound) {
+			continue
+		}
+		return value, err
+	}
+	return "", ErrNotFound
+}
+
+// Dir reads secrets from files in a directory: "db/password" is read from <dir>/db/password
+// A single trailing newline is removed, so files written with echo work as expected
+type Dir string
+
+// Secret implements Provider
+func (d Dir) Secret(name string) (string, error) {
+	if err := ValidateName(name); err != nil {
+		return "", err
+	}
+
+	data, err := os.ReadFile(filepath.Join(string(d), filepath.FromSlash(name)))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", ErrNotFound
+		}
+		return "", fmt.Errorf("reading secret %s: %v", name, err)
+	}
+
+	value := strings.TrimSuffix(string(data), "\n")
+	return strings.TrimSuffix(value, "\r"), nil
+}
+
+// Env reads secrets from environment variables: "db/password" is read from
+// <Prefix>DB_PASSWORD
+type Env struct {
+	Provider env.Provider
+	Prefix   string
+}
+
+// Secret implements Provider
+func (e Env) Secret(name string) (string, error) {
+	if err := ValidateName(name); err != nil {
+		return "", err
+	}
+
+	value, ok := e.Provider.Lookup(EnvName(e.Prefix, name))
+	if !ok {
+		return "", ErrNotFound
+	}
+	return value, nil
+}
+
+// EnvName converts a secret name to the environment variable Env reads
+func EnvName(prefix, name string) string {
+	replacer := strings.NewReplacer("/", "_", "-", "_", ".", "_")
+	return prefix + strings.ToUpper(replacer.Replace(name))
+}
+
+// ValidateName checks that a secret name is a relative slash-separated path
+// of letters, digits, '.', '_' and '-' that cannot escape a provider's root
+func ValidateName(name string) error {
+	if name == "" {
+		return fmt.Errorf("secret name must not be empty")
+	}
+	for _, segment := range strings.Split(name, "/") {
+		if segment == "" || segment == "." || segment == ".." {
+			return fmt.Errorf("invalid secret name %q", name)
+		}
+		for _, ch := range segment {
+			valid := ch >= 'a' && ch <= 'z' || ch >= 'A' && ch <= 'Z' || ch >= '0' && ch <= '9' ||
+				ch == '.' || ch == '_' || ch == '-'
+			if !valid {
+				return fmt.Errorf("invalid secret name %q", name)
+			}
+		}
+	}
+	return nil
+}
@@ -39,6 +39,7 @@ const (
 	AT       // @ (directives)
 	HASH     // # (tables)
 	DOT      // . (namespace separator)
+	DOLLAR   // $ (output root in :$.path references)
 	BACKTICK // `
 )
 
@@ -49,7 +50,11 @@ type Token struct {
 	Line     int
 	Column   int
 	Position int
-	Length   int // Length of the token for better error reporting
+	Length   int // Length of the token in bytes, including quotes and delimiters
+
+	// Position just past the token; zero for tokens not produced by the lexer
+	EndLine   int
+	EndColumn int
 }
 
 // String returns a string representation of the token type
@@ -99,6 +104,8 @@ func (t TokenType) String() string {
 		return "#"
 	case DOT:
 		return "."
+	case DOLLAR:
+		return "$"
 	case BACKTICK:
 		return "`"
 	default:
@@ -0,0 +1,160 @@
+package transform
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/tomdoesdev/brace/internal/ast"
+	"github.com/tomdoesdev/brace/internal/query"
+	"github.com/tomdoesdev/brace/internal/token"
+)
+
+// Location is the position in the source a value was defined at
+type Location struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+// Span is a range of source text; lines and columns start at 1 and the end
+// column is exclusive
+type Span struct {
+	Line      int `json:"line"`
+	Column    int `json:"column"`
+	EndLine   int `json:"end_line"`
+	EndColumn int `json:"end_column"`
+}
+
+// Mapping locates one output value in the source
+type Mapping struct {
+	Key   *Span  `json:"key,omitempty"` // the key the value is stored under, nil for array elements and tables
+	Value Span   `json:"value"`         // where the value was written (a constant's definition for references)
+	Via   []Span `json:"via,omitempty"` // references and unset @env lookups followed to reach Value
+}
+
+// Resolver returns the expression a constant reference or an unset @env
+// lookup evaluated to, or nil if expr is not one
+type Resolver func(expr ast.Expression) ast.Expression
+
+// Mappings maps every value in the output of program, by JSON pointer, to the
+// source that produced it: the key of an assignment or object pair, the
+// header of a table, or the element of an array
+// Values reached through resolve are mapped to the expression it returns, with
+// the references and lookups followed recorded in Via; resolve may be nil
+// Values produced by directives (such as @file) are mapped to the directive;
+// their children have no entries of their own (see Locate)
+func Mappings(program *ast.Program, source string, resolve Resolver) map[string]Mapping {
+	m := &mapper{source: source, resolve: resolve, mappings: make(map[string]Mapping)}
+	m.mappings[""] = Mapping{Value: m.span(token.Token{Line: 1, Column: 1}, token.Token{Line: 1, Column: 1, Length: len(source)})}
+
+	for _, stmt := range program.Statements {
+		switch s := stmt.(type) {
+		case *ast.AssignmentStatement:
+			key := m.span(s.Name.Token, s.Name.Token)
+			m.mapExpression("/"+query.EscapePointer(s.Name.Value), &key, s.Value, nil)
+		case *ast.TableStatement:
+			table := Mapping{Value: m.span(s.Token, s.Body.End)}
+			pointer := ""
+			for _, segment := range s.Path {
+				pointer += "/" + query.EscapePointer(segment)
+				if _, ok := m.mappings[pointer]; !ok {
+					m.mappings[pointer] = table
+				}
+			}
+			m.mappings[pointer] = table
+			m.mapPairs(pointer, s.Body, nil)
+		case *ast.DirectiveStatement:
+			for key := range s.ResolvedOutput {
+				m.mappings["/"+query.EscapePointer(key)] = Mapping{Value: m.span(s.Token, s.End)}
+			}
+		}
+	}
+
+	return m.mappings
+}
+
+// mapper builds the mappings of a program
+type mapper struct {
+	source   string
+	resolve  Resolver
+	mappings map[string]Mapping
+}
+
+// mapExpression maps the value at pointer and its children
+func (m *mapper) mapExpression(pointer string, key *Span, expr ast.Expression, via []Span) {
+	value := m.span(ast.ExpressionToken(expr), ast.ExpressionEnd(expr))
+	m.mappings[pointer] = Mapping{Key: key, Value: value, Via: via}
+
+	switch e := expr.(type) {
+	case *ast.ObjectLiteral:
+		m.mapPairs(pointer, e, via)
+	case *ast.ArrayLiteral:
+		for i, element := range e.Elements {
+			m.mapExpression(pointer+"/"+strconv.Itoa(i), nil, element, via)
+		}
+	default:
+		if m.resolve == nil {
+			return
+		}
+		if target := m.resolve(expr); target != nil {
+			// Copy via so sibling values never share a backing array
+			followed := make([]Span, len(via), len(via)+1)
+			copy(followed, via)
+			m.mapExpression(pointer, key, target, append(followed, value))
+		}
+	}
+}
+
+// mapPairs maps the pairs of an object literal
+func (m *mapper) mapPairs(pointer string, obj *ast.ObjectLiteral, via []Span) {
+	for _, keyExpr := range ast.SortedKeys(obj) {
+		key := m.span(ast.ExpressionToken(keyExpr), ast.ExpressionEnd(keyExpr))
+		m.mapExpression(pointer+"/"+query.EscapePointer(ast.KeyName(keyExpr)), &key, obj.Pairs[keyExpr], via)
+	}
+}
+
+// span returns the span from the start of start to the end of end
+func (m *mapper) span(start, end token.Token) Span {
+	line, column := end.Line, end.Column
+	stop := end.Position + end.Length
+	if stop > len(m.source) {
+		stop = len(m.source)
+	}
+	for i := end.Position; i < stop; i++ {
+		if m.source[i] == '\n' {
+			line++
+			column = 1
+		} else {
+			column++
+		}
+	}
+	return Span{Line: start.Line, Column: start.Column, EndLine: line, EndColumn: column}
+}
+
+// Locations reduces mappings to the position each value was defined at: its
+// key if it has one, otherwise the start of its value
+func Locations(mappings map[string]Mapping) map[string]Location {
+	locations := make(map[string]Location, len(mappings))
+	for pointer, mapping := range mappings {
+		span := mapping.Value
+		if mapping.Key != nil {
+			span = *mapping.Key
+		}
+		locations[pointer] = Location{Line: span.Line, Column: span.Column}
+	}
+	return locations
+}
+
+// Locate returns the location of pointer, falling back to its nearest ancestor
+// with a location (e.g. the @file directive a value was embedded by)
+func Locate(locations map[string]Location, pointer string) Location {
+	for {
+		if location, ok := locations[pointer]; ok {
+			return location
+		}
+		idx := strings.LastIndexByte(pointer, '/')
+		if idx < 0 {
+			return Location{}
+		}
+		pointer = pointer[:idx]
+	}
+}
@@ -3,9 +3,10 @@ package transform
 import (
 	"encoding/json"
 	"fmt"
-	"strings"
 
 	"github.com/tomdoesdev/brace/internal/ast"
+	"github.com/tomdoesdev/brace/internal/errors"
+	"github.com/tomdoesdev/brace/internal/value"
 	"gopkg.in/yaml.v3"
 )
 
@@ -21,6 +22,8 @@ const (
 type Transform struct {
 	output map[string]interface{}
 	format OutputFormat
+	redact bool // emit secret.Redacted instead of secret plaintext
+	keep   bool // leave secrets wrapped in secret.Value
 }
 
 // New creates a new transform instance with JSON as default format
@@ -44,41 +47,69 @@ func (t *Transform) SetFormat(format OutputFormat) {
 	t.format = format
 }
 
+// SetRedact controls whether secrets are replaced with secret.Redacted in the output
+func (t *Transform) SetRedact(redact bool) {
+	t.redact = redact
+}
+
+// SetKeepSecrets controls whether Evaluate leaves secrets wrapped in
+// secret.Value, so callers can compare them without revealing them
+func (t *Transform) SetKeepSecrets(keep bool) {
+	t.keep = keep
+}
+
 // Transform converts the AST to the specified format and returns it as a string
 func (t *Transform) Transform(program *ast.Program) (string, error) {
+	output, err := t.Evaluate(program)
+	if err != nil {
+		return "", err
+	}
+	return t.Encode(output)
+}
+
+// Evaluate converts the AST to a tree of maps, slices and scalars
+func (t *Transform) Evaluate(program *ast.Program) (map[string]interface{}, error) {
 	// Process all statements
 	for _, stmt := range program.Statements {
 		err := t.processStatement(stmt)
 		if err != nil {
-			return "", err
+			return nil, err
 		}
 	}
 
-	// Convert to the specified format
+	// Secrets stay wrapped until the final output is produced
+	if !t.keep {
+		t.output = value.RevealSecrets(t.output, t.redact).(map[string]interface{})
+	}
+	return t.output, nil
+}
+
+// Encode converts a value produced by Evaluate to the specified format
+func (t *Transform) Encode(value interface{}) (string, error) {
 	switch t.format {
 	case FormatJSON:
-		return t.toJSON()
+		return toJSON(value)
 	case FormatYAML:
-		return t.toYAML()
+		return toYAML(value)
 	default:
-		return "", fmt.Errorf("unsupported output format: %s", t.format)
+		return "", errors.Errorf(errors.CodeUnsupportedFormat, "unsupported output format: %s", t.format)
 	}
 }
 
-// toJSON converts the output to JSON format
-func (t *Transform) toJSON() (string, error) {
-	jsonBytes, err := json.MarshalIndent(t.output, "", "  ")
+// toJSON converts a value to JSON format
+func toJSON(value interface{}) (string, error) {
+	jsonBytes, err := json.MarshalIndent(value, "", "  ")
 	if err != nil {
-		return "", fmt.Errorf("error marshaling to JSON: %v", err)
+		return "", errors.Errorf(errors.CodeEncodingFailed, "error marshaling to JSON: %v", err)
 	}
 	return string(jsonBytes), nil
 }
 
-// toYAML converts the output to YAML format
-func (t *Transform) toYAML() (string, error) {
-	yamlBytes, err := yaml.Marshal(t.output)
+// toYAML converts a value to YAML format
+func toYAML(value interface{}) (string, error) {
+	yamlBytes, err := yaml.Marshal(value)
 	if err != nil {
-		return "", fmt.Errorf("error marshaling to YAML: %v", err)
+		return "", errors.Errorf(errors.CodeEncodingFailed, "error marshaling to YAML: %v", err)
 	}
 	return string(yamlBytes), nil
 }
@@ -91,10 +122,14 @@ func (t *Transform) processStatement(stmt ast.Statement) error {
 	case *ast.TableStatement:
 		return t.processTable(s)
 	case *ast.DirectiveStatement:
-		// Directives don't contribute to output (they're processed during analysis)
+		// Builtin directives don't contribute to output (they're processed during
+		// analysis); custom directives may have produced top-level keys
+		for key, value := range s.ResolvedOutput {
+			t.output[key] = value
+		}
 		return nil
 	default:
-		return fmt.Errorf("unknown statement type: %T", stmt)
+		return errors.Errorf(errors.CodeUnresolvedValue, "unknown statement type: %T", stmt)
 	}
 }
 
@@ -151,8 +186,8 @@ func (t *Transform) evaluateExpression(expr ast.Expression) (interface{}, error)
 	case *ast.ObjectLiteral:
 		return t.evaluateObject(e)
 	case *ast.Reference:
-		// Use the resolved value from the analyzer
-		if e.ResolvedValue != nil {
+		// Use the resolved value from the analyzer; output paths may hold null
+		if e.ResolvedValue != nil || e.Path != nil {
 			return e.ResolvedValue, nil
 		}
 		// Construct full reference name for error
@@ -160,19 +195,22 @@ func (t *Transform) evaluateExpression(expr ast.Expression) (interface{}, error)
 		if e.Namespace != "" {
 			fullName = e.Namespace + "." + e.Name
 		}
-		return nil, fmt.Errorf("unresolved reference: %s", fullName)
+		return nil, errors.Errorf(errors.CodeUnresolvedValue, "unresolved reference: %s", fullName)
 	case *ast.EnvDirective:
-		// Use the resolved value from the analyzer
-		if e.ResolvedValue != nil {
+		// Use the resolved value from the analyzer; a default may be null
+		if e.Resolved {
 			return e.ResolvedValue, nil
 		}
-		return nil, fmt.Errorf("unresolved environment directive: @env(\"%s\")", e.VarName)
+		return nil, errors.Errorf(errors.CodeUnresolvedValue, "unresolved environment directive: @env(\"%s\")", e.VarName)
+	case *ast.DirectiveExpression:
+		// Custom directives may legitimately resolve to null
+		return e.ResolvedValue, nil
 	case *ast.Identifier:
 		return e.Value, nil
 	case *ast.TemplateStringLiteral:
 		return t.evaluateTemplateString(e)
 	default:
-		return nil, fmt.Errorf("cannot evaluate expression type: %T", expr)
+		return nil, errors.Errorf(errors.CodeUnresolvedValue, "cannot evaluate expression type: %T", expr)
 	}
 }
 
@@ -195,7 +233,9 @@ func (t *Transform) evaluateArray(arr *ast.ArrayLiteral) (interface{}, error) {
 func (t *Transform) evaluateObject(obj *ast.ObjectLiteral) (interface{}, error) {
 	result := make(map[string]interface{})
 
-	for key, value := range obj.Pairs {
+	// In source order, so the last of duplicate keys wins
+	for _, key := range ast.SortedKeys(obj) {
+		value := obj.Pairs[key]
 		keyStr, err := t.evaluateExpression(key)
 		if err != nil {
 			return nil, err
@@ -203,7 +243,7 @@ func (t *Transform) evaluateObject(obj *ast.ObjectLiteral) (interface{}, error)
 
 		keyString, ok := keyStr.(string)
 		if !ok {
-			return nil, fmt.Errorf("object keys must be strings, got %T", keyStr)
+			return nil, errors.Errorf(errors.CodeInvalidKey, "object keys must be strings, got %T", keyStr)
 		}
 
 		valueResult, err := t.evaluateExpression(value)
@@ -219,22 +259,9 @@ func (t *Transform) evaluateObject(obj *ast.ObjectLiteral) (interface{}, error)
 
 // evaluateTemplateString processes template string interpolation
 func (t *Transform) evaluateTemplateString(template *ast.TemplateStringLiteral) (interface{}, error) {
-	var result strings.Builder
-
-	for _, part := range template.Parts {
-		if part.IsLiteral {
-			result.WriteString(part.Content)
-		} else {
-			// Evaluate the interpolated expression
-			value, err := t.evaluateExpression(part.Expr)
-			if err != nil {
-				return nil, fmt.Errorf("error in template interpolation: %v", err)
-			}
-
-			// Convert to string
-			result.WriteString(fmt.Sprintf("%v", value))
-		}
+	result, err := value.Interpolate(template, t.evaluateExpression)
+	if err != nil {
+		return nil, fmt.Errorf("error in template interpolation: %w", err)
 	}
-
-	return result.String(), nil
+	return result, nil
 }
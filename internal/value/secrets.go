@@ -0,0 +1,69 @@
+package value
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/tomdoesdev/brace/internal/ast"
+	"github.com/tomdoesdev/brace/internal/secret"
+)
+
+// Interpolate builds the string of a template, evaluating each interpolated
+// expression with evaluate
+// A template that interpolates a secret becomes a secret itself
+func Interpolate(template *ast.TemplateStringLiteral, evaluate func(ast.Expression) (interface{}, error)) (interface{}, error) {
+	var result strings.Builder
+	var secretName string
+	containsSecret := false
+
+	for _, part := range template.Parts {
+		if part.IsLiteral || part.Expr == nil {
+			result.WriteString(part.Content)
+			continue
+		}
+		v, err := evaluate(part.Expr)
+		if err != nil {
+			return nil, err
+		}
+		if s, ok := v.(secret.Value); ok {
+			if !containsSecret {
+				secretName = s.Name()
+			}
+			containsSecret = true
+			result.WriteString(s.Reveal())
+			continue
+		}
+		result.WriteString(fmt.Sprintf("%v", v))
+	}
+
+	if containsSecret {
+		return secret.New(secretName, result.String()), nil
+	}
+	return result.String(), nil
+}
+
+// RevealSecrets replaces secret values in val with their plaintext, or with
+// secret.Redacted if redact is set
+func RevealSecrets(val interface{}, redact bool) interface{} {
+	switch v := val.(type) {
+	case secret.Value:
+		if redact {
+			return secret.Redacted
+		}
+		return v.Reveal()
+	case map[string]interface{}:
+		result := make(map[string]interface{}, len(v))
+		for key, element := range v {
+			result[key] = RevealSecrets(element, redact)
+		}
+		return result
+	case []interface{}:
+		result := make([]interface{}, len(v))
+		for i, element := range v {
+			result[i] = RevealSecrets(element, redact)
+		}
+		return result
+	default:
+		return val
+	}
+}
@@ -0,0 +1,197 @@
+package value
+
+import (
+	"sort"
+	"strconv"
+
+	"github.com/tomdoesdev/brace/internal/query"
+)
+
+// Kind is the kind of a value's type; integers and floats share Number, as in JSON
+type Kind int
+
+const (
+	Any  Kind = iota // unknown, or values of different types; fits every other type
+	Null             // only null was seen
+	String
+	Number
+	Boolean
+	Array
+	Object
+)
+
+var kindNames = [...]string{"any", "null", "string", "number", "boolean", "array", "object"}
+
+func (k Kind) String() string {
+	return kindNames[k]
+}
+
+// Type is the type of a BRACE value, inferred from values or expressions and
+// merged with Unify
+type Type struct {
+	Kind     Kind
+	Integer  bool              // for numbers: every number seen was an integer
+	Nullable bool              // null was seen alongside values of the type
+	Elem     *Type             // element type of arrays; nil if no element other than null was seen
+	Fields   map[string]*Field // fields of objects
+	Source   interface{}       // where the type was inferred from, such as an expression or a JSON pointer
+}
+
+// Field is a key of an object type
+type Field struct {
+	Type     *Type
+	Optional bool // missing from some of the objects the type was unified from
+}
+
+func (t *Type) String() string {
+	if t.Kind == Array && t.Elem != nil && t.Elem.Kind != Any {
+		return "array of " + t.Elem.String()
+	}
+	return t.Kind.String()
+}
+
+// Mismatch describes where two types could not be unified
+type Mismatch struct {
+	Field    string // dotted field path inside the types, "" for the types themselves
+	Expected *Type
+	Found    *Type
+}
+
+// TypeOf returns the type of a compiled value
+// source gives the Source of the type of each value by its JSON pointer below
+// v, and may be nil; mixed arrays get elements of type Any
+func TypeOf(v interface{}, source func(pointer string) interface{}) *Type {
+	if source == nil {
+		source = func(string) interface{} { return nil }
+	}
+	return typeOf(v, "", source)
+}
+
+func typeOf(v interface{}, pointer string, source func(string) interface{}) *Type {
+	t := &Type{Source: source(pointer)}
+	switch val := v.(type) {
+	case nil:
+		t.Kind = Null
+	case string:
+		t.Kind = String
+	case bool:
+		t.Kind = Boolean
+	case int, int64:
+		t.Kind, t.Integer = Number, true
+	case float64:
+		t.Kind = Number
+	case []interface{}:
+		t.Kind = Array
+		for i, element := range val {
+			elem := typeOf(element, pointer+"/"+strconv.Itoa(i), source)
+			if t.Elem == nil {
+				t.Elem = elem
+				continue
+			}
+			t.Elem, _ = Unify(t.Elem, elem)
+		}
+		if t.Elem != nil && t.Elem.Kind == Null {
+			t.Elem = nil
+		}
+	case map[string]interface{}:
+		t.Kind = Object
+		t.Fields = make(map[string]*Field, len(val))
+		for key, element := range val {
+			t.Fields[key] = &Field{Type: typeOf(element, pointer+"/"+query.EscapePointer(key), source)}
+		}
+	default:
+		t.Kind = Any
+	}
+	return t
+}
+
+// Unify returns the type of an array holding values of both types
+// The rule is relaxed: null and Any fit every type, and objects may leave
+// fields out, which makes them optional; only fields present in both objects
+// must agree
+// Types that do not agree become Any in the result, and the first such
+// disagreement is returned as a Mismatch
+func Unify(a, b *Type) (*Type, *Mismatch) {
+	switch {
+	case a.Kind == Null:
+		return nullable(b), nil
+	case b.Kind == Null:
+		return nullable(a), nil
+	case a.Kind == Any:
+		return a, nil
+	case b.Kind == Any:
+		return b, nil
+	case a.Kind != b.Kind:
+		return &Type{Kind: Any, Source: a.Source}, &Mismatch{Expected: a, Found: b}
+	}
+
+	t := *a
+	t.Nullable = a.Nullable || b.Nullable
+	var mismatch *Mismatch
+	switch a.Kind {
+	case Number:
+		t.Integer = a.Integer && b.Integer
+	case Array:
+		switch {
+		case a.Elem == nil:
+			t.Elem = b.Elem
+		case b.Elem != nil:
+			var m *Mismatch
+			if t.Elem, m = Unify(a.Elem, b.Elem); m != nil {
+				mismatch = &Mismatch{Expected: a, Found: b}
+			}
+		}
+	case Object:
+		t.Fields, mismatch = unifyFields(a.Fields, b.Fields)
+	}
+	return &t, mismatch
+}
+
+// unifyFields merges the fields of two object types
+func unifyFields(a, b map[string]*Field) (map[string]*Field, *Mismatch) {
+	fields := make(map[string]*Field, len(a))
+	for name, field := range a {
+		f := *field
+		f.Optional = f.Optional || b[name] == nil
+		fields[name] = &f
+	}
+
+	names := make([]string, 0, len(b))
+	for name := range b {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var mismatch *Mismatch
+	for _, name := range names {
+		field := b[name]
+		existing, ok := fields[name]
+		if !ok {
+			f := *field
+			f.Optional = true
+			fields[name] = &f
+			continue
+		}
+		merged, m := Unify(existing.Type, field.Type)
+		if m != nil && mismatch == nil {
+			mismatch = m
+			if m.Field == "" {
+				mismatch.Field = name
+			} else {
+				mismatch.Field = name + "." + m.Field
+			}
+		}
+		fields[name] = &Field{Type: merged, Optional: existing.Optional || field.Optional}
+	}
+	return fields, mismatch
+}
+
+// nullable returns a copy of t that also holds null
+func nullable(t *Type) *Type {
+	if t.Kind == Null || t.Kind == Any {
+		return t
+	}
+	c := *t
+	c.Nullable = true
+	return &c
+}
@@ -0,0 +1,65 @@
+package value
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"gopkg.in/yaml.v3"
+)
+
+// FromJSON decodes JSON into BRACE values (int64/float64 numbers, string-keyed maps)
+func FromJSON(data []byte) (interface{}, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	var result interface{}
+	if err := decoder.Decode(&result); err != nil {
+		return nil, err
+	}
+	if decoder.More() {
+		return nil, fmt.Errorf("unexpected data after JSON value")
+	}
+	return normalize(result), nil
+}
+
+// FromYAML decodes YAML into BRACE values
+func FromYAML(data []byte) (interface{}, error) {
+	var result interface{}
+	if err := yaml.Unmarshal(data, &result); err != nil {
+		return nil, err
+	}
+	return normalize(result), nil
+}
+
+// normalize converts decoder-specific types to the value types produced by the parser
+func normalize(v interface{}) interface{} {
+	switch val := v.(type) {
+	case json.Number:
+		if i, err := val.Int64(); err == nil {
+			return i
+		}
+		f, _ := val.Float64()
+		return f
+	case int:
+		return int64(val)
+	case []interface{}:
+		for i, element := range val {
+			val[i] = normalize(element)
+		}
+		return val
+	case map[string]interface{}:
+		for key, element := range val {
+			val[key] = normalize(element)
+		}
+		return val
+	case map[interface{}]interface{}:
+		result := make(map[string]interface{}, len(val))
+		for key, element := range val {
+			result[fmt.Sprintf("%v", key)] = normalize(element)
+		}
+		return result
+	default:
+		return v
+	}
+}
@@ -0,0 +1,73 @@
+package version
+
+import "fmt"
+
+// Feature is a language feature available from one version, and possibly
+// removed in a later one
+type Feature struct {
+	Name        string
+	Description string  // plural noun phrase used in error messages, such as "typed @env lookups"
+	Since       Version // first version with the feature
+	Until       Version // first version without the feature; zero while it is still available
+	Directive   string  // built-in directive the feature provides, if any
+	Migration   string  // how files change once the feature is removed, for deprecation advice
+}
+
+// Language features that depend on the declared version
+var (
+	EnvInference = Feature{
+		Name:        "env-inference",
+		Description: "untyped @env values converted to booleans and numbers",
+		Since:       V0_0_1,
+		Until:       V1_0_0,
+		Migration:   "untyped @env values are always strings, so use @env.int, @env.float or @env.bool where a number or boolean is needed",
+	}
+	TypedEnv           = Feature{Name: "typed-env", Description: "typed @env.<type> lookups", Since: V1_0_0}
+	EnvPolicy          = Feature{Name: "env-policy", Description: "@env_policy directives", Since: V1_0_0, Directive: "env_policy"}
+	FileDirective      = Feature{Name: "file", Description: "@file directives", Since: V1_0_0, Directive: "file"}
+	SecretDirective    = Feature{Name: "secret", Description: "@secret directives", Since: V1_0_0, Directive: "secret"}
+	EncryptedDirective = Feature{Name: "encrypted", Description: "@encrypted values", Since: V1_0_0, Directive: "encrypted"}
+	OutputReferences   = Feature{Name: "output-references", Description: "output path references such as :$.server.host", Since: V1_1_0}
+)
+
+// features lists every feature in the order they were introduced
+var features = []Feature{
+	EnvInference,
+	TypedEnv,
+	EnvPolicy,
+	FileDirective,
+	SecretDirective,
+	EncryptedDirective,
+	OutputReferences,
+}
+
+// Features returns every version-dependent feature
+func Features() []Feature {
+	return append([]Feature(nil), features...)
+}
+
+// ForDirective returns the feature that provides a built-in directive
+func ForDirective(name string) (Feature, bool) {
+	for _, f := range features {
+		if f.Directive != "" && f.Directive == name {
+			return f, true
+		}
+	}
+	return Feature{}, false
+}
+
+// Available reports whether the feature can be used in files declaring v
+func (f Feature) Available(v Version) bool {
+	if v.Compare(f.Since) < 0 {
+		return false
+	}
+	return f.Until.IsZero() || v.Compare(f.Until) < 0
+}
+
+// Unavailable explains why the feature cannot be used in files declaring v
+func (f Feature) Unavailable(v Version) string {
+	if !f.Until.IsZero() && v.Compare(f.Until) >= 0 {
+		return fmt.Sprintf("%s were removed in BRACE %s (the file declares %s)", f.Description, f.Until, v)
+	}
+	return fmt.Sprintf("%s require BRACE %s or later (the file declares %s)", f.Description, f.Since, v)
+}
@@ -0,0 +1,99 @@
+package version
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Version is a BRACE language version, as declared with @brace
+type Version struct {
+	Major int
+	Minor int
+	Patch int
+}
+
+// Parse parses a MAJOR.MINOR.PATCH version
+func Parse(s string) (Version, error) {
+	parts := strings.Split(s, ".")
+	if len(parts) != 3 {
+		return Version{}, fmt.Errorf("invalid version %q (expected MAJOR.MINOR.PATCH)", s)
+	}
+
+	var numbers [3]int
+	for i, part := range parts {
+		// Only plain digits, without leading zeros, so each version has one spelling
+		if part == "" || strings.Trim(part, "0123456789") != "" || (len(part) > 1 && part[0] == '0') {
+			return Version{}, fmt.Errorf("invalid version %q (expected MAJOR.MINOR.PATCH)", s)
+		}
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			return Version{}, fmt.Errorf("invalid version %q (expected MAJOR.MINOR.PATCH)", s)
+		}
+		numbers[i] = n
+	}
+	return Version{Major: numbers[0], Minor: numbers[1], Patch: numbers[2]}, nil
+}
+
+func (v Version) String() string {
+	return fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+}
+
+// IsZero reports whether v is unset
+func (v Version) IsZero() bool {
+	return v == Version{}
+}
+
+// Compare returns -1, 0 or 1 as v is older than, the same as or newer than other
+func (v Version) Compare(other Version) int {
+	for _, d := range []int{v.Major - other.Major, v.Minor - other.Minor, v.Patch - other.Patch} {
+		if d < 0 {
+			return -1
+		}
+		if d > 0 {
+			return 1
+		}
+	}
+	return 0
+}
+
+// Released BRACE versions
+var (
+	V0_0_1 = Version{Major: 0, Minor: 0, Patch: 1}
+	V1_0_0 = Version{Major: 1, Minor: 0, Patch: 0}
+	V1_1_0 = Version{Major: 1, Minor: 1, Patch: 0}
+	// Add new versions here and to supported as they're released
+)
+
+// supported lists the supported versions, oldest first
+var supported = []Version{V0_0_1, V1_0_0, V1_1_0}
+
+// deprecated lists supported versions that brace migrate upgrades
+var deprecated = map[Version]bool{
+	V0_0_1: true,
+}
+
+// Supported returns the supported versions, oldest first
+func Supported() []Version {
+	return append([]Version(nil), supported...)
+}
+
+// IsSupported reports whether v is a supported version
+func IsSupported(v Version) bool {
+	for _, s := range supported {
+		if s == v {
+			return true
+		}
+	}
+	return false
+}
+
+// IsDeprecated reports whether v is supported but should be upgraded
+func IsDeprecated(v Version) bool {
+	return deprecated[v]
+}
+
+// Latest returns the newest version
+func Latest() Version {
+	return supported[len(supported)-1]
+}
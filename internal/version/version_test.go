@@ -0,0 +1,78 @@
+package version
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	valid := map[string]Version{
+		"0.0.1":    V0_0_1,
+		"1.0.0":    V1_0_0,
+		"1.1.0":    V1_1_0,
+		"10.20.30": {Major: 10, Minor: 20, Patch: 30},
+	}
+	for s, want := range valid {
+		v, err := Parse(s)
+		if err != nil || v != want {
+			t.Errorf("Parse(%q): expected %v, got %v, %v", s, want, v, err)
+		}
+		if v.String() != s {
+			t.Errorf("expected %q to print as itself, got %q", s, v)
+		}
+	}
+
+	for _, s := range []string{"", "1", "1.0", "1.0.0.0", "01.0.0", "1.00.0", "1.0.01", "1.0.", ".1.0", "1.0.+0", "1.0.-0", "1.0.x", "v1.0.0", " 1.0.0"} {
+		if v, err := Parse(s); err == nil || !strings.Contains(err.Error(), "expected MAJOR.MINOR.PATCH") {
+			t.Errorf("Parse(%q): expected an error, got %v, %v", s, v, err)
+		}
+	}
+}
+
+func TestCompare(t *testing.T) {
+	ordered := []Version{{}, V0_0_1, {Minor: 2}, {Minor: 10}, V1_0_0, {Major: 1, Patch: 9}, V1_1_0, {Major: 2}}
+	for i, a := range ordered {
+		for j, b := range ordered {
+			want := 0
+			switch {
+			case i < j:
+				want = -1
+			case i > j:
+				want = 1
+			}
+			if got := a.Compare(b); got != want {
+				t.Errorf("%v.Compare(%v): expected %d, got %d", a, b, want, got)
+			}
+		}
+	}
+}
+
+func TestFeatureAvailable(t *testing.T) {
+	tests := []struct {
+		feature Feature
+		version Version
+		want    bool
+	}{
+		{TypedEnv, V0_0_1, false},
+		{TypedEnv, V1_0_0, true},
+		{TypedEnv, V1_1_0, true},
+		{OutputReferences, V1_0_0, false},
+		{OutputReferences, V1_1_0, true},
+		{EnvInference, V0_0_1, true},
+		{EnvInference, Version{Minor: 9}, true},
+		{EnvInference, V1_0_0, false},
+		{EnvInference, V1_1_0, false},
+	}
+	for _, tt := range tests {
+		if got := tt.feature.Available(tt.version); got != tt.want {
+			t.Errorf("%s in %v: expected available %v, got %v", tt.feature.Name, tt.version, tt.want, got)
+		}
+	}
+
+	if got := EnvInference.Unavailable(V1_0_0); !strings.Contains(got, "removed in BRACE 1.0.0") {
+		t.Errorf("expected a removed feature to say when it was removed, got %q", got)
+	}
+	if got := OutputReferences.Unavailable(V1_0_0); !strings.Contains(got, "require BRACE 1.1.0 or later") {
+		t.Errorf("expected a new feature to say when it was added, got %q", got)
+	}
+}